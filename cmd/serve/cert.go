@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"serve/internal/autotls"
+	"serve/internal/config"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// ipWatchInterval 自动 TLS 时检查网卡 IP 变化的间隔
+const ipWatchInterval = 30 * time.Second
+
+// serve cert 参数
+var certForce bool
+
+// certCmd 创建本地 CA 并签发证书
+var certCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "创建本地 CA 并签发覆盖本机所有 IP 和主机名的证书（与 --auto-tls 使用相同的文件）",
+	Long: `创建本地 CA 并签发覆盖本机所有 IP 和主机名的证书，输出文件路径和 CA 指纹。
+
+本地 CA 只创建一次并保存在 --auto-tls-dir 目录（默认为用户配置目录下的 serve/tls），
+将其中的 rootCA.pem 安装到手机等设备上后，这些设备信任 serve 签发的所有证书。
+证书包含 localhost、本机主机名、所有网卡 IP、--auto-tls-host 以及配置中虚拟主机和代理路由的主机名，
+已有证书包含全部名称且未临近过期时沿用，否则重新签发。
+
+启动服务器时使用 --auto-tls 自动完成同样的步骤，不需要先执行此命令。`,
+	Args: cobra.NoArgs,
+	Run:  runCert,
+}
+
+func init() {
+	certCmd.Flags().BoolVar(&certForce, "force", false, "总是重新签发证书（本地 CA 保持不变）")
+	rootCmd.AddCommand(certCmd)
+}
+
+// runCert 创建本地 CA 并签发证书
+func runCert(cmd *cobra.Command, args []string) {
+	cfg, err := buildConfig(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	store := &autotls.Store{Dir: cfg.GetAutoTLSDir()}
+	ca, created, err := store.LoadOrCreateCA()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	leaf, err := store.EnsureLeaf(ca, autotls.CollectNames(cfg.AutoTLSNames()), certForce)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	caState, leafState := "existing", "up to date"
+	if created {
+		caState = "created"
+	}
+	if leaf.Issued {
+		leafState = "issued"
+	}
+	fmt.Printf("Local CA (%s): %s\n", caState, store.CAFile())
+	fmt.Printf("  Subject: %s\n", ca.Cert.Subject)
+	fmt.Printf("  SHA-256: %s\n", autotls.Fingerprint(ca.Cert))
+	fmt.Printf("  Expires: %s\n", ca.Cert.NotAfter.Format(time.RFC3339))
+	fmt.Printf("Certificate (%s): %s\n", leafState, store.CertFile())
+	fmt.Printf("  Key:     %s\n", store.KeyFile())
+	fmt.Printf("  Names:   %s\n", certNames(leaf))
+	fmt.Printf("  Expires: %s\n", leaf.Cert.NotAfter.Format(time.RFC3339))
+	fmt.Println()
+	fmt.Printf("Install %s on your devices to trust the certificate, then start the server with --auto-tls\n", store.CAFile())
+	fmt.Printf("(or --ssl-cert-file %s --ssl-key-file %s).\n", store.CertFile(), store.KeyFile())
+}
+
+// applyAutoTLS 启用自动 TLS 时准备本地 CA 和证书，并将证书路径写入顶层 cert_file/key_file
+// 在配置校验之后、启动或热加载之前调用；证书已包含当前所有名称时沿用，不会重复签发
+func applyAutoTLS(cfg *config.Config, logger *logrus.Logger) error {
+	if !cfg.AutoTLS {
+		return nil
+	}
+
+	store := &autotls.Store{Dir: cfg.GetAutoTLSDir()}
+	ca, created, err := store.LoadOrCreateCA()
+	if err != nil {
+		return err
+	}
+	if created {
+		logger.Infof("Created local CA %s (SHA-256 %s), install it on devices to trust the auto TLS certificate",
+			store.CAFile(), autotls.Fingerprint(ca.Cert))
+	}
+
+	leaf, err := store.EnsureLeaf(ca, autotls.CollectNames(cfg.AutoTLSNames()), false)
+	if err != nil {
+		return err
+	}
+	if leaf.Issued {
+		logger.Infof("Issued auto TLS certificate %s for: %s", store.CertFile(), certNames(leaf))
+	} else {
+		logger.Debugf("Auto TLS certificate %s is up to date", store.CertFile())
+	}
+
+	cfg.CertFile, cfg.KeyFile = store.CertFile(), store.KeyFile()
+	return nil
+}
+
+// certNames 获取证书包含的名称列表
+func certNames(leaf *autotls.Leaf) string {
+	return autotls.Names{DNS: leaf.Cert.DNSNames, IPs: leaf.Cert.IPAddresses}.String()
+}
+
+// watchLocalIPs 轮询本机网卡 IP，发生变化时发送热加载通知，由热加载重新签发自动 TLS 证书
+func watchLocalIPs(interval time.Duration, reloads chan<- string, logger *logrus.Logger) {
+	last := localIPsKey()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		current := localIPsKey()
+		if current == last {
+			continue
+		}
+		logger.Infof("Local IP addresses changed: [%s] -> [%s]", last, current)
+		last = current
+
+		// 通知通道已有待处理的热加载时无需重复发送
+		select {
+		case reloads <- "local IP address change":
+		default:
+		}
+	}
+}
+
+// localIPsKey 获取本机网卡 IP 的标识，用于判断是否发生变化
+func localIPsKey() string {
+	ips := make([]string, 0)
+	for _, ip := range autotls.LocalIPs() {
+		ips = append(ips, ip.String())
+	}
+	sort.Strings(ips)
+	return strings.Join(ips, " ")
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"serve/internal/config"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// serve config print 参数
+	printFormat  string
+	printSources bool
+)
+
+// configCmd 配置管理命令
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "配置检查与查看",
+	Long: `配置检查与查看，适用于部署前在 CI 中检查配置。
+
+配置的加载方式与启动服务器时完全一致：命令行参数 > 环境变量 > 配置文件 > 默认值`,
+}
+
+// configValidateCmd 校验配置
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "校验配置，存在错误时列出全部错误并以非零状态码退出",
+	Args:  cobra.NoArgs,
+	Run:   runConfigValidate,
+}
+
+// configPrintCmd 输出最终生效的配置
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "校验配置后输出最终生效的配置及每个配置项的来源",
+	Args:  cobra.NoArgs,
+	Run:   runConfigPrint,
+}
+
+func init() {
+	configPrintCmd.Flags().StringVarP(&printFormat, "format", "f", "yaml", "输出格式（json, yaml）")
+	configPrintCmd.Flags().BoolVar(&printSources, "sources", true, "是否输出每个配置项的来源（关闭后输出内容可直接作为配置文件使用）")
+
+	configCmd.AddCommand(configValidateCmd, configPrintCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// runConfigValidate 解析并校验配置
+func runConfigValidate(cmd *cobra.Command, args []string) {
+	if code := validateConfig(cmd, cmd.OutOrStdout(), cmd.ErrOrStderr()); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// runConfigPrint 输出最终生效的配置
+func runConfigPrint(cmd *cobra.Command, args []string) {
+	if code := printConfig(cmd, cmd.OutOrStdout(), cmd.ErrOrStderr()); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// validateConfig 解析并校验配置，列出全部错误，返回进程退出码
+func validateConfig(cmd *cobra.Command, stdout, stderr io.Writer) int {
+	cfg, errs := loadValidatedConfig(cmd)
+	if len(errs) > 0 {
+		reportErrors(stderr, errs)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Configuration is valid (%d proxy configurations)\n", len(cfg.ProxyConfigs))
+	return 0
+}
+
+// printConfig 输出最终生效的配置，返回进程退出码
+// 输出前执行与 validate 相同的校验和规范化（如将静态目录解析为绝对路径），配置无效时列出错误
+func printConfig(cmd *cobra.Command, stdout, stderr io.Writer) int {
+	cfg, errs := loadValidatedConfig(cmd)
+	if len(errs) > 0 {
+		reportErrors(stderr, errs)
+		return 1
+	}
+
+	// 密码等敏感值不输出
+	var document interface{} = cfg.Redacted()
+	if printSources {
+		values, err := cfg.EffectiveValues()
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		sources := make(map[string]string, len(values))
+		for _, v := range values {
+			sources[v.Key] = v.Source
+		}
+		document = map[string]interface{}{
+			"config":  document,
+			"sources": sources,
+		}
+	}
+
+	out, err := marshalDocument(document, printFormat)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Fprint(stdout, out)
+	return 0
+}
+
+// loadValidatedConfig 按启动服务器时的方式加载配置并校验，返回全部错误
+func loadValidatedConfig(cmd *cobra.Command) (*config.Config, []error) {
+	cfg, err := buildConfig(cmd)
+	if err != nil {
+		return nil, config.SplitErrors(err)
+	}
+	if errs := config.SplitErrors(cfg.Validate()); len(errs) > 0 {
+		return nil, errs
+	}
+	return cfg, nil
+}
+
+// reportErrors 逐条列出配置错误
+func reportErrors(w io.Writer, errs []error) {
+	fmt.Fprintf(w, "Configuration is invalid (%d errors):\n", len(errs))
+	for _, err := range errs {
+		fmt.Fprintf(w, "  - %v\n", err)
+	}
+}
+
+// marshalDocument 将文档按指定格式序列化
+// YAML 输出先转换为 JSON 通用结构，保证字段名与配置文件一致
+func marshalDocument(document interface{}, format string) (string, error) {
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case "json":
+		return string(data) + "\n", nil
+	case "yaml", "yml":
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return "", err
+		}
+		out, err := yaml.Marshal(generic)
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	default:
+		return "", fmt.Errorf("unsupported output format: %s (must be json or yaml)", format)
+	}
+}
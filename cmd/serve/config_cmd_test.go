@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useConfigFile 让配置加载使用指定的配置文件，测试结束后恢复
+func useConfigFile(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "serve.json")
+	writeConfig(t, path, content)
+
+	saved := configFile
+	configFile = path
+	t.Cleanup(func() { configFile = saved })
+}
+
+func TestValidateConfig(t *testing.T) {
+	useConfigFile(t, `{"static_dir": `+quote(t.TempDir())+`}`)
+
+	var stdout, stderr bytes.Buffer
+	if code := validateConfig(configValidateCmd, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, want 0 (stderr: %s)", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Configuration is valid") {
+		t.Errorf("stdout = %q, want validity message", stdout.String())
+	}
+}
+
+func TestValidateConfigListsAllErrors(t *testing.T) {
+	useConfigFile(t, `{"log_level": "verbose", "static_dir": "/nonexistent/serve-static", "cert_file": "cert.pem"}`)
+
+	var stdout, stderr bytes.Buffer
+	if code := validateConfig(configValidateCmd, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code %d, want 1", code)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want empty", stdout.String())
+	}
+
+	out := stderr.String()
+	for _, want := range []string{
+		"Configuration is invalid (3 errors)",
+		"invalid log level: verbose",
+		"both cert_file and key_file must be provided",
+		"static directory not found",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stderr missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintConfigResolvesStaticDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "public"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	useConfigFile(t, `{"static_dir": "public"}`)
+	savedFormat, savedSources := printFormat, printSources
+	printFormat, printSources = "json", false
+	defer func() { printFormat, printSources = savedFormat, savedSources }()
+
+	var stdout, stderr bytes.Buffer
+	if code := printConfig(configPrintCmd, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, want 0 (stderr: %s)", code, stderr.String())
+	}
+
+	var printed struct {
+		StaticDir string `json:"static_dir"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &printed); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, stdout.String())
+	}
+	want, _ := filepath.EvalSymlinks(filepath.Join(dir, "public"))
+	if got, _ := filepath.EvalSymlinks(printed.StaticDir); got != want || !filepath.IsAbs(printed.StaticDir) {
+		t.Errorf("static_dir = %q, want absolute path %q", printed.StaticDir, want)
+	}
+}
+
+func TestPrintConfigInvalid(t *testing.T) {
+	useConfigFile(t, `{"log_level": "verbose", "static_dir": `+quote(t.TempDir())+`}`)
+
+	var stdout, stderr bytes.Buffer
+	if code := printConfig(configPrintCmd, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code %d, want 1", code)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want empty", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "invalid log level: verbose") {
+		t.Errorf("stderr = %q, want log level error", stderr.String())
+	}
+}
+
+// quote 将字符串编码为 JSON 字符串
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
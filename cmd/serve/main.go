@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
+	"serve/internal/autotls"
 	"serve/internal/config"
 	"serve/internal/server"
 
@@ -22,13 +26,27 @@ var (
 	version = "dev"
 
 	// 命令行参数
-	host      string
-	certFile  string
-	keyFile   string
-	logLevel  string
-	staticDir string
-
-	// 代理配置（格式：path_prefix:target_domain:use_https:insecure，可以多次使用 --proxy）
+	configFile  string
+	host        string
+	certFile    string
+	keyFile     string
+	logLevel    string
+	staticDir   string
+	statusPath  string
+	statusAllow []string
+	tlsProfile  string
+	listens     []string
+
+	// 自动 TLS
+	autoTLS      bool
+	autoTLSDir   string
+	autoTLSHosts []string
+
+	// 配置热加载
+	watchConfig   bool
+	watchInterval time.Duration
+
+	// 代理配置（URL 格式 /path_prefix=scheme://host[:port][/base_path][?options] 或旧格式 path_prefix:target_domain:use_https:insecure，可以多次使用 --proxy）
 	proxyConfigs []string
 )
 
@@ -39,11 +57,16 @@ var rootCmd = &cobra.Command{
 	Long: `serve 是一个基于 Go 的 HTTP/HTTPS 服务器，集成了静态文件服务和反向代理功能。
 
 功能特性：
-- 支持 HTTP 和 HTTPS 协议
+- 支持 HTTP 和 HTTPS 协议，支持同时监听多个地址
 - 支持静态文件服务
 - 支持反向代理，通过路径前缀匹配目标域名
 - 支持配置日志等级
 - 支持配置 SSL 证书
+- 支持从 YAML/JSON/TOML 配置文件加载配置
+- 支持通过 SERVE_* 环境变量配置
+- 支持通过 SIGHUP 信号或监听配置文件变化热加载代理和静态文件配置
+
+配置优先级（从高到低）：命令行参数 > 环境变量 > 配置文件 > 默认值
 
 使用示例：
   # 启动 HTTP 服务器
@@ -52,29 +75,113 @@ var rootCmd = &cobra.Command{
   # 启动 HTTPS 服务器
   serve --host :8443 --cert-file cert.pem --key-file key.pem --static-dir ./static
 
+  # 同时监听 HTTP 和 HTTPS
+  serve --listen http://:8080 --listen https://:8443 --ssl-cert-file cert.pem --ssl-key-file key.pem
+
   # 配置代理
   serve --host :8080 --static-dir ./static --proxy www.example.com:true:false
+
+  # 从配置文件加载（命令行显式指定的参数优先于配置文件）
+  serve --config serve.yaml --log-level debug
+
+  # 通过环境变量配置
+  SERVE_HOST=:9090 SERVE_PROXY=api:api.example.com:true:false serve
+
+  # 检查配置并输出最终生效的配置
+  serve config validate --config serve.yaml
+  serve config print --config serve.yaml --format yaml
 `,
 	Run: runServer,
 }
 
 func init() {
-	// 绑定命令行参数
-	rootCmd.Flags().StringVar(&host, "host", ":8080", "监听地址（如 :8080）")
-	rootCmd.Flags().StringVar(&certFile, "ssl-cert-file", "", "SSL 证书文件路径（启用 HTTPS）")
-	rootCmd.Flags().StringVar(&keyFile, "ssl-key-file", "", "SSL 私钥文件路径（启用 HTTPS）")
-	rootCmd.Flags().StringVar(&logLevel, "log-level", "info", "日志等级（debug, info, warn, error）")
-	rootCmd.Flags().StringVar(&staticDir, "static-dir", "./static", "静态文件目录路径")
+	// 绑定命令行参数（配置相关参数为全局参数，子命令如 serve config 共用）
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "配置文件路径（支持 .yaml/.yml/.json/.toml，也可通过 SERVE_CONFIG 指定），命令行显式指定的参数优先于配置文件")
+	rootCmd.PersistentFlags().StringVar(&host, "host", ":8080", "监听地址（如 :8080）")
+	rootCmd.PersistentFlags().StringVar(&certFile, "ssl-cert-file", "", "SSL 证书文件路径（启用 HTTPS）")
+	rootCmd.PersistentFlags().StringVar(&keyFile, "ssl-key-file", "", "SSL 私钥文件路径（启用 HTTPS）")
+	rootCmd.PersistentFlags().BoolVar(&autoTLS, "auto-tls", false, "自动 TLS：创建本地 CA 并签发覆盖本机所有 IP 和主机名的证书（启用 HTTPS，不能与 --ssl-cert-file 同时使用）")
+	rootCmd.PersistentFlags().StringVar(&autoTLSDir, "auto-tls-dir", "", "本地 CA 和证书的保存目录（默认为用户配置目录下的 serve/tls）")
+	rootCmd.PersistentFlags().StringArrayVar(&autoTLSHosts, "auto-tls-host", []string{}, "自动 TLS 证书额外包含的主机名或 IP，可以多次使用")
+	rootCmd.PersistentFlags().StringVar(&tlsProfile, "tls-profile", config.DefaultTLSProfile, "HTTPS 监听器的 TLS 档位：legacy（兼容 Android 4 等旧客户端）、intermediate、modern（仅 TLS 1.3）")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "日志等级（debug, info, warn, error）")
+	rootCmd.PersistentFlags().StringVar(&staticDir, "static-dir", "./static", "静态文件目录路径")
+	rootCmd.PersistentFlags().StringVar(&statusPath, "status-path", "", "状态接口路径（如 /_serve/status），以 JSON 输出代理上游状态，为空时不启用")
+	rootCmd.PersistentFlags().StringSliceVar(&statusAllow, "status-allow", []string{},
+		"允许访问状态接口的客户端 IP 或 CIDR（如 10.0.0.0/8），多个用逗号分隔或多次使用；本机回环地址始终允许")
+	rootCmd.PersistentFlags().StringArrayVar(&listens, "listen", []string{},
+		"监听器，格式：protocol://addr（如 http://:8080、https://:8443），可以多次使用以同时监听多个地址；指定后忽略 --host")
+
+	rootCmd.Flags().BoolVar(&watchConfig, "watch-config", false, "监听配置文件变化并自动热加载（也可以发送 SIGHUP 信号手动触发）")
+	rootCmd.Flags().DurationVar(&watchInterval, "watch-interval", 2*time.Second, "配置文件变化检查间隔")
 
 	// 版本显示
 	rootCmd.Flags().BoolP("version", "v", false, "显示版本信息")
 
-	rootCmd.Flags().StringArrayVar(&proxyConfigs, "proxy", []string{},
-		`反向代理配置，格式：path_prefix:target_domain:use_https:insecure，可以多次使用 --proxy 参数
-		
-格式说明：
+	rootCmd.PersistentFlags().StringArrayVar(&proxyConfigs, "proxy", []string{},
+		`反向代理配置，支持 URL 格式和旧格式，可以多次使用 --proxy 参数
+
+URL 格式：/path_prefix=scheme://host[:port][/base_path][?option=value&...]
+  - path_prefix: 路径前缀，可以包含多段（如 /api/v2），按最长前缀匹配，/ 匹配所有路径
+  - scheme: 目标协议，http 或 https
+  - host、port: 目标主机和端口，主机为空（如 https://）时使用 path_prefix 作为目标域名
+  - base_path: 目标基础路径，转发时拼接在请求路径之前
+  - 选项：
+      insecure=true|false      是否跳过 SSL 证书验证
+      ca_file=path             使用 PEM CA 证书文件验证上游证书（替代系统根证书）
+      server_name=name         上游 TLS 的 SNI 和证书验证名称
+      pin=sha256//base64       上游证书公钥的 SHA-256 固定值，可重复使用
+      cert_file=path           向上游出示的客户端证书（mTLS，PEM 格式），需同时设置 key_file
+      key_file=path            客户端证书的私钥（PEM 格式）
+      pkcs12_file=path         PKCS#12 格式的客户端证书和私钥（替代 cert_file 和 key_file）
+      pkcs12_password=pass     PKCS#12 文件的密码
+      tls_profile=name         上游连接的 TLS 档位：legacy（默认）、intermediate、modern
+      tls_min_version=1.2      上游连接的最低 TLS 版本（1.0、1.1、1.2、1.3），tls_max_version 设置最高版本
+      tls_ciphers=A,B          上游连接的加密套件（IANA 名称，逗号分隔，仅用于 TLS 1.2 及以下）
+      tls_curves=X25519,P256   上游连接的密钥交换曲线
+      strip_prefix=true|false  是否移除路径前缀（默认：指定了主机时保留，未指定时移除）
+      replace_prefix=/prefix   将路径前缀替换为指定前缀
+      rewrite=pattern->repl    正则重写规则，可重复使用，按顺序匹配，第一条匹配的规则生效
+      rewrite_body=true|false  改写 HTML/CSS/JS 中指向代理目标的链接，使其经过路径前缀
+      rewrite_root_relative=true|false  是否为以 / 开头的链接加上路径前缀（默认 true）
+      reverse_rewrite=true|false  将 Location、Refresh、Set-Cookie 中的上游地址映射回路径前缀
+      cookie_secure=mode       Set-Cookie 的 Secure 处理：auto（默认）、keep、strip
+      preserve_host=true|false 是否将原始 Host 头转发给上游
+      forwarded=mode           转发头模式：x_forwarded、forwarded（RFC 7239）、both、none
+      trusted_proxies=CIDR,... 可信代理网段，保留来自这些地址的转发头
+      set_header=Name:value    设置转发的请求头，可重复使用，值支持 {client_ip}、{request_id}、{host}、{scheme}
+      add_header=Name:value    追加转发的请求头，可重复使用
+      remove_header=N1,N2      移除转发的请求头
+      set_response_header=Name:value  设置返回的响应头，可重复使用
+      add_response_header=Name:value  追加返回的响应头，可重复使用
+      remove_response_header=N1,N2    移除返回的响应头（如 X-Frame-Options）
+      host=h1,h2               匹配的 Host 头（host:port、host 或 *.domain）
+      method=GET,POST          匹配的请求方法
+      priority=N               路由优先级，数值越大越优先
+      sticky_origin=true|false 未匹配任何路由的请求按 Referer 或 Cookie 转发到来源页面所属的此路由
+      upstream=host:port*N     追加上游目标（*N 为权重，默认 1），可重复使用，URL 中的主机为第一个目标
+      lb=policy                负载均衡策略：round_robin（默认）、least_conn、random_two、hash
+      hash_header=name         按请求头一致性哈希（hash 策略）
+      hash_cookie=name         按 Cookie 一致性哈希（hash 策略）
+      health_check=/path       启用主动健康检查并指定检查路径
+      health_interval=10s      健康检查间隔
+      health_timeout=2s        单次健康检查超时时间
+      circuit_breaker=N        启用熔断器，连续失败 N 次（默认 5）后熔断
+      cooldown=30s             熔断冷却时间
+      retry=N                  启用失败重试，总尝试次数 N（默认 3）
+      retry_on=kind,...        重试的失败类型：connect_error、connection_reset、timeout、5xx 或状态码
+      retry_backoff=100ms      第一次重试前的等待时间，之后每次翻倍
+      retry_non_idempotent     允许重试非幂等方法（如 POST）
+      dial_timeout=10s         建立上游连接的超时时间
+      keep_alive=30s           TCP keep-alive 探测间隔，负数关闭
+      idle_conn_timeout=90s    空闲连接保留时间
+      max_idle_conns_per_host=N 每个目标地址的最大空闲连接数
+      disable_keep_alives      禁用上游连接复用
+
+旧格式：path_prefix:target_domain[:port][/base_path]:use_https:insecure
   - path_prefix: 路径前缀，用于匹配请求路径第一段
   - target_domain: 目标域名，如果为空则使用 path_prefix 作为目标域名
+  - port、base_path: 可选的目标端口和目标基础路径（如 api:127.0.0.1:3000/v2:false:false）
   - use_https: 是否使用 HTTPS 协议，可选值：true（使用 HTTPS）或 false（使用 HTTP）
   - insecure: 是否跳过 SSL 证书验证，可选值：true（跳过验证）或 false（验证证书）
             仅在 use_https 为 true 时生效
@@ -86,9 +193,13 @@ func init() {
                                  3. 如果匹配成功：
                                     - 如果配置中指定了 target_domain，使用配置的目标域名，并保留路径前缀（完整路径转发）
                                     - 如果配置中未指定 target_domain（为空），使用路径第一段作为目标域名，并移除路径前缀
+                                    - 设置了 strip_prefix、replace_prefix 或 rewrite 时以其为准
                                  4. 如果未匹配，则不会进行代理转发（可能由静态文件服务处理）
 
                                使用示例：
+                                 --proxy /api=https://api.example.com:8443/v2?strip_prefix=true
+                                   匹配路径 /api/...，代理到 https://api.example.com:8443/v2/...（移除路径前缀），验证 SSL 证书
+
                                  --proxy api:api.example.com:true:false
                                    匹配路径 /api/...，代理到 https://api.example.com/api/...（保留路径前缀），验证 SSL 证书
                                  
@@ -99,6 +210,20 @@ func init() {
                                    配置多个代理，使用多个 --proxy 参数`)
 }
 
+// logClientCerts 输出配置了上游客户端证书（mTLS）的代理路由及证书路径，prefix 为虚拟主机名称前缀
+func logClientCerts(prefix string, proxyConfigs map[string]*config.ProxyConfig, logger *logrus.Logger) {
+	names := make([]string, 0, len(proxyConfigs))
+	for name, pc := range proxyConfigs {
+		if pc.TLS.HasClientCert() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		logger.Infof("Upstream client certificate for proxy route %s%s: %s", prefix, name, proxyConfigs[name].TLS.ClientCertPath())
+	}
+}
+
 // runServer 运行服务器
 func runServer(cmd *cobra.Command, args []string) {
 	// 检查是否显示版本
@@ -114,28 +239,28 @@ func runServer(cmd *cobra.Command, args []string) {
 	})
 
 	// 创建配置
-	cfg := config.LoadConfig()
-	cfg.Host = host
-	cfg.CertFile = certFile
-	cfg.KeyFile = keyFile
-	cfg.LogLevel = logLevel
-	cfg.StaticDir = staticDir
-
-	// 解析代理配置
-	if err := parseProxyConfigs(cfg, proxyConfigs); err != nil {
-		logger.Fatalf("Failed to parse proxy configs: %v", err)
+	cfg, err := buildConfig(cmd)
+	if err != nil {
+		logger.Fatalf("Failed to load configuration: %v", err)
 	}
 
 	// 设置日志等级
 	logger.SetLevel(cfg.GetLogLevel())
+	logConfigSources(cfg, logger)
 
 	// 验证配置
 	if err := cfg.Validate(); err != nil {
 		logger.Fatalf("Invalid configuration: %v", err)
 	}
+	if err := applyAutoTLS(cfg, logger); err != nil {
+		logger.Fatalf("Failed to prepare auto TLS certificate: %v", err)
+	}
 
 	// 创建服务器
-	srv := server.NewServer(cfg, logger)
+	srv, err := server.NewServer(cfg, logger)
+	if err != nil {
+		logger.Fatalf("Failed to create server: %v", err)
+	}
 
 	// 启动服务器（在 goroutine 中运行）
 	go func() {
@@ -145,18 +270,63 @@ func runServer(cmd *cobra.Command, args []string) {
 	}()
 
 	logger.Infof("Server started successfully on %s", srv.GetAddr())
-	if cfg.IsHTTPS() {
-		logger.Info("HTTPS mode enabled")
-	} else {
-		logger.Info("HTTP mode enabled")
+	for _, listener := range cfg.EffectiveListeners() {
+		if listener.IsHTTPS() {
+			logger.Infof("HTTPS mode enabled on %s", listener.Addr)
+		} else {
+			logger.Infof("HTTP mode enabled on %s", listener.Addr)
+		}
 	}
 	logger.Infof("Static directory: %s", cfg.StaticDir)
+	if cfg.AutoTLS {
+		store := &autotls.Store{Dir: cfg.GetAutoTLSDir()}
+		logger.Infof("Auto TLS enabled, local CA: %s", store.CAFile())
+	}
 	logger.Infof("Proxy configurations: %d", len(cfg.ProxyConfigs))
+	if cfg.StatusPath != "" {
+		if len(cfg.StatusAllow) > 0 {
+			logger.Infof("Status API: %s (allowed clients: loopback, %s)", cfg.StatusPath, strings.Join(cfg.StatusAllow, ", "))
+		} else {
+			logger.Infof("Status API: %s (allowed clients: loopback only)", cfg.StatusPath)
+		}
+	}
+	logClientCerts("", cfg.ProxyConfigs, logger)
+	for _, vhost := range cfg.VirtualHosts {
+		logger.Infof("Virtual host %s: hosts=%v, static directory=%q, proxy configurations=%d, default=%t",
+			vhost.Name, vhost.Hosts, vhost.StaticDir, len(vhost.ProxyConfigs), vhost.Default)
+		logClientCerts(vhost.Name+"/", vhost.ProxyConfigs, logger)
+	}
+
+	// 监听配置文件变化
+	reloads := make(chan string, 1)
+	if watchConfig {
+		if path := resolveConfigPath(cmd); path != "" {
+			go watchConfigFile(path, watchInterval, reloads, logger)
+			logger.Infof("Watching config file for changes: %s", path)
+		} else {
+			logger.Warn("--watch-config is set but no config file is specified")
+		}
+	}
+	// 自动 TLS 时监听网卡 IP 变化，通过热加载重新签发证书
+	if cfg.AutoTLS {
+		go watchLocalIPs(ipWatchInterval, reloads, logger)
+	}
 
-	// 等待中断信号
+	// 等待中断信号，SIGHUP 触发配置热加载
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for waiting := true; waiting; {
+		select {
+		case sig := <-quit:
+			if sig == syscall.SIGHUP {
+				reloadConfig(cmd, srv, logger, "SIGHUP")
+				continue
+			}
+			waiting = false
+		case reason := <-reloads:
+			reloadConfig(cmd, srv, logger, reason)
+		}
+	}
 
 	logger.Info("Received shutdown signal")
 
@@ -171,57 +341,170 @@ func runServer(cmd *cobra.Command, args []string) {
 	}
 }
 
+// buildConfig 构建最终生效的配置
+// 优先级：命令行显式指定的参数 > 环境变量（SERVE_*） > 配置文件 > 默认值
+func buildConfig(cmd *cobra.Command) (*config.Config, error) {
+	environ := os.Environ()
+	flags := cmd.Flags()
+
+	cfg, err := config.LoadConfigFile(resolveConfigPath(cmd))
+	if err != nil {
+		return nil, err
+	}
+
+	// 环境变量覆盖配置文件
+	// 环境变量和代理配置的错误会全部收集后一起返回
+	var errs []error
+	if err := cfg.ApplyEnv(environ); err != nil {
+		errs = append(errs, err)
+	}
+	for _, spec := range config.ProxySpecsFromEnv(environ) {
+		if err := parseProxyConfigs(cfg, []string{spec.Value}, config.SourceEnv+":"+spec.Name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", spec.Name, err))
+		}
+	}
+
+	// 仅覆盖命令行中显式指定的参数，未指定的参数保留环境变量、配置文件或默认值
+	stringFlags := []struct {
+		name  string
+		key   string
+		value string
+		field *string
+	}{
+		{"host", "host", host, &cfg.Host},
+		{"ssl-cert-file", "cert_file", certFile, &cfg.CertFile},
+		{"ssl-key-file", "key_file", keyFile, &cfg.KeyFile},
+		{"log-level", "log_level", logLevel, &cfg.LogLevel},
+		{"static-dir", "static_dir", staticDir, &cfg.StaticDir},
+		{"status-path", "status_path", statusPath, &cfg.StatusPath},
+	}
+	for _, f := range stringFlags {
+		if flags.Changed(f.name) {
+			*f.field = f.value
+			cfg.SetSource(f.key, config.SourceFlag+":--"+f.name)
+		}
+	}
+
+	// 命令行指定的状态接口访问列表整体替换配置文件和环境变量中的列表
+	if flags.Changed("status-allow") {
+		cfg.StatusAllow = statusAllow
+		cfg.SetSource("status_allow", config.SourceFlag+":--status-allow")
+	}
+
+	// 自动 TLS
+	if flags.Changed("auto-tls") {
+		cfg.AutoTLS = autoTLS
+		cfg.SetSource("auto_tls", config.SourceFlag+":--auto-tls")
+	}
+	if flags.Changed("auto-tls-dir") {
+		cfg.AutoTLSDir = autoTLSDir
+		cfg.SetSource("auto_tls_dir", config.SourceFlag+":--auto-tls-dir")
+	}
+	if flags.Changed("auto-tls-host") {
+		cfg.AutoTLSHosts = autoTLSHosts
+		cfg.SetSource("auto_tls_hosts", config.SourceFlag+":--auto-tls-host")
+	}
+
+	// 命令行指定的 TLS 档位覆盖顶层 tls 中的档位，其余 TLS 设置保留
+	if flags.Changed("tls-profile") {
+		if cfg.TLS == nil {
+			cfg.TLS = &config.TLSProfileConfig{}
+		}
+		cfg.TLS.Profile = tlsProfile
+		cfg.SetSource("tls", config.SourceFlag+":--tls-profile")
+	}
+
+	// 命令行指定的监听器整体替换配置文件和环境变量中的监听器
+	if flags.Changed("listen") {
+		cfg.Listeners = nil
+		for _, spec := range listens {
+			listener, err := config.ParseListener(spec)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			cfg.Listeners = append(cfg.Listeners, listener)
+		}
+		cfg.SetSource("listeners", config.SourceFlag+":--listen")
+	}
+
+	// 解析代理配置，相同路径前缀的命令行配置覆盖环境变量和配置文件中的配置
+	if err := parseProxyConfigs(cfg, proxyConfigs, config.SourceFlag+":--proxy"); err != nil {
+		errs = append(errs, config.SplitErrors(err)...)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// resolveConfigPath 获取配置文件路径，未通过 --config 指定时使用 SERVE_CONFIG
+func resolveConfigPath(cmd *cobra.Command) string {
+	if cmd.Flags().Changed("config") {
+		return configFile
+	}
+	if envPath, ok := config.LookupEnv(os.Environ(), config.EnvConfigFile); ok {
+		return envPath
+	}
+	return configFile
+}
+
+// logConfigSources 在 debug 级别输出每个配置项的生效值及来源
+func logConfigSources(cfg *config.Config, logger *logrus.Logger) {
+	if !logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+
+	values, err := cfg.EffectiveValues()
+	if err != nil {
+		logger.Debugf("Failed to resolve config sources: %v", err)
+		return
+	}
+	for _, v := range values {
+		logger.Debugf("Config %s = %s (source: %s)", v.Key, formatConfigValue(v.Value), v.Source)
+	}
+}
+
+// formatConfigValue 将配置值格式化为单行 JSON，便于日志输出
+func formatConfigValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
 // parseProxyConfigs 解析代理配置字符串数组
-// 格式：path_prefix:target_domain:use_https:insecure
-// 如果 target_domain 为空，则使用 path_prefix 作为目标域名
+// 支持 URL 格式和旧格式（详见 config.ParseProxySpec），两种格式可以混用
 // 可以多次使用 --proxy 参数来配置多个代理
 // 示例：
+//   - /api=https://api.example.com:8443?insecure=true（URL 格式，路径前缀 api）
 //   - api:api.example.com:true:false（路径前缀 api，目标域名 api.example.com）
 //   - api::true:false（路径前缀 api，target_domain 为空，使用 api 作为目标域名）
-func parseProxyConfigs(cfg *config.Config, proxyConfigs []string) error {
-	if len(proxyConfigs) == 0 {
-		return nil
-	}
-
+//
+// source 为这些配置的来源，用于记录配置项来源
+func parseProxyConfigs(cfg *config.Config, proxyConfigs []string, source string) error {
+	var errs []error
 	for _, configStr := range proxyConfigs {
 		configStr = strings.TrimSpace(configStr)
 		if configStr == "" {
 			continue
 		}
 
-		// 分割配置项（使用 SplitN 限制分割次数为4，避免域名中的冒号影响）
-		parts := strings.SplitN(configStr, ":", 4)
-
-		if len(parts) != 4 {
-			return fmt.Errorf("invalid proxy config format: %s (expected: path_prefix:target_domain:use_https:insecure)", configStr)
-		}
-
-		pathPrefix := strings.TrimSpace(parts[0])
-		targetDomain := strings.TrimSpace(parts[1])
-		useHTTPSStr := strings.TrimSpace(parts[2])
-		insecureStr := strings.TrimSpace(parts[3])
-
-		// 解析 use_https
-		useHTTPS := false
-		if useHTTPSStr == "true" {
-			useHTTPS = true
-		} else if useHTTPSStr != "false" {
-			return fmt.Errorf("invalid use_https value: %s (must be true or false)", useHTTPSStr)
-		}
-
-		// 解析 insecure
-		insecure := false
-		if insecureStr == "true" {
-			insecure = true
-		} else if insecureStr != "false" {
-			return fmt.Errorf("invalid insecure value: %s (must be true or false)", insecureStr)
+		// 单个配置解析失败时继续解析其余配置，以便一次性列出所有错误
+		name, proxyConfig, err := config.ParseProxySpec(configStr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
 		}
 
 		// 添加代理配置
-		cfg.AddProxyConfig(pathPrefix, targetDomain, useHTTPS, insecure)
+		cfg.SetProxyConfig(name, proxyConfig)
+		cfg.SetProxySource(name, source)
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func main() {
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"serve/internal/server"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// reloadConfig 重新加载配置并替换服务器的路由快照
+// 新配置无效时保留旧配置继续运行，仅输出错误日志
+func reloadConfig(cmd *cobra.Command, srv *server.Server, logger *logrus.Logger, reason string) {
+	logger.Infof("Reloading configuration (triggered by %s)", reason)
+
+	cfg, err := buildConfig(cmd)
+	if err != nil {
+		logger.Errorf("Failed to reload configuration, keeping current one: %v", err)
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		logger.Errorf("Invalid configuration, keeping current one: %v", err)
+		return
+	}
+	if err := applyAutoTLS(cfg, logger); err != nil {
+		logger.Errorf("Failed to prepare auto TLS certificate, keeping current one: %v", err)
+		return
+	}
+
+	if err := srv.Reload(cfg); err != nil {
+		logger.Errorf("Failed to apply configuration, keeping current one: %v", err)
+		return
+	}
+
+	logger.SetLevel(cfg.GetLogLevel())
+	logConfigSources(cfg, logger)
+}
+
+// watchConfigFile 轮询配置文件的修改时间和大小，发生变化时发送热加载通知
+// 使用轮询而不是文件系统事件，兼容编辑器先写临时文件再重命名的保存方式
+func watchConfigFile(path string, interval time.Duration, reloads chan<- string, logger *logrus.Logger) {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(path)
+		if err != nil {
+			// 文件暂时不存在（如正在被替换），等待下一次检查
+			logger.Debugf("Failed to stat config file %s: %v", path, err)
+			continue
+		}
+
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+
+		// 通知通道已有待处理的热加载时无需重复发送
+		select {
+		case reloads <- "config file change":
+		default:
+		}
+	}
+}
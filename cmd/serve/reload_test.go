@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"serve/internal/server"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// writeConfig 写入 JSON 配置文件
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// proxyStatus 通过服务器路由请求 /api/ping，返回状态码
+func proxyStatus(srv *server.Server) int {
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/ping", nil))
+	return rec.Code
+}
+
+func TestReloadConfig(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "pong")
+	}))
+	defer upstream.Close()
+
+	staticDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "serve.json")
+	withProxy := fmt.Sprintf(`{"static_dir": %q, "proxy_configs": {"api": {"target_domain": %q}}}`,
+		staticDir, upstream.Listener.Addr().String())
+	writeConfig(t, path, withProxy)
+
+	saved := configFile
+	configFile = path
+	defer func() { configFile = saved }()
+
+	logger, hook := test.NewNullLogger()
+	cfg, err := buildConfig(rootCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := server.NewServer(cfg, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := proxyStatus(srv); code != http.StatusOK {
+		t.Fatalf("initial config: status %d, want %d", code, http.StatusOK)
+	}
+
+	// 无效配置（静态目录不存在）保留当前路由
+	writeConfig(t, path, `{"static_dir": "/nonexistent/serve-static"}`)
+	reloadConfig(rootCmd, srv, logger, "test")
+
+	entry := hook.LastEntry()
+	if entry == nil || entry.Level != logrus.ErrorLevel || !strings.Contains(entry.Message, "keeping current one") {
+		t.Fatalf("invalid config: last log entry %v, want error keeping current config", entry)
+	}
+	if code := proxyStatus(srv); code != http.StatusOK {
+		t.Fatalf("after invalid config: status %d, want %d", code, http.StatusOK)
+	}
+
+	// 有效配置移除代理路由
+	writeConfig(t, path, fmt.Sprintf(`{"static_dir": %q}`, staticDir))
+	reloadConfig(rootCmd, srv, logger, "test")
+
+	if code := proxyStatus(srv); code != http.StatusNotFound {
+		t.Fatalf("after removing route: status %d, want %d", code, http.StatusNotFound)
+	}
+}
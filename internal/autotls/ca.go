@@ -0,0 +1,248 @@
+package autotls
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 本地 CA 和证书的文件名
+const (
+	CAFileName      = "rootCA.pem"
+	CAKeyFileName   = "rootCA-key.pem"
+	CertFileName    = "cert.pem"
+	CertKeyFileName = "key.pem"
+)
+
+// CAValidity 本地 CA 的有效期
+const CAValidity = 10 * 365 * 24 * time.Hour
+
+// CAOrganization 本地 CA 证书的组织名称，用于识别 serve 创建的 CA
+const CAOrganization = "serve local CA"
+
+// Store 本地 CA 和证书的存储目录
+type Store struct {
+	Dir string
+}
+
+// CAFile 获取 CA 证书文件路径（需要安装到设备上的文件）
+func (s *Store) CAFile() string {
+	return filepath.Join(s.Dir, CAFileName)
+}
+
+// CAKeyFile 获取 CA 私钥文件路径
+func (s *Store) CAKeyFile() string {
+	return filepath.Join(s.Dir, CAKeyFileName)
+}
+
+// CertFile 获取服务器证书文件路径
+func (s *Store) CertFile() string {
+	return filepath.Join(s.Dir, CertFileName)
+}
+
+// KeyFile 获取服务器证书私钥文件路径
+func (s *Store) KeyFile() string {
+	return filepath.Join(s.Dir, CertKeyFileName)
+}
+
+// CA 本地根证书及其私钥
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// LoadOrCreateCA 加载本地 CA，不存在时创建并保存，created 表示是否新建
+// CA 私钥只保存在本机，权限为 0600
+func (s *Store) LoadOrCreateCA() (ca *CA, created bool, err error) {
+	ca, err = LoadCA(s.CAFile(), s.CAKeyFile())
+	if err == nil {
+		return ca, false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+	if _, statErr := os.Stat(s.CAKeyFile()); statErr == nil {
+		// 只剩私钥或只剩证书时不覆盖，避免已安装到设备上的 CA 失效
+		return nil, false, fmt.Errorf("incomplete local CA in %s: %v", s.Dir, err)
+	}
+
+	ca, err = newCA()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return nil, false, fmt.Errorf("failed to create directory %s: %v", s.Dir, err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(ca.Key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode CA key: %v", err)
+	}
+	if err := writePEM(s.CAKeyFile(), "PRIVATE KEY", keyDER, 0o600); err != nil {
+		return nil, false, err
+	}
+	if err := writePEM(s.CAFile(), "CERTIFICATE", ca.Cert.Raw, 0o644); err != nil {
+		return nil, false, err
+	}
+	return ca, true, nil
+}
+
+// LoadCA 从文件加载本地 CA，文件不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)
+func LoadCA(certFile, keyFile string) (*CA, error) {
+	cert, err := LoadCertificate(certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", keyFile)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key %s: %v", keyFile, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported CA key type in %s", keyFile)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s is not a CA certificate", certFile)
+	}
+	return &CA{Cert: cert, Key: signer}, nil
+}
+
+// LoadCertificate 读取 PEM 文件中的第一张证书
+func LoadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found in %s", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %v", path, err)
+	}
+	return cert, nil
+}
+
+// Fingerprint 获取证书的 SHA-256 指纹，格式为冒号分隔的大写十六进制（与系统证书详情中显示的格式一致）
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return colonHex(sum[:])
+}
+
+// FingerprintSHA1 获取证书的 SHA-1 指纹，部分旧设备的证书详情只显示 SHA-1
+func FingerprintSHA1(cert *x509.Certificate) string {
+	sum := sha1.Sum(cert.Raw)
+	return colonHex(sum[:])
+}
+
+// newCA 生成新的本地根证书
+// 使用 RSA 2048 而不是 ECDSA，兼容 Android 4 等只支持 RSA 证书的旧设备
+func newCA() (*CA, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %v", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	owner := "serve"
+	if u, err := user.Current(); err == nil {
+		owner = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		owner += "@" + host
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization:       []string{CAOrganization},
+			OrganizationalUnit: []string{owner},
+			CommonName:         CAOrganization + " " + owner,
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(CAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// randomSerial 生成 128 位随机序列号
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	return serial, nil
+}
+
+// writePEM 写入 PEM 文件，先写临时文件再重命名，运行中的服务器不会读到写了一半的文件
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := pem.Encode(tmp, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// colonHex 将字节转换为冒号分隔的大写十六进制
+func colonHex(b []byte) string {
+	h := strings.ToUpper(hex.EncodeToString(b))
+	parts := make([]string, 0, len(b))
+	for i := 0; i < len(h); i += 2 {
+		parts = append(parts, h[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,96 @@
+package autotls
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// assertPerm 检查文件权限
+func assertPerm(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != want {
+		t.Errorf("%s permissions = %o, want %o", filepath.Base(path), got, want)
+	}
+}
+
+func TestLoadOrCreateCA(t *testing.T) {
+	s := &Store{Dir: filepath.Join(t.TempDir(), "tls")}
+
+	ca, created, err := s.LoadOrCreateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Error("created = false for an empty directory")
+	}
+	if !ca.Cert.IsCA || ca.Cert.Subject.Organization[0] != CAOrganization {
+		t.Errorf("CA certificate = %s, want a CA issued for %q", ca.Cert.Subject, CAOrganization)
+	}
+	assertPerm(t, s.Dir, 0o700)
+	assertPerm(t, s.CAKeyFile(), 0o600)
+	assertPerm(t, s.CAFile(), 0o644)
+
+	// 再次加载时沿用已保存的 CA
+	again, created, err := s.LoadOrCreateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Error("created = true for an existing CA")
+	}
+	if !again.Cert.Equal(ca.Cert) {
+		t.Error("LoadOrCreateCA() returned a different CA certificate on the second call")
+	}
+}
+
+func TestLoadOrCreateCAIncomplete(t *testing.T) {
+	s := &Store{Dir: t.TempDir()}
+	if _, _, err := s.LoadOrCreateCA(); err != nil {
+		t.Fatal(err)
+	}
+	key, err := os.ReadFile(s.CAKeyFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只剩私钥时报错，不生成新的 CA 覆盖私钥
+	if err := os.Remove(s.CAFile()); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = s.LoadOrCreateCA()
+	if err == nil || !strings.Contains(err.Error(), "incomplete local CA") {
+		t.Fatalf("LoadOrCreateCA() error = %v, want incomplete local CA", err)
+	}
+	if after, _ := os.ReadFile(s.CAKeyFile()); string(after) != string(key) {
+		t.Error("CA key was overwritten")
+	}
+}
+
+func TestLoadOrCreateCAInvalid(t *testing.T) {
+	s := &Store{Dir: t.TempDir()}
+	if err := os.WriteFile(s.CAFile(), []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.LoadOrCreateCA(); err == nil || !strings.Contains(err.Error(), "no PEM certificate") {
+		t.Fatalf("LoadOrCreateCA() error = %v, want a parse error", err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	ca, err := newCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Fingerprint(ca.Cert); len(got) != 32*3-1 || strings.Count(got, ":") != 31 || strings.ToUpper(got) != got {
+		t.Errorf("Fingerprint() = %s, want 32 colon-separated uppercase hex bytes", got)
+	}
+	if got := FingerprintSHA1(ca.Cert); len(got) != 20*3-1 {
+		t.Errorf("FingerprintSHA1() = %s, want 20 colon-separated hex bytes", got)
+	}
+}
@@ -0,0 +1,234 @@
+package autotls
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LeafValidity 服务器证书的有效期，不超过 Apple 和 Chrome 接受的 398 天上限
+const LeafValidity = 397 * 24 * time.Hour
+
+// RenewBefore 服务器证书到期前多久重新签发
+const RenewBefore = 30 * 24 * time.Hour
+
+// Names 证书包含的主机名和 IP
+type Names struct {
+	DNS []string
+	IPs []net.IP
+}
+
+// String 获取证书包含的名称列表，用于日志
+func (n Names) String() string {
+	names := append([]string(nil), n.DNS...)
+	for _, ip := range n.IPs {
+		names = append(names, ip.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// CollectNames 收集证书需要包含的名称：localhost、本机主机名（以及 .local 的 mDNS 名称）、本机所有网卡 IP 和 extra 中的主机名或 IP
+// extra 中的主机名可以带端口（会被去掉），可以使用 *.domain 通配
+func CollectNames(extra []string) Names {
+	dns := map[string]bool{"localhost": true}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		dns[host] = true
+		if !strings.Contains(host, ".") {
+			dns[host+".local"] = true
+		}
+	}
+
+	ips := make(map[string]net.IP)
+	for _, ip := range LocalIPs() {
+		ips[ip.String()] = ip
+	}
+	for _, name := range extra {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if host, _, err := net.SplitHostPort(name); err == nil {
+			name = host
+		}
+		name = strings.Trim(name, "[]")
+		if name == "" {
+			continue
+		}
+		if ip := net.ParseIP(name); ip != nil {
+			ips[ip.String()] = ip
+		} else {
+			dns[name] = true
+		}
+	}
+
+	var names Names
+	for name := range dns {
+		names.DNS = append(names.DNS, name)
+	}
+	sort.Strings(names.DNS)
+	for _, ip := range ips {
+		names.IPs = append(names.IPs, ip)
+	}
+	sort.Slice(names.IPs, func(i, j int) bool {
+		return names.IPs[i].String() < names.IPs[j].String()
+	})
+	return names
+}
+
+// LocalIPs 获取本机所有网卡的 IP 地址，包括回环地址，不包括 IPv6 链路本地地址（使用时需要指定网卡，证书中无意义）
+func LocalIPs() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP
+		if (ip.IsLinkLocalUnicast() && ip.To4() == nil) || ip.IsMulticast() || ip.IsUnspecified() {
+			continue
+		}
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+// Leaf 服务器证书的签发结果
+type Leaf struct {
+	Cert   *x509.Certificate
+	Issued bool // 是否新签发（false 表示沿用已有证书）
+}
+
+// EnsureLeaf 确保服务器证书由 ca 签发、未临近过期且包含 names 中的所有名称，否则重新签发并保存
+// force 为 true 时总是重新签发
+func (s *Store) EnsureLeaf(ca *CA, names Names, force bool) (*Leaf, error) {
+	if !force {
+		if cert, err := s.loadLeaf(); err == nil && leafUsable(cert, ca, names) {
+			return &Leaf{Cert: cert}, nil
+		}
+	}
+
+	cert, keyDER, err := issueLeaf(ca, names)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePEM(s.KeyFile(), "PRIVATE KEY", keyDER, 0o600); err != nil {
+		return nil, err
+	}
+	if err := writePEM(s.CertFile(), "CERTIFICATE", cert.Raw, 0o644); err != nil {
+		return nil, err
+	}
+	return &Leaf{Cert: cert, Issued: true}, nil
+}
+
+// IssuedBy 判断证书是否由 ca 证书签发
+func IssuedBy(cert, ca *x509.Certificate) bool {
+	return cert.CheckSignatureFrom(ca) == nil
+}
+
+// loadLeaf 加载已保存的服务器证书，证书和私钥不匹配时返回错误
+func (s *Store) loadLeaf() (*x509.Certificate, error) {
+	pair, err := tls.LoadX509KeyPair(s.CertFile(), s.KeyFile())
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(pair.Certificate[0])
+}
+
+// leafUsable 判断已有的服务器证书是否可以继续使用
+func leafUsable(cert *x509.Certificate, ca *CA, names Names) bool {
+	if !IssuedBy(cert, ca.Cert) || time.Until(cert.NotAfter) < RenewBefore {
+		return false
+	}
+	for _, name := range names.DNS {
+		if !containsString(cert.DNSNames, name) {
+			return false
+		}
+	}
+	for _, ip := range names.IPs {
+		if !containsIP(cert.IPAddresses, ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// issueLeaf 使用 ca 签发服务器证书，返回证书和 PKCS#8 编码的私钥
+func issueLeaf(ca *CA, names Names) (*x509.Certificate, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate certificate key: %v", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commonName := "localhost"
+	if len(names.DNS) > 0 {
+		commonName = names.DNS[0]
+	}
+	now := time.Now()
+	notAfter := now.Add(LeafValidity)
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"serve development certificate"},
+			CommonName:   commonName,
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    names.DNS,
+		IPAddresses: names.IPs,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode certificate key: %v", err)
+	}
+	return cert, keyDER, nil
+}
+
+// containsString 判断列表中是否包含指定字符串（不区分大小写）
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsIP 判断列表中是否包含指定 IP
+func containsIP(list []net.IP, ip net.IP) bool {
+	for _, item := range list {
+		if item.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package autotls
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+	"time"
+)
+
+// testNames 测试使用的证书名称
+var testNames = Names{DNS: []string{"localhost", "serve.local"}, IPs: []net.IP{net.IPv4(127, 0, 0, 1).To4(), net.IPv6loopback}}
+
+// newTestStore 创建临时目录中的存储和本地 CA
+func newTestStore(t *testing.T) (*Store, *CA) {
+	t.Helper()
+	s := &Store{Dir: t.TempDir()}
+	ca, _, err := s.LoadOrCreateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, ca
+}
+
+// writeTestLeaf 使用 ca 签发指定到期时间的服务器证书并保存，模拟临近过期的已有证书
+func writeTestLeaf(t *testing.T, s *Store, ca *CA, names Names, notAfter time.Time) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     names.DNS,
+		IPAddresses:  names.IPs,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writePEM(s.KeyFile(), "PRIVATE KEY", keyDER, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePEM(s.CertFile(), "CERTIFICATE", der, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnsureLeafIssue(t *testing.T) {
+	s, ca := newTestStore(t)
+
+	leaf, err := s.EnsureLeaf(ca, testNames, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !leaf.Issued {
+		t.Error("Issued = false without an existing certificate")
+	}
+	if !IssuedBy(leaf.Cert, ca.Cert) {
+		t.Error("certificate is not issued by the local CA")
+	}
+	for _, name := range testNames.DNS {
+		if err := leaf.Cert.VerifyHostname(name); err != nil {
+			t.Errorf("VerifyHostname(%s): %v", name, err)
+		}
+	}
+	for _, ip := range testNames.IPs {
+		if err := leaf.Cert.VerifyHostname(ip.String()); err != nil {
+			t.Errorf("VerifyHostname(%s): %v", ip, err)
+		}
+	}
+	if validity := leaf.Cert.NotAfter.Sub(leaf.Cert.NotBefore); validity > LeafValidity+time.Hour {
+		t.Errorf("validity = %s, want at most %s", validity, LeafValidity)
+	}
+	assertPerm(t, s.KeyFile(), 0o600)
+	assertPerm(t, s.CertFile(), 0o644)
+}
+
+func TestEnsureLeafReuse(t *testing.T) {
+	tests := []struct {
+		name       string
+		prepare    func(t *testing.T, s *Store, ca *CA) // 准备已有的证书，为 nil 时使用 EnsureLeaf 签发的证书
+		names      Names
+		force      bool
+		wantIssued bool
+	}{
+		{
+			name:  "same names reused",
+			names: testNames,
+		},
+		{
+			name:  "subset of names reused",
+			names: Names{DNS: []string{"LOCALHOST"}, IPs: []net.IP{net.ParseIP("127.0.0.1")}},
+		},
+		{
+			name:       "missing DNS name reissued",
+			names:      Names{DNS: []string{"localhost", "serve.local", "new.example.com"}, IPs: testNames.IPs},
+			wantIssued: true,
+		},
+		{
+			name:       "missing IP reissued",
+			names:      Names{DNS: testNames.DNS, IPs: []net.IP{net.ParseIP("192.168.1.2")}},
+			wantIssued: true,
+		},
+		{
+			name:       "force reissued",
+			names:      testNames,
+			force:      true,
+			wantIssued: true,
+		},
+		{
+			name: "near expiry reissued",
+			prepare: func(t *testing.T, s *Store, ca *CA) {
+				writeTestLeaf(t, s, ca, testNames, time.Now().Add(RenewBefore-time.Hour))
+			},
+			names:      testNames,
+			wantIssued: true,
+		},
+		{
+			name: "outside renewal window reused",
+			prepare: func(t *testing.T, s *Store, ca *CA) {
+				writeTestLeaf(t, s, ca, testNames, time.Now().Add(RenewBefore+24*time.Hour))
+			},
+			names: testNames,
+		},
+		{
+			name: "issued by another CA reissued",
+			prepare: func(t *testing.T, s *Store, _ *CA) {
+				other, err := newCA()
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeTestLeaf(t, s, other, testNames, time.Now().Add(LeafValidity))
+			},
+			names:      testNames,
+			wantIssued: true,
+		},
+		{
+			name: "mismatched key reissued",
+			prepare: func(t *testing.T, s *Store, ca *CA) {
+				writeTestLeaf(t, s, ca, testNames, time.Now().Add(LeafValidity))
+				_, keyDER, err := issueLeaf(ca, testNames)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := writePEM(s.KeyFile(), "PRIVATE KEY", keyDER, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			names:      testNames,
+			wantIssued: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ca := newTestStore(t)
+			if tt.prepare != nil {
+				tt.prepare(t, s, ca)
+			} else if _, err := s.EnsureLeaf(ca, testNames, false); err != nil {
+				t.Fatal(err)
+			}
+			existing, err := LoadCertificate(s.CertFile())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			leaf, err := s.EnsureLeaf(ca, tt.names, tt.force)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if leaf.Issued != tt.wantIssued {
+				t.Errorf("Issued = %t, want %t", leaf.Issued, tt.wantIssued)
+			}
+			if replaced := !leaf.Cert.Equal(existing); replaced != tt.wantIssued {
+				t.Errorf("certificate replaced = %t, want %t", replaced, tt.wantIssued)
+			}
+			saved, err := LoadCertificate(s.CertFile())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !saved.Equal(leaf.Cert) {
+				t.Error("saved certificate differs from the returned one")
+			}
+			if !IssuedBy(leaf.Cert, ca.Cert) {
+				t.Error("certificate is not issued by the local CA")
+			}
+			assertPerm(t, s.KeyFile(), 0o600)
+		})
+	}
+}
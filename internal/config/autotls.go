@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GetAutoTLSDir 获取本地 CA 和证书的保存目录，未设置时为用户配置目录下的 serve/tls（如 ~/.config/serve/tls）
+func (c *Config) GetAutoTLSDir() string {
+	if c.AutoTLSDir != "" {
+		return c.AutoTLSDir
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".serve", "tls")
+	}
+	return filepath.Join(dir, "serve", "tls")
+}
+
+// AutoTLSNames 获取自动 TLS 证书需要额外包含的主机名：auto_tls_hosts、虚拟主机和代理路由中配置的主机
+// localhost、本机主机名和网卡 IP 由签发时自动加入
+func (c *Config) AutoTLSNames() []string {
+	names := append([]string(nil), c.AutoTLSHosts...)
+	add := func(proxyConfigs map[string]*ProxyConfig) {
+		for _, name := range sortedProxyNames(proxyConfigs) {
+			if pc := proxyConfigs[name]; pc != nil {
+				names = append(names, pc.Hosts...)
+			}
+		}
+	}
+	add(c.ProxyConfigs)
+	for _, vhost := range c.VirtualHosts {
+		if vhost != nil {
+			names = append(names, vhost.Hosts...)
+			add(vhost.ProxyConfigs)
+		}
+	}
+	return names
+}
+
+// validateAutoTLS 验证自动 TLS 配置
+func (c *Config) validateAutoTLS() []error {
+	if !c.AutoTLS {
+		if c.AutoTLSDir != "" || len(c.AutoTLSHosts) > 0 {
+			return []error{fmt.Errorf("auto_tls_dir and auto_tls_hosts require auto_tls")}
+		}
+		return nil
+	}
+
+	var errs []error
+	if c.CertFile != "" || c.KeyFile != "" {
+		errs = append(errs, fmt.Errorf("auto_tls cannot be combined with cert_file/key_file"))
+	}
+	for _, host := range c.AutoTLSHosts {
+		if host == "" || strings.ContainsAny(host, "/ ?#@") {
+			errs = append(errs, fmt.Errorf("invalid auto_tls_hosts entry %q: must be a host name or IP address", host))
+		}
+	}
+	return errs
+}
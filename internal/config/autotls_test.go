@@ -0,0 +1,60 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateAutoTLS(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"disabled", Config{}, nil},
+		{"enabled", Config{AutoTLS: true, AutoTLSDir: "/tmp/tls", AutoTLSHosts: []string{"dev.local", "192.168.1.20"}}, nil},
+		{"options without auto_tls", Config{AutoTLSHosts: []string{"dev.local"}}, []string{"auto_tls_dir and auto_tls_hosts require auto_tls"}},
+		{
+			name: "with cert_file and bad host",
+			cfg:  Config{AutoTLS: true, CertFile: "cert.pem", KeyFile: "key.pem", AutoTLSHosts: []string{"dev.local", "http://dev.local/", ""}},
+			want: []string{
+				"auto_tls cannot be combined with cert_file/key_file",
+				`invalid auto_tls_hosts entry "http://dev.local/": must be a host name or IP address`,
+				`invalid auto_tls_hosts entry "": must be a host name or IP address`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, err := range tt.cfg.validateAutoTLS() {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: validateAutoTLS() =\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestAutoTLSNames(t *testing.T) {
+	cfg := Config{
+		AutoTLS:      true,
+		AutoTLSHosts: []string{"dev.local"},
+		ProxyConfigs: map[string]*ProxyConfig{
+			"web": {Hosts: []string{"web.local"}},
+			"api": {Hosts: []string{"api.local"}},
+		},
+		VirtualHosts: []*VirtualHost{{
+			Name:         "www",
+			Hosts:        []string{"www.local", "*.www.local"},
+			ProxyConfigs: map[string]*ProxyConfig{"shop": {Hosts: []string{"shop.local"}}},
+		}},
+	}
+	want := []string{"dev.local", "api.local", "web.local", "www.local", "*.www.local", "shop.local"}
+	if got := cfg.AutoTLSNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("AutoTLSNames() = %v, want %v", got, want)
+	}
+	if !cfg.IsHTTPS() {
+		t.Error("IsHTTPS() = false with auto_tls, want true")
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import "fmt"
+
+// DefaultRewriteMaxBodySize 响应体改写的默认最大字节数（解压后）
+const DefaultRewriteMaxBodySize = 8 << 20
+
+// BodyRewriteConfig 响应体链接改写配置
+// 将 HTML、CSS、JavaScript 中指向代理目标的绝对链接改写为经过对应路径前缀的链接，
+// 使页面通过路径前缀访问时（如 https://lan-ip:8080/www.example.com/）引用的资源仍然经过代理
+type BodyRewriteConfig struct {
+	RootRelative *bool `json:"root_relative,omitempty"` // 是否为 HTML 和 CSS 中以 / 开头的链接加上当前路由的路径前缀，默认 true
+	MaxBodySize  int64 `json:"max_body_size,omitempty"` // 改写的最大响应体字节数（解压后），超过时原样转发，默认 8MiB
+}
+
+// RewritesRootRelative 判断是否改写以 / 开头的链接
+func (b *BodyRewriteConfig) RewritesRootRelative() bool {
+	return b.RootRelative == nil || *b.RootRelative
+}
+
+// GetMaxBodySize 获取改写的最大响应体字节数，未设置时使用默认值
+func (b *BodyRewriteConfig) GetMaxBodySize() int64 {
+	if b.MaxBodySize == 0 {
+		return DefaultRewriteMaxBodySize
+	}
+	return b.MaxBodySize
+}
+
+// validate 验证响应体改写配置，name 为路由名称
+func (b *BodyRewriteConfig) validate(name string) []error {
+	if b == nil {
+		return nil
+	}
+	if b.MaxBodySize < 0 {
+		return []error{fmt.Errorf("proxy %s: rewrite_body.max_body_size must not be negative, got %d", name, b.MaxBodySize)}
+	}
+	return nil
+}
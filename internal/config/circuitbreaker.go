@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// 熔断器的默认值
+const (
+	DefaultConsecutiveFailures = 5
+	DefaultCircuitCooldown     = Duration(30 * time.Second)
+	DefaultHalfOpenRequests    = 1
+)
+
+// CircuitBreakerConfig 熔断器配置（被动异常检测）
+// 根据实际转发结果统计每个上游目标的连续失败次数（5xx 响应、连接错误和超时），
+// 达到阈值后熔断：冷却期内不再向该目标转发，所有目标都熔断时直接返回 503；
+// 冷却期结束后进入半开状态，放行少量试探请求，成功则恢复，失败则重新熔断
+type CircuitBreakerConfig struct {
+	ConsecutiveFailures int      `json:"consecutive_failures,omitempty"` // 连续失败多少次后熔断，默认 5
+	Cooldown            Duration `json:"cooldown,omitempty"`             // 熔断冷却时间，默认 30s
+	HalfOpenRequests    int      `json:"half_open_requests,omitempty"`   // 半开状态同时放行的试探请求数，默认 1
+	FailureStatus       []int    `json:"failure_status,omitempty"`       // 视为失败的响应状态码，为空时所有 5xx 视为失败
+}
+
+// WithDefaults 返回填充默认值后的熔断器配置
+func (c *CircuitBreakerConfig) WithDefaults() CircuitBreakerConfig {
+	settings := *c
+	if settings.ConsecutiveFailures == 0 {
+		settings.ConsecutiveFailures = DefaultConsecutiveFailures
+	}
+	if settings.Cooldown == 0 {
+		settings.Cooldown = DefaultCircuitCooldown
+	}
+	if settings.HalfOpenRequests == 0 {
+		settings.HalfOpenRequests = DefaultHalfOpenRequests
+	}
+	return settings
+}
+
+// IsFailureStatus 判断响应状态码是否视为失败
+func (c *CircuitBreakerConfig) IsFailureStatus(status int) bool {
+	if len(c.FailureStatus) == 0 {
+		return status >= http.StatusInternalServerError
+	}
+	for _, failure := range c.FailureStatus {
+		if status == failure {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 验证熔断器配置，name 为路由名称
+func (c *CircuitBreakerConfig) validate(name string) []error {
+	if c == nil {
+		return nil
+	}
+
+	var errs []error
+	if c.ConsecutiveFailures < 0 {
+		errs = append(errs, fmt.Errorf("proxy %s: circuit_breaker.consecutive_failures must not be negative, got %d", name, c.ConsecutiveFailures))
+	}
+	if c.Cooldown < 0 {
+		errs = append(errs, fmt.Errorf("proxy %s: circuit_breaker.cooldown must not be negative, got %s", name, c.Cooldown))
+	}
+	if c.HalfOpenRequests < 0 {
+		errs = append(errs, fmt.Errorf("proxy %s: circuit_breaker.half_open_requests must not be negative, got %d", name, c.HalfOpenRequests))
+	}
+	for _, status := range c.FailureStatus {
+		if status < 100 || status > 599 {
+			errs = append(errs, fmt.Errorf("proxy %s: invalid status %d in circuit_breaker.failure_status", name, status))
+		}
+	}
+	return errs
+}
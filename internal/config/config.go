@@ -2,9 +2,14 @@ package config
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
@@ -16,6 +21,17 @@ type Config struct {
 	CertFile string `json:"cert_file"` // SSL 证书文件路径
 	KeyFile  string `json:"key_file"`  // SSL 私钥文件路径
 
+	// 自动 TLS：创建本地 CA 并签发覆盖本机所有 IP、localhost 和配置的主机名的证书，不能与 cert_file/key_file 同时使用
+	AutoTLS      bool     `json:"auto_tls,omitempty"`
+	AutoTLSDir   string   `json:"auto_tls_dir,omitempty"`   // 本地 CA 和证书的保存目录，默认为用户配置目录下的 serve/tls
+	AutoTLSHosts []string `json:"auto_tls_hosts,omitempty"` // 证书额外包含的主机名或 IP
+
+	// 监听器配置，为空时根据 host 和证书配置生成单个监听器
+	Listeners []*Listener `json:"listeners,omitempty"`
+
+	// HTTPS 监听器的 TLS 协议版本和加密套件，未设置时使用 legacy 档位，监听器可以单独设置
+	TLS *TLSProfileConfig `json:"tls,omitempty"`
+
 	// 日志配置
 	LogLevel string `json:"log_level"` // 日志等级：debug, info, warn, error
 
@@ -23,14 +39,188 @@ type Config struct {
 	StaticDir string `json:"static_dir"` // 静态文件目录路径
 
 	// 代理配置
-	ProxyConfigs map[string]*ProxyConfig `json:"proxy_configs"` // 代理配置映射，key 为目标域名
+	ProxyConfigs map[string]*ProxyConfig `json:"proxy_configs"` // 代理配置映射，key 为路由名称，未设置 path_prefix 时同时作为路径前缀
+
+	// 状态接口路径，如 /_serve/status，以 JSON 输出代理上游的负载均衡和健康检查状态，为空时不启用
+	StatusPath string `json:"status_path,omitempty"`
+
+	// 允许访问状态接口的客户端 IP 或 CIDR，如 10.0.0.0/8；本机回环地址始终允许
+	StatusAllow []string `json:"status_allow,omitempty"`
+
+	// 虚拟主机配置，按 Host 头匹配，未匹配时使用默认虚拟主机或以上顶层配置
+	VirtualHosts []*VirtualHost `json:"virtual_hosts,omitempty"`
+
+	// 各配置项的来源记录，key 为配置项名称
+	sources map[string]string
 }
 
 // ProxyConfig 代理配置结构
 type ProxyConfig struct {
+	// 路由匹配
+	PathPrefix string   `json:"path_prefix,omitempty"` // 路径前缀，可以包含多段（如 api/v2），为空时使用路由名称，为 / 时匹配所有路径
+	Hosts      []string `json:"hosts,omitempty"`       // 匹配的 Host 头，支持 host:port、host（任意端口）和 *.domain 通配，为空时匹配所有主机
+	Methods    []string `json:"methods,omitempty"`     // 匹配的请求方法，为空时匹配所有方法
+	Priority   int      `json:"priority,omitempty"`    // 优先级，数值越大越优先，相同优先级按匹配精确程度排序
+
+	// 未匹配任何路由且没有对应静态文件的请求，按 Referer 或 Cookie 判断来源页面属于此路由时转发到此路由（路径不变）
+	// 用于页面脚本在运行时构造的以 / 开头的请求（如 fetch('/api/x')）
+	StickyOrigin bool `json:"sticky_origin,omitempty"`
+
 	TargetDomain string `json:"target_domain"` // 目标域名，如果为空则使用路径第一段作为目标域名
 	UseHTTPS     bool   `json:"use_https"`     // 是否使用 HTTPS 协议
 	Insecure     bool   `json:"insecure"`      // 是否跳过 SSL 证书验证（仅在 use_https 为 true 时生效）
+
+	// 上游 TLS：私有 CA、SNI、证书固定和客户端证书（mTLS），未设置时使用系统根证书验证
+	TLS *UpstreamTLSConfig `json:"tls,omitempty"`
+
+	// 目标端口和路径
+	TargetPort  int    `json:"target_port,omitempty"`  // 目标端口，为 0 时使用协议默认端口
+	TargetPath  string `json:"target_path,omitempty"`  // 目标基础路径，转发时拼接在请求路径之前，如 /v2
+	StripPrefix *bool  `json:"strip_prefix,omitempty"` // 是否移除路径前缀，未设置时 target_domain 为空则移除，否则保留
+
+	// 路径重写
+	ReplacePrefix string         `json:"replace_prefix,omitempty"` // 将路径前缀替换为指定前缀，如 /v2，设置后忽略 strip_prefix
+	Rewrites      []*RewriteRule `json:"rewrites,omitempty"`       // 按顺序匹配的正则重写规则，第一条匹配的规则生效，优先于前缀处理
+
+	// Host 头和转发头
+	PreserveHost bool             `json:"preserve_host,omitempty"` // 是否将原始请求的 Host 头转发给上游，默认使用上游目标地址
+	Forwarded    *ForwardedConfig `json:"forwarded,omitempty"`     // 转发头（X-Forwarded-*、Forwarded）配置，未设置时只追加 X-Forwarded-For
+
+	// 请求头和响应头修改
+	RequestHeaders  *HeaderRules `json:"request_headers,omitempty"`  // 转发到上游的请求头修改规则
+	ResponseHeaders *HeaderRules `json:"response_headers,omitempty"` // 返回给客户端的上游响应头修改规则
+
+	// 响应链接改写，未设置时不改写
+	RewriteBody    *BodyRewriteConfig    `json:"rewrite_body,omitempty"`    // 改写 HTML、CSS、JavaScript 响应体中的链接
+	ReverseRewrite *ReverseRewriteConfig `json:"reverse_rewrite,omitempty"` // 改写 Location、Refresh、Set-Cookie 等响应头
+
+	// 多个上游目标和负载均衡，配置 targets 时不能同时配置 target_domain 和 target_port
+	Targets      []*UpstreamTarget   `json:"targets,omitempty"`       // 上游目标列表，为空时使用 target_domain 和 target_port 作为单个目标
+	LoadBalancer *LoadBalancerConfig `json:"load_balancer,omitempty"` // 负载均衡策略，未设置时使用加权轮询
+
+	// 主动健康检查，未设置时不检查，所有目标始终参与负载均衡
+	HealthCheck *HealthCheckConfig `json:"health_check,omitempty"`
+
+	// 熔断器，根据实际转发结果熔断连续失败的目标，未设置时不熔断
+	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+
+	// 失败重试，未设置时不重试
+	Retry *RetryConfig `json:"retry,omitempty"`
+
+	// 上游连接
+	Transport *TransportConfig `json:"transport,omitempty"` // 连接池、keep-alive 和超时设置，未设置时使用默认值
+}
+
+// RoutePrefix 获取路由的路径前缀（不含首尾 "/"，匹配所有路径时为空字符串）
+// 未设置 path_prefix 时使用路由名称
+func (p *ProxyConfig) RoutePrefix(name string) string {
+	if p.PathPrefix != "" {
+		return strings.Trim(p.PathPrefix, "/")
+	}
+	return strings.Trim(name, "/")
+}
+
+// TargetAddr 获取转发目标地址（host[:port]），用于目标 URL 和 Host 头
+// 未配置目标域名时使用路径前缀作为目标域名
+func (p *ProxyConfig) TargetAddr(pathPrefix string) string {
+	domain := p.TargetDomain
+	if domain == "" {
+		domain = pathPrefix
+	}
+	if p.TargetPort == 0 {
+		// IPv6 地址需要加方括号
+		if ip := net.ParseIP(domain); ip != nil && ip.To4() == nil {
+			return "[" + domain + "]"
+		}
+		return domain
+	}
+	return net.JoinHostPort(domain, strconv.Itoa(p.TargetPort))
+}
+
+// ShouldStripPrefix 判断转发时是否移除路径前缀
+// 未显式设置时沿用原有行为：未配置目标域名和上游目标（使用路径前缀作为域名）时移除，否则保留
+func (p *ProxyConfig) ShouldStripPrefix() bool {
+	if p.StripPrefix != nil {
+		return *p.StripPrefix
+	}
+	return p.TargetDomain == "" && len(p.Targets) == 0
+}
+
+// validate 验证代理配置的有效性，并规范化目标基础路径
+// name 为路由名称（代理配置映射的 key）
+func (p *ProxyConfig) validate(name string) []error {
+	var errs []error
+	pathPrefix := p.RoutePrefix(name)
+
+	// 未配置目标域名时使用路径前缀作为域名，此时路径前缀必须是单段的域名
+	if p.TargetDomain == "" && len(p.Targets) == 0 && (pathPrefix == "" || strings.Contains(pathPrefix, "/")) {
+		errs = append(errs, fmt.Errorf("proxy %s: target_domain is required when path prefix %q is not a single path segment", name, "/"+pathPrefix))
+	}
+	for _, host := range p.Hosts {
+		if strings.TrimSpace(host) == "" || strings.ContainsAny(host, "/ ") {
+			errs = append(errs, fmt.Errorf("proxy %s: invalid host %q in hosts", name, host))
+		}
+	}
+	for _, method := range p.Methods {
+		if !isValidMethod(method) {
+			errs = append(errs, fmt.Errorf("proxy %s: invalid method %q in methods", name, method))
+		}
+	}
+	if p.TargetPort < 0 || p.TargetPort > 65535 {
+		errs = append(errs, fmt.Errorf("proxy %s: invalid target_port %d (must be between 1 and 65535)", name, p.TargetPort))
+	}
+	if strings.ContainsAny(p.TargetDomain, "/?#") || strings.Contains(p.TargetDomain, "://") {
+		errs = append(errs, fmt.Errorf("proxy %s: target_domain %q must be a host name without scheme or path (use target_path for the base path)", name, p.TargetDomain))
+	}
+	if p.TargetPort != 0 {
+		if _, _, err := net.SplitHostPort(p.TargetDomain); err == nil {
+			errs = append(errs, fmt.Errorf("proxy %s: target_domain %q already contains a port, conflicting with target_port %d", name, p.TargetDomain, p.TargetPort))
+		}
+	}
+	if strings.ContainsAny(p.ReplacePrefix, "?#") {
+		errs = append(errs, fmt.Errorf("proxy %s: replace_prefix %q must not contain query or fragment", name, p.ReplacePrefix))
+	}
+	for i, rule := range p.Rewrites {
+		if err := rule.compile(); err != nil {
+			errs = append(errs, fmt.Errorf("proxy %s: rewrites[%d]: %v", name, i, err))
+		}
+	}
+	errs = append(errs, p.Forwarded.validate(name)...)
+	errs = append(errs, p.RequestHeaders.validate(name, "request_headers")...)
+	errs = append(errs, p.ResponseHeaders.validate(name, "response_headers")...)
+	errs = append(errs, p.RewriteBody.validate(name)...)
+	errs = append(errs, p.ReverseRewrite.validate(name)...)
+	errs = append(errs, p.validateTargets(name)...)
+	errs = append(errs, p.HealthCheck.validate(name)...)
+	errs = append(errs, p.CircuitBreaker.validate(name)...)
+	errs = append(errs, p.Retry.validate(name)...)
+	errs = append(errs, p.TLS.validate(name, p.UseHTTPS)...)
+	errs = append(errs, p.Transport.validate(name)...)
+	if strings.ContainsAny(p.TargetPath, "?#") {
+		errs = append(errs, fmt.Errorf("proxy %s: target_path %q must not contain query or fragment", name, p.TargetPath))
+	} else if p.TargetPath != "" && !strings.HasPrefix(p.TargetPath, "/") {
+		p.TargetPath = "/" + p.TargetPath
+	}
+
+	return errs
+}
+
+// isValidMethod 判断是否为合法的 HTTP 方法名（要求大写）
+func isValidMethod(method string) bool {
+	return isToken(method) && strings.ToUpper(method) == method
+}
+
+// isToken 判断是否为合法的 HTTP token（RFC 7230），用于校验方法名和头名称
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune("()<>@,;:\\\"/[]?={}", r) {
+			return false
+		}
+	}
+	return true
 }
 
 // LoadConfig 加载配置
@@ -44,7 +234,10 @@ func LoadConfig() *Config {
 }
 
 // Validate 验证配置的有效性
+// 会检查所有配置项并返回全部错误（通过 errors.Join 合并），可以使用 SplitErrors 拆分
 func (c *Config) Validate() error {
+	var errs []error
+
 	// 验证日志等级
 	validLevels := map[string]bool{
 		"debug": true,
@@ -53,51 +246,143 @@ func (c *Config) Validate() error {
 		"error": true,
 	}
 	if !validLevels[c.LogLevel] {
-		return fmt.Errorf("invalid log level: %s, must be one of: debug, info, warn, error", c.LogLevel)
+		errs = append(errs, fmt.Errorf("invalid log level: %s, must be one of: debug, info, warn, error", c.LogLevel))
 	}
 
 	// 如果配置了证书文件，验证文件是否存在
 	if c.CertFile != "" || c.KeyFile != "" {
-		if c.CertFile == "" || c.KeyFile == "" {
-			return fmt.Errorf("both cert_file and key_file must be provided for HTTPS")
+		if err := validateCertPair(c.CertFile, c.KeyFile); err != nil {
+			errs = append(errs, err)
 		}
+	}
 
-		// 检查证书文件是否存在
-		if _, err := os.Stat(c.CertFile); os.IsNotExist(err) {
-			return fmt.Errorf("certificate file not found: %s", c.CertFile)
-		}
-		if _, err := os.Stat(c.KeyFile); os.IsNotExist(err) {
-			return fmt.Errorf("key file not found: %s", c.KeyFile)
-		}
+	// 验证 TLS 档位和自动 TLS
+	errs = append(errs, c.TLS.validate("tls")...)
+	errs = append(errs, c.validateAutoTLS()...)
 
-		// 验证证书和私钥是否匹配
-		if _, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile); err != nil {
-			return fmt.Errorf("failed to load certificate pair: %v", err)
+	// 验证代理配置，按路由名称排序保证错误顺序稳定
+	for _, name := range sortedProxyNames(c.ProxyConfigs) {
+		if proxyConfig := c.ProxyConfigs[name]; proxyConfig == nil {
+			errs = append(errs, fmt.Errorf("proxy %s: empty proxy config", name))
+		} else {
+			errs = append(errs, proxyConfig.validate(name)...)
 		}
 	}
 
 	// 验证静态文件目录
 	if c.StaticDir != "" {
-		absPath, err := filepath.Abs(c.StaticDir)
+		absPath, err := validateStaticDir(c.StaticDir)
 		if err != nil {
-			return fmt.Errorf("failed to resolve static directory path: %v", err)
+			errs = append(errs, err)
+		} else {
+			c.StaticDir = absPath
+		}
+	}
+
+	// 验证状态接口路径
+	if c.StatusPath != "" && (!strings.HasPrefix(c.StatusPath, "/") || strings.ContainsAny(c.StatusPath, "?# ")) {
+		errs = append(errs, fmt.Errorf("invalid status_path %q: must be an absolute URL path like /_serve/status", c.StatusPath))
+	}
+	for _, allow := range c.StatusAllow {
+		if _, err := ParseIPPrefix(allow); err != nil {
+			errs = append(errs, fmt.Errorf("invalid address %q in status_allow (expected an IP or CIDR such as 10.0.0.0/8)", allow))
 		}
-		c.StaticDir = absPath
+	}
 
-		// 检查目录是否存在
-		if info, err := os.Stat(c.StaticDir); os.IsNotExist(err) {
-			return fmt.Errorf("static directory not found: %s", c.StaticDir)
-		} else if !info.IsDir() {
-			return fmt.Errorf("static path is not a directory: %s", c.StaticDir)
+	// 验证虚拟主机
+	errs = append(errs, c.validateVirtualHosts()...)
+
+	// 验证监听器
+	errs = append(errs, c.validateListeners()...)
+
+	return errors.Join(errs...)
+}
+
+// SplitErrors 将 errors.Join 合并的错误拆分为错误列表
+func SplitErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, SplitErrors(e)...)
 		}
+		return errs
+	}
+	return []error{err}
+}
+
+// validateCertPair 验证证书和私钥文件存在且相互匹配
+func validateCertPair(certFile, keyFile string) error {
+	if certFile == "" || keyFile == "" {
+		return fmt.Errorf("both cert_file and key_file must be provided for HTTPS")
+	}
+
+	// 检查证书文件是否存在
+	if _, err := os.Stat(certFile); os.IsNotExist(err) {
+		return fmt.Errorf("certificate file not found: %s", certFile)
+	}
+	if _, err := os.Stat(keyFile); os.IsNotExist(err) {
+		return fmt.Errorf("key file not found: %s", keyFile)
 	}
 
+	// 验证证书和私钥是否匹配
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return fmt.Errorf("failed to load certificate pair: %v", err)
+	}
 	return nil
 }
 
-// IsHTTPS 判断是否启用 HTTPS
+// ParseIPPrefix 解析 IP 或 CIDR，单个 IP 视为只包含该地址的网段
+// IPv4 映射的 IPv6 地址按 IPv4 处理
+func ParseIPPrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// validateStaticDir 验证静态文件目录存在，返回绝对路径
+func validateStaticDir(dir string) (string, error) {
+	absPath, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve static directory path: %v", err)
+	}
+
+	// 检查目录是否存在
+	if info, err := os.Stat(absPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("static directory not found: %s", absPath)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to access static directory: %v", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("static path is not a directory: %s", absPath)
+	}
+	return absPath, nil
+}
+
+// IsHTTPS 判断是否配置了可用于 HTTPS 的证书
+// 启用了自动 TLS、顶层或任意虚拟主机配置了证书时返回 true，未配置 listeners 时据此决定是否启用 HTTPS
 func (c *Config) IsHTTPS() bool {
-	return c.CertFile != "" && c.KeyFile != ""
+	if c.AutoTLS || (c.CertFile != "" && c.KeyFile != "") {
+		return true
+	}
+	for _, vhost := range c.VirtualHosts {
+		if vhost != nil && vhost.HasCertificate() {
+			return true
+		}
+	}
+	return false
 }
 
 // GetLogLevel 获取日志等级
@@ -114,21 +399,10 @@ func (c *Config) GetLogLevel() logrus.Level {
 	}
 }
 
-// AddProxyConfig 添加代理配置
-// pathPrefix: 路径前缀（用于匹配请求路径第一段）
-// targetDomain: 目标域名，如果为空则使用路径第一段作为目标域名
-// useHTTPS: 是否使用 HTTPS 协议
-// insecure: 是否跳过 SSL 证书验证
-func (c *Config) AddProxyConfig(pathPrefix, targetDomain string, useHTTPS, insecure bool) {
-	c.ProxyConfigs[pathPrefix] = &ProxyConfig{
-		TargetDomain: targetDomain,
-		UseHTTPS:     useHTTPS,
-		Insecure:     insecure,
+// SetProxyConfig 设置指定路由名称的代理配置，已存在时覆盖
+func (c *Config) SetProxyConfig(name string, proxyConfig *ProxyConfig) {
+	if c.ProxyConfigs == nil {
+		c.ProxyConfigs = make(map[string]*ProxyConfig)
 	}
-}
-
-// GetProxyConfig 获取指定路径前缀的代理配置
-func (c *Config) GetProxyConfig(pathPrefix string) (*ProxyConfig, bool) {
-	config, exists := c.ProxyConfigs[pathPrefix]
-	return config, exists
+	c.ProxyConfigs[name] = proxyConfig
 }
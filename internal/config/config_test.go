@@ -0,0 +1,55 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIPPrefix(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8"},
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{" 192.168.1.20 ", "192.168.1.20/32"},
+		{"::ffff:192.168.1.20", "192.168.1.20/32"},
+		{"fd00::/8", "fd00::/8"},
+		{"::1", "::1/128"},
+	}
+	for _, tt := range tests {
+		prefix, err := ParseIPPrefix(tt.in)
+		if err != nil {
+			t.Errorf("ParseIPPrefix(%q): %v", tt.in, err)
+			continue
+		}
+		if got := prefix.String(); got != tt.want {
+			t.Errorf("ParseIPPrefix(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "localhost", "10.0.0.0/33", "10.0.0"} {
+		if _, err := ParseIPPrefix(in); err == nil {
+			t.Errorf("ParseIPPrefix(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestValidateStatus(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.StaticDir = ""
+	cfg.StatusPath = "_serve/status"
+	cfg.StatusAllow = []string{"10.0.0.0/8", "office", "::1"}
+
+	var got []string
+	for _, err := range SplitErrors(cfg.Validate()) {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		`invalid status_path "_serve/status": must be an absolute URL path like /_serve/status`,
+		`invalid address "office" in status_allow (expected an IP or CIDR such as 10.0.0.0/8)`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() errors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Duration 配置中的时间间隔，使用 Go 的时间格式书写，如 30s、1m30s、500ms
+// 同时实现 encoding.TextMarshaler/TextUnmarshaler，配置文件、环境变量和命令行使用相同的格式
+type Duration time.Duration
+
+// Duration 转换为 time.Duration
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// String 格式化时间间隔
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalText 实现 encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText 实现 encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// ParseDuration 解析时间间隔，如 30s、1m30s、500ms
+func ParseDuration(s string) (Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q (expected a value like 500ms, 30s or 1m30s)", s)
+	}
+	return Duration(d), nil
+}
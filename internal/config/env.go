@@ -0,0 +1,242 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix 环境变量前缀
+const EnvPrefix = "SERVE_"
+
+// 特殊环境变量
+const (
+	EnvConfigFile = EnvPrefix + "CONFIG" // 配置文件路径
+	EnvProxy      = EnvPrefix + "PROXY"  // 代理配置列表，多个配置用换行或逗号分隔
+)
+
+// proxySpecTargetPattern URL 格式代理配置中 "=" 之后的目标地址开头
+var proxySpecTargetPattern = regexp.MustCompile(`^\s*[A-Za-z][A-Za-z0-9+.-]*://`)
+
+// EnvName 获取配置字段对应的环境变量名，如 log_level 对应 SERVE_LOG_LEVEL
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// ApplyEnv 使用环境变量覆盖配置，返回所有解析失败的环境变量错误
+// environ 格式与 os.Environ() 一致（KEY=VALUE）
+// 每个带 json 标签的字段对应一个 SERVE_{大写字段名} 环境变量：
+//   - 字符串、布尔、数值以及实现了 encoding.TextUnmarshaler 的字段直接解析
+//   - 其余复合字段（映射、列表、结构体）使用 JSON 格式，如 SERVE_PROXY_CONFIGS='{"api":{"use_https":true}}'
+func (c *Config) ApplyEnv(environ []string) error {
+	env := parseEnviron(environ)
+
+	var errs []error
+	value := reflect.ValueOf(c).Elem()
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key := jsonFieldName(field)
+		if key == "" {
+			continue
+		}
+
+		name := EnvName(key)
+		raw, ok := env[name]
+		if !ok {
+			continue
+		}
+
+		if err := setFieldFromEnv(value.Field(i), raw); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for %s: %v", name, err))
+			continue
+		}
+		c.SetSource(key, SourceEnv+":"+name)
+
+		// 通过 JSON 设置的代理配置逐条记录来源（与配置文件合并，仅记录环境变量中出现的路由）
+		if key == "proxy_configs" {
+			var proxies map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(raw), &proxies); err == nil {
+				for route := range proxies {
+					c.SetProxySource(route, SourceEnv+":"+name)
+				}
+			}
+		}
+	}
+
+	if c.ProxyConfigs == nil {
+		c.ProxyConfigs = make(map[string]*ProxyConfig)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ProxySpec 来自环境变量的代理配置字符串
+type ProxySpec struct {
+	Name  string // 环境变量名
+	Value string // 代理配置字符串
+}
+
+// ProxySpecsFromEnv 从环境变量中读取代理配置字符串
+// 支持两种形式，列表形式在前，索引形式按索引升序排列在后：
+//   - SERVE_PROXY：多个配置用换行或逗号分隔，配置内容中的逗号不会拆分配置（见 splitProxyList）
+//   - SERVE_PROXY_0、SERVE_PROXY_1 ...：每个变量一个配置，不做任何拆分，是配置中包含逗号时最可靠的形式
+func ProxySpecsFromEnv(environ []string) []ProxySpec {
+	env := parseEnviron(environ)
+
+	var specs []ProxySpec
+	if list, ok := env[EnvProxy]; ok {
+		for _, item := range splitProxyList(list) {
+			if item = strings.TrimSpace(item); item != "" {
+				specs = append(specs, ProxySpec{Name: EnvProxy, Value: item})
+			}
+		}
+	}
+
+	type indexedSpec struct {
+		index int
+		spec  ProxySpec
+	}
+	var indexed []indexedSpec
+	for name, value := range env {
+		suffix, ok := strings.CutPrefix(name, EnvProxy+"_")
+		if !ok {
+			continue
+		}
+		index, err := strconv.Atoi(suffix)
+		if err != nil || index < 0 {
+			continue
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			indexed = append(indexed, indexedSpec{index: index, spec: ProxySpec{Name: name, Value: value}})
+		}
+	}
+	sort.Slice(indexed, func(i, j int) bool {
+		return indexed[i].index < indexed[j].index
+	})
+	for _, item := range indexed {
+		specs = append(specs, item.spec)
+	}
+
+	return specs
+}
+
+// splitProxyList 拆分 SERVE_PROXY 中的代理配置列表
+// 换行始终分隔配置；逗号只在其后是一个新配置的开头时分隔（见 startsProxySpec），其余逗号保留在所属的配置中
+func splitProxyList(list string) []string {
+	var specs []string
+	for _, line := range strings.Split(list, "\n") {
+		var current []string
+		for _, part := range strings.Split(line, ",") {
+			if strings.TrimSpace(part) == "" || startsProxySpec(part) {
+				if len(current) > 0 {
+					specs = append(specs, strings.Join(current, ","))
+				}
+				current = nil
+			}
+			if strings.TrimSpace(part) != "" {
+				current = append(current, part)
+			}
+		}
+		if len(current) > 0 {
+			specs = append(specs, strings.Join(current, ","))
+		}
+	}
+	return specs
+}
+
+// startsProxySpec 判断逗号之后的内容是否是一个新代理配置的开头
+//   - URL 格式：path_prefix=scheme://...，path_prefix 中不能包含 ?、& 和 :（排除选项值中的 key=value）
+//   - 旧格式：不包含 "="，且以 :true 或 :false 两段结尾
+func startsProxySpec(s string) bool {
+	s = strings.TrimSpace(s)
+	if prefix, target, ok := strings.Cut(s, "="); ok {
+		return !strings.ContainsAny(prefix, "?&:") && proxySpecTargetPattern.MatchString(target)
+	}
+	parts := strings.Split(s, ":")
+	n := len(parts)
+	return n >= 4 && isLegacyBool(parts[n-2]) && isLegacyBool(parts[n-1])
+}
+
+// isLegacyBool 判断是否是旧格式代理配置中的布尔值
+func isLegacyBool(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "true" || s == "false"
+}
+
+// LookupEnv 在 environ 中查找环境变量
+func LookupEnv(environ []string, name string) (string, bool) {
+	value, ok := parseEnviron(environ)[name]
+	return value, ok
+}
+
+// parseEnviron 将 KEY=VALUE 列表解析为映射，仅保留 SERVE_ 前缀的变量
+func parseEnviron(environ []string) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		env[name] = value
+	}
+	return env
+}
+
+// jsonFieldName 获取字段的 json 名称，忽略的字段返回空字符串
+func jsonFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// setFieldFromEnv 将环境变量字符串解析并写入字段
+func setFieldFromEnv(field reflect.Value, raw string) error {
+	// 自定义文本格式的类型（如时长）使用其自身的解析逻辑
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return decodeJSON([]byte(raw), field.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProxySpecsFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		want    []ProxySpec
+	}{
+		{
+			name:    "specs separated by commas",
+			environ: []string{"SERVE_PROXY=api:api.example.com:true:false, www:www.example.com:false:false"},
+			want: []ProxySpec{
+				{Name: EnvProxy, Value: "api:api.example.com:true:false"},
+				{Name: EnvProxy, Value: "www:www.example.com:false:false"},
+			},
+		},
+		{
+			name:    "url and legacy specs mixed",
+			environ: []string{"SERVE_PROXY=/api=http://localhost:3000?insecure,api2:localhost:3001:false:false,/www=https://"},
+			want: []ProxySpec{
+				{Name: EnvProxy, Value: "/api=http://localhost:3000?insecure"},
+				{Name: EnvProxy, Value: "api2:localhost:3001:false:false"},
+				{Name: EnvProxy, Value: "/www=https://"},
+			},
+		},
+		{
+			name:    "comma followed by key=value kept",
+			environ: []string{"SERVE_PROXY=/api=http://a?x=1,y=2,/b=http://b"},
+			want: []ProxySpec{
+				{Name: EnvProxy, Value: "/api=http://a?x=1,y=2"},
+				{Name: EnvProxy, Value: "/b=http://b"},
+			},
+		},
+		{
+			name:    "commas in option values kept",
+			environ: []string{"SERVE_PROXY=/api=http://10.0.0.1:8080?method=GET,POST&host=a.local,[::1]:8080,/www=https://www.example.com"},
+			want: []ProxySpec{
+				{Name: EnvProxy, Value: "/api=http://10.0.0.1:8080?method=GET,POST&host=a.local,[::1]:8080"},
+				{Name: EnvProxy, Value: "/www=https://www.example.com"},
+			},
+		},
+		{
+			name:    "newlines always separate",
+			environ: []string{"SERVE_PROXY=api:a:true:false\n\n  www:w:false:false  \n"},
+			want: []ProxySpec{
+				{Name: EnvProxy, Value: "api:a:true:false"},
+				{Name: EnvProxy, Value: "www:w:false:false"},
+			},
+		},
+		{
+			name:    "comma not followed by a new spec kept",
+			environ: []string{"SERVE_PROXY=api:a:true:false,x\nwww:w:false:false"},
+			want: []ProxySpec{
+				{Name: EnvProxy, Value: "api:a:true:false,x"},
+				{Name: EnvProxy, Value: "www:w:false:false"},
+			},
+		},
+		{
+			name:    "empty items skipped",
+			environ: []string{"SERVE_PROXY=,api:a:true:false,,www:w:false:false,"},
+			want: []ProxySpec{
+				{Name: EnvProxy, Value: "api:a:true:false"},
+				{Name: EnvProxy, Value: "www:w:false:false"},
+			},
+		},
+		{
+			name: "indexed specs kept whole and sorted after the list",
+			environ: []string{
+				"SERVE_PROXY_10=c:c:false:false",
+				"SERVE_PROXY_2=b:b:false:false,x:x:false:false",
+				"SERVE_PROXY=api:a:true:false",
+				"SERVE_PROXY_X=ignored:x:false:false",
+				"SERVE_PROXY_3=  ",
+			},
+			want: []ProxySpec{
+				{Name: EnvProxy, Value: "api:a:true:false"},
+				{Name: "SERVE_PROXY_2", Value: "b:b:false:false,x:x:false:false"},
+				{Name: "SERVE_PROXY_10", Value: "c:c:false:false"},
+			},
+		},
+		{
+			name:    "no proxy variables",
+			environ: []string{"SERVE_HOST=:9090", "PROXY=api:a:true:false"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProxySpecsFromEnv(tt.environ); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProxySpecsFromEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxySpecsFromEnvParse(t *testing.T) {
+	// 拆分后的每个配置都能完整解析，选项值中的逗号没有丢失
+	specs := ProxySpecsFromEnv([]string{"SERVE_PROXY=/api=http://10.0.0.1:8080?method=GET,POST&retry_on=5xx,timeout,/www=https://www.example.com?host=a.local,b.local"})
+	if len(specs) != 2 {
+		t.Fatalf("got %d specs, want 2: %q", len(specs), specs)
+	}
+	_, api, err := ParseProxySpec(specs[0].Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := api.Methods; !reflect.DeepEqual(got, []string{"GET", "POST"}) {
+		t.Errorf("methods = %q, want [GET POST]", got)
+	}
+	if got := api.Retry.RetryOn; !reflect.DeepEqual(got, []string{"5xx", "timeout"}) {
+		t.Errorf("retry_on = %q, want [5xx timeout]", got)
+	}
+	_, www, err := ParseProxySpec(specs[1].Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := www.Hosts; !reflect.DeepEqual(got, []string{"a.local", "b.local"}) {
+		t.Errorf("hosts = %q, want [a.local b.local]", got)
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFile 从配置文件加载配置
+// 先填充默认值，再用配置文件中的值覆盖默认值
+// 根据文件扩展名选择格式：.yaml/.yml、.json、.toml
+func LoadConfigFile(path string) (*Config, error) {
+	cfg := LoadConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	raw, err := decodeFile(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	// 空文件直接使用默认值
+	if raw != nil {
+		jsonData, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+		}
+		if err := decodeJSON(jsonData, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+		}
+	}
+
+	// 记录配置文件中出现的配置项来源
+	source := SourceFile + ":" + path
+	for key := range raw {
+		cfg.SetSource(key, source)
+	}
+	if proxies, ok := raw["proxy_configs"].(map[string]interface{}); ok {
+		for name := range proxies {
+			cfg.SetProxySource(name, source)
+		}
+	}
+
+	// 配置文件中可能显式写了 null，保证映射可用
+	if cfg.ProxyConfigs == nil {
+		cfg.ProxyConfigs = make(map[string]*ProxyConfig)
+	}
+
+	return cfg, nil
+}
+
+// decodeFile 按文件格式将配置内容解码为通用结构
+// 各格式统一转换为 JSON 后再解码到结构体，复用结构体上的 json 标签
+func decodeFile(path string, data []byte) (map[string]interface{}, error) {
+	var raw map[string]interface{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, nil
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		if _, err := toml.Decode(string(data), &raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension: %q (expected .yaml, .yml, .json or .toml)", ext)
+	}
+
+	return raw, nil
+}
+
+// decodeJSON 严格解码 JSON，未知字段视为错误，避免配置项拼写错误被静默忽略
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
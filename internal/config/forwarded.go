@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// 转发头模式
+const (
+	ForwardedModeXForwarded = "x_forwarded" // X-Forwarded-For、X-Forwarded-Proto、X-Forwarded-Host、X-Forwarded-Prefix
+	ForwardedModeRFC7239    = "forwarded"   // RFC 7239 Forwarded 头
+	ForwardedModeBoth       = "both"        // 同时发送两种转发头
+	ForwardedModeNone       = "none"        // 不发送转发头，并移除请求中的转发头
+)
+
+// ForwardedConfig 转发头配置
+// 客户端地址属于 trusted_proxies 时保留请求中已有的转发头并在其后追加，否则丢弃请求中的转发头重新生成
+type ForwardedConfig struct {
+	Mode           string   `json:"mode,omitempty"`            // 转发头模式：x_forwarded（默认）、forwarded、both、none
+	TrustedProxies []string `json:"trusted_proxies,omitempty"` // 可信代理的 IP 或 CIDR，如 10.0.0.0/8
+
+	trusted []netip.Prefix // 解析后的可信代理网段
+}
+
+// GetMode 获取转发头模式，未设置时为 x_forwarded
+func (f *ForwardedConfig) GetMode() string {
+	if f == nil || f.Mode == "" {
+		return ForwardedModeXForwarded
+	}
+	return f.Mode
+}
+
+// SendsXForwarded 判断是否发送 X-Forwarded-* 头
+func (f *ForwardedConfig) SendsXForwarded() bool {
+	mode := f.GetMode()
+	return mode == ForwardedModeXForwarded || mode == ForwardedModeBoth
+}
+
+// SendsForwarded 判断是否发送 RFC 7239 Forwarded 头
+func (f *ForwardedConfig) SendsForwarded() bool {
+	mode := f.GetMode()
+	return mode == ForwardedModeRFC7239 || mode == ForwardedModeBoth
+}
+
+// IsTrusted 判断客户端 IP 是否属于可信代理
+// 配置未经过配置校验解析时在此解析，无法解析的项忽略
+func (f *ForwardedConfig) IsTrusted(ip string) bool {
+	if f == nil || len(f.TrustedProxies) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	trusted := f.trusted
+	if trusted == nil {
+		for _, cidr := range f.TrustedProxies {
+			if prefix, err := ParseIPPrefix(cidr); err == nil {
+				trusted = append(trusted, prefix)
+			}
+		}
+	}
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 验证转发头配置，name 为路由名称
+func (f *ForwardedConfig) validate(name string) []error {
+	if f == nil {
+		return nil
+	}
+
+	var errs []error
+	switch f.Mode {
+	case "", ForwardedModeXForwarded, ForwardedModeRFC7239, ForwardedModeBoth, ForwardedModeNone:
+	default:
+		errs = append(errs, fmt.Errorf("proxy %s: invalid forwarded.mode %q (must be %s, %s, %s or %s)",
+			name, f.Mode, ForwardedModeXForwarded, ForwardedModeRFC7239, ForwardedModeBoth, ForwardedModeNone))
+	}
+
+	f.trusted = nil
+	for _, cidr := range f.TrustedProxies {
+		prefix, err := ParseIPPrefix(cidr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("proxy %s: invalid address %q in forwarded.trusted_proxies (expected an IP or CIDR such as 10.0.0.0/8)", name, cidr))
+			continue
+		}
+		f.trusted = append(f.trusted, prefix)
+	}
+	return errs
+}
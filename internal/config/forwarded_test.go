@@ -0,0 +1,35 @@
+package config
+
+import "testing"
+
+func TestForwardedIsTrusted(t *testing.T) {
+	f := &ForwardedConfig{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1", "fd00::/8", "bad"}}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"fd00::1", true},
+		{"2001:db8::1", false},
+		{"not-an-ip", false},
+	}
+	// 未经过配置校验时按需解析，校验后使用解析结果
+	for _, validated := range []bool{false, true} {
+		if validated {
+			f.validate("api")
+		}
+		for _, tt := range tests {
+			if got := f.IsTrusted(tt.ip); got != tt.want {
+				t.Errorf("validated=%t: IsTrusted(%q) = %t, want %t", validated, tt.ip, got, tt.want)
+			}
+		}
+	}
+
+	var none *ForwardedConfig
+	if none.IsTrusted("10.1.2.3") || none.GetMode() != ForwardedModeXForwarded || !none.SendsXForwarded() || none.SendsForwarded() {
+		t.Error("nil ForwardedConfig should trust nobody and send only X-Forwarded-*")
+	}
+}
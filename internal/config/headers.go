@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// HeaderSeparator 命令行 header 选项中头名称与值的分隔符
+const HeaderSeparator = ":"
+
+// 头的值中可以使用的模板变量
+const (
+	HeaderVarClientIP  = "client_ip"  // 客户端 IP
+	HeaderVarRequestID = "request_id" // 请求 ID：请求中的 X-Request-Id，没有时生成随机 ID
+	HeaderVarHost      = "host"       // 原始请求的 Host 头
+	HeaderVarScheme    = "scheme"     // 原始请求的协议（http 或 https）
+)
+
+// headerVars 支持的模板变量
+var headerVars = []string{HeaderVarClientIP, HeaderVarRequestID, HeaderVarHost, HeaderVarScheme}
+
+// HeaderRules 请求头或响应头的修改规则，按 remove、set、add 的顺序执行
+// 头的值支持 {client_ip}、{request_id}、{host}、{scheme} 模板变量
+type HeaderRules struct {
+	Set    map[string]string `json:"set,omitempty"`    // 设置的头，覆盖已有的值
+	Add    map[string]string `json:"add,omitempty"`    // 追加的头，保留已有的值
+	Remove []string          `json:"remove,omitempty"` // 移除的头
+}
+
+// IsEmpty 判断是否没有任何规则
+func (h *HeaderRules) IsEmpty() bool {
+	return h == nil || (len(h.Set) == 0 && len(h.Add) == 0 && len(h.Remove) == 0)
+}
+
+// ParseHeader 解析命令行 header 选项，格式为 Name:value
+func ParseHeader(spec string) (string, string, error) {
+	name, value, ok := strings.Cut(spec, HeaderSeparator)
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("%q must be in the form Name%svalue", spec, HeaderSeparator)
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
+// ExpandHeaderValue 展开头的值中的模板变量，lookup 返回变量的值
+// 未知的变量和不成对的花括号原样保留
+func ExpandHeaderValue(value string, lookup func(name string) string) string {
+	if !strings.Contains(value, "{") {
+		return value
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(value, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(value[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(value[:start])
+		if name := value[start+1 : end]; isHeaderVar(name) {
+			b.WriteString(lookup(name))
+		} else {
+			b.WriteString(value[start : end+1])
+		}
+		value = value[end+1:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
+
+// isHeaderVar 判断是否为支持的模板变量
+func isHeaderVar(name string) bool {
+	for _, v := range headerVars {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 验证头修改规则，name 为路由名称，field 为配置项名称（request_headers 或 response_headers）
+func (h *HeaderRules) validate(name, field string) []error {
+	if h == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, action := range []struct {
+		key    string
+		values map[string]string
+	}{{"set", h.Set}, {"add", h.Add}} {
+		keys := make([]string, 0, len(action.values))
+		for key := range action.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			errs = append(errs, validateHeaderName(name, field, action.key, key)...)
+			errs = append(errs, validateHeaderValue(name, field, action.key, key, action.values[key])...)
+		}
+	}
+	for _, key := range h.Remove {
+		errs = append(errs, validateHeaderName(name, field, "remove", key)...)
+	}
+	return errs
+}
+
+// validateHeaderName 验证头名称
+// Host 头只能在请求头中设置，不能追加或移除
+func validateHeaderName(name, field, action, key string) []error {
+	if !isToken(key) {
+		return []error{fmt.Errorf("proxy %s: invalid header name %q in %s.%s", name, key, field, action)}
+	}
+	if http.CanonicalHeaderKey(key) == "Host" && (field != "request_headers" || action != "set") {
+		return []error{fmt.Errorf("proxy %s: Host header can only be set in request_headers.set, not in %s.%s", name, field, action)}
+	}
+	return nil
+}
+
+// validateHeaderValue 验证头的值：不能包含换行，花括号中的模板变量必须是支持的变量
+func validateHeaderValue(name, field, action, key, value string) []error {
+	if strings.ContainsAny(value, "\r\n") {
+		return []error{fmt.Errorf("proxy %s: value of header %s in %s.%s must not contain line breaks", name, key, field, action)}
+	}
+
+	var errs []error
+	rest := value
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			break
+		}
+		if v := rest[start+1 : start+end]; !isHeaderVar(v) {
+			errs = append(errs, fmt.Errorf("proxy %s: unknown variable {%s} in header %s of %s.%s (supported: {%s})",
+				name, v, key, field, action, strings.Join(headerVars, "}, {")))
+		}
+		rest = rest[start+end+1:]
+	}
+	return errs
+}
@@ -0,0 +1,40 @@
+package config
+
+import "testing"
+
+func TestExpandHeaderValue(t *testing.T) {
+	vars := map[string]string{HeaderVarClientIP: "10.0.0.1", HeaderVarHost: "www.local", HeaderVarScheme: "https"}
+	lookup := func(name string) string { return vars[name] }
+
+	tests := []struct {
+		value, want string
+	}{
+		{"plain", "plain"},
+		{"{client_ip}", "10.0.0.1"},
+		{"{scheme}://{host}/", "https://www.local/"},
+		{"{user}-{host}", "{user}-www.local"},
+		{"{host", "{host"},
+		{"}{host}{", "}www.local{"},
+		{"{}", "{}"},
+	}
+	for _, tt := range tests {
+		if got := ExpandHeaderValue(tt.value, lookup); got != tt.want {
+			t.Errorf("ExpandHeaderValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	name, value, err := ParseHeader(" X-Env : a:b ")
+	if err != nil || name != "X-Env" || value != "a:b" {
+		t.Errorf("ParseHeader = %q, %q, %v, want X-Env, a:b", name, value, err)
+	}
+	if _, value, err := ParseHeader("X-Empty:"); err != nil || value != "" {
+		t.Errorf("ParseHeader(empty value) = %q, %v", value, err)
+	}
+	for _, spec := range []string{"X-Env", ":v", " :v"} {
+		if _, _, err := ParseHeader(spec); err == nil {
+			t.Errorf("ParseHeader(%q) succeeded, want error", spec)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 主动健康检查的默认值
+const (
+	DefaultHealthCheckPath     = "/"
+	DefaultHealthCheckInterval = Duration(10 * time.Second)
+	DefaultHealthCheckTimeout  = Duration(2 * time.Second)
+	DefaultHealthyThreshold    = 2
+	DefaultUnhealthyThreshold  = 3
+)
+
+// HealthCheckConfig 主动健康检查配置
+// 定期向每个上游目标发送 GET 请求，连续失败达到阈值的目标被移出负载均衡，连续成功达到阈值后恢复
+type HealthCheckConfig struct {
+	Path               string   `json:"path,omitempty"`                // 检查路径，默认 /，不拼接 target_path
+	Interval           Duration `json:"interval,omitempty"`            // 检查间隔，默认 10s
+	Timeout            Duration `json:"timeout,omitempty"`             // 单次检查超时时间，默认 2s
+	ExpectedStatus     []int    `json:"expected_status,omitempty"`     // 视为健康的响应状态码，为空时 2xx 和 3xx 视为健康
+	HealthyThreshold   int      `json:"healthy_threshold,omitempty"`   // 连续成功多少次后恢复，默认 2
+	UnhealthyThreshold int      `json:"unhealthy_threshold,omitempty"` // 连续失败多少次后移出，默认 3
+}
+
+// WithDefaults 返回填充默认值后的健康检查配置
+func (h *HealthCheckConfig) WithDefaults() HealthCheckConfig {
+	settings := *h
+	if settings.Path == "" {
+		settings.Path = DefaultHealthCheckPath
+	}
+	if settings.Interval == 0 {
+		settings.Interval = DefaultHealthCheckInterval
+	}
+	if settings.Timeout == 0 {
+		settings.Timeout = DefaultHealthCheckTimeout
+	}
+	if settings.HealthyThreshold == 0 {
+		settings.HealthyThreshold = DefaultHealthyThreshold
+	}
+	if settings.UnhealthyThreshold == 0 {
+		settings.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+	return settings
+}
+
+// IsExpectedStatus 判断响应状态码是否视为健康
+func (h *HealthCheckConfig) IsExpectedStatus(status int) bool {
+	if len(h.ExpectedStatus) == 0 {
+		return status >= http.StatusOK && status < http.StatusBadRequest
+	}
+	for _, expected := range h.ExpectedStatus {
+		if status == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 验证健康检查配置，name 为路由名称
+func (h *HealthCheckConfig) validate(name string) []error {
+	if h == nil {
+		return nil
+	}
+
+	var errs []error
+	if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+		errs = append(errs, fmt.Errorf("proxy %s: health_check.path %q must start with /", name, h.Path))
+	}
+	if h.Interval < 0 {
+		errs = append(errs, fmt.Errorf("proxy %s: health_check.interval must not be negative, got %s", name, h.Interval))
+	}
+	if h.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("proxy %s: health_check.timeout must not be negative, got %s", name, h.Timeout))
+	}
+	if settings := h.WithDefaults(); settings.Timeout > settings.Interval {
+		errs = append(errs, fmt.Errorf("proxy %s: health_check.timeout %s must not exceed interval %s", name, settings.Timeout, settings.Interval))
+	}
+	for _, status := range h.ExpectedStatus {
+		if status < 100 || status > 599 {
+			errs = append(errs, fmt.Errorf("proxy %s: invalid status %d in health_check.expected_status", name, status))
+		}
+	}
+	if h.HealthyThreshold < 0 {
+		errs = append(errs, fmt.Errorf("proxy %s: health_check.healthy_threshold must not be negative, got %d", name, h.HealthyThreshold))
+	}
+	if h.UnhealthyThreshold < 0 {
+		errs = append(errs, fmt.Errorf("proxy %s: health_check.unhealthy_threshold must not be negative, got %d", name, h.UnhealthyThreshold))
+	}
+	return errs
+}
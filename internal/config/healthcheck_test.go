@@ -0,0 +1,18 @@
+package config
+
+import "testing"
+
+func TestHealthCheckExpectedStatus(t *testing.T) {
+	settings := &HealthCheckConfig{}
+	for status, want := range map[int]bool{200: true, 204: true, 301: true, 404: false, 503: false} {
+		if got := settings.IsExpectedStatus(status); got != want {
+			t.Errorf("default IsExpectedStatus(%d) = %t, want %t", status, got, want)
+		}
+	}
+	settings.ExpectedStatus = []int{200, 401}
+	for status, want := range map[int]bool{200: true, 401: true, 204: false} {
+		if got := settings.IsExpectedStatus(status); got != want {
+			t.Errorf("IsExpectedStatus(%d) with %v = %t, want %t", status, settings.ExpectedStatus, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 监听协议
+const (
+	ProtocolHTTP  = "http"
+	ProtocolHTTPS = "https"
+)
+
+// Listener 监听器配置
+// 所有监听器共享同一套站点路由（虚拟主机、代理和静态文件），在服务器停止时一起关闭
+type Listener struct {
+	Addr     string `json:"addr"`                // 监听地址，如 :8080
+	Protocol string `json:"protocol"`            // 协议：http 或 https
+	CertFile string `json:"cert_file,omitempty"` // SSL 证书文件路径，为空时按 SNI 使用顶层或虚拟主机证书
+	KeyFile  string `json:"key_file,omitempty"`  // SSL 私钥文件路径
+
+	TLS *TLSProfileConfig `json:"tls,omitempty"` // TLS 协议版本和加密套件，为空时使用顶层 tls 设置
+}
+
+// String 获取监听器的描述，如 https://:8443
+func (l *Listener) String() string {
+	return l.Protocol + "://" + l.Addr
+}
+
+// IsHTTPS 判断监听器是否使用 HTTPS
+func (l *Listener) IsHTTPS() bool {
+	return l.Protocol == ProtocolHTTPS
+}
+
+// ParseListener 解析监听器字符串，格式：protocol://addr，如 http://:8080、https://0.0.0.0:8443
+// 省略协议时默认为 http
+func ParseListener(spec string) (*Listener, error) {
+	spec = strings.TrimSpace(spec)
+	protocol, addr, ok := strings.Cut(spec, "://")
+	if !ok {
+		protocol, addr = ProtocolHTTP, spec
+	}
+	protocol = strings.ToLower(protocol)
+	if protocol != ProtocolHTTP && protocol != ProtocolHTTPS {
+		return nil, fmt.Errorf("invalid listener %q: unsupported protocol %q (must be http or https)", spec, protocol)
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("invalid listener %q: address is empty", spec)
+	}
+	return &Listener{Addr: addr, Protocol: protocol}, nil
+}
+
+// EffectiveListeners 获取生效的监听器列表
+// 未配置 listeners 时根据 host 和证书配置生成单个监听器，与原有行为一致
+func (c *Config) EffectiveListeners() []*Listener {
+	if len(c.Listeners) > 0 {
+		return c.Listeners
+	}
+	protocol := ProtocolHTTP
+	if c.IsHTTPS() {
+		protocol = ProtocolHTTPS
+	}
+	return []*Listener{{Addr: c.Host, Protocol: protocol}}
+}
+
+// ListenerTLS 获取监听器生效的 TLS 档位设置，监听器未设置时使用顶层设置，都未设置时返回 nil（使用默认档位）
+func (c *Config) ListenerTLS(listener *Listener) *TLSProfileConfig {
+	if listener.TLS != nil {
+		return listener.TLS
+	}
+	return c.TLS
+}
+
+// validateListeners 验证监听器配置
+func (c *Config) validateListeners() []error {
+	var errs []error
+	addrs := make(map[string]bool)
+
+	for i, listener := range c.Listeners {
+		if listener == nil {
+			errs = append(errs, fmt.Errorf("listeners[%d]: empty listener", i))
+			continue
+		}
+		label := fmt.Sprintf("listener %s", listener)
+
+		if listener.Addr == "" {
+			errs = append(errs, fmt.Errorf("listeners[%d]: addr is empty", i))
+		} else if addrs[listener.Addr] {
+			errs = append(errs, fmt.Errorf("%s: duplicate address %s", label, listener.Addr))
+		}
+		addrs[listener.Addr] = true
+
+		switch listener.Protocol {
+		case ProtocolHTTP:
+			if listener.CertFile != "" || listener.KeyFile != "" {
+				errs = append(errs, fmt.Errorf("%s: cert_file and key_file are only allowed for https listeners", label))
+			}
+			if listener.TLS != nil {
+				errs = append(errs, fmt.Errorf("%s: tls is only allowed for https listeners", label))
+			}
+		case ProtocolHTTPS:
+			if listener.CertFile != "" || listener.KeyFile != "" {
+				if err := validateCertPair(listener.CertFile, listener.KeyFile); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %v", label, err))
+				}
+			} else if !c.IsHTTPS() {
+				errs = append(errs, fmt.Errorf("%s: no certificate available (set cert_file/key_file on the listener, top level or a virtual host)", label))
+			}
+			errs = append(errs, listener.TLS.validate(label+": tls")...)
+		default:
+			errs = append(errs, fmt.Errorf("listeners[%d]: invalid protocol %q (must be http or https)", i, listener.Protocol))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseListener(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    *Listener
+		wantErr string
+	}{
+		{spec: "http://:8080", want: &Listener{Addr: ":8080", Protocol: ProtocolHTTP}},
+		{spec: "HTTPS://0.0.0.0:8443", want: &Listener{Addr: "0.0.0.0:8443", Protocol: ProtocolHTTPS}},
+		{spec: " :9090 ", want: &Listener{Addr: ":9090", Protocol: ProtocolHTTP}},
+		{spec: "https://[::1]:8443", want: &Listener{Addr: "[::1]:8443", Protocol: ProtocolHTTPS}},
+		{spec: "ftp://:21", wantErr: `unsupported protocol "ftp"`},
+		{spec: "https://", wantErr: "address is empty"},
+	}
+	for _, tt := range tests {
+		got, err := ParseListener(tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseListener(%q) error = %v, want containing %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseListener(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseListener(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestEffectiveListeners(t *testing.T) {
+	explicit := []*Listener{
+		{Addr: ":8080", Protocol: ProtocolHTTP},
+		{Addr: ":8443", Protocol: ProtocolHTTPS},
+	}
+	tests := []struct {
+		name string
+		cfg  *Config
+		want []*Listener
+	}{
+		{
+			name: "derived http from host",
+			cfg:  &Config{Host: ":8080"},
+			want: []*Listener{{Addr: ":8080", Protocol: ProtocolHTTP}},
+		},
+		{
+			name: "derived https from top-level certificate",
+			cfg:  &Config{Host: ":8443", CertFile: "cert.pem", KeyFile: "key.pem"},
+			want: []*Listener{{Addr: ":8443", Protocol: ProtocolHTTPS}},
+		},
+		{
+			name: "derived https from virtual host certificate",
+			cfg: &Config{Host: ":8443", VirtualHosts: []*VirtualHost{
+				{Name: "a", Hosts: []string{"a.local"}, CertFile: "a.pem", KeyFile: "a.key"},
+			}},
+			want: []*Listener{{Addr: ":8443", Protocol: ProtocolHTTPS}},
+		},
+		{
+			name: "explicit listeners ignore host",
+			cfg:  &Config{Host: ":9999", CertFile: "cert.pem", KeyFile: "key.pem", Listeners: explicit},
+			want: explicit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.EffectiveListeners(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EffectiveListeners() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateListeners(t *testing.T) {
+	cfg := &Config{Listeners: []*Listener{
+		{Addr: ":8080", Protocol: ProtocolHTTP},
+		{Addr: ":8080", Protocol: ProtocolHTTP},
+		{Addr: ":8081", Protocol: ProtocolHTTP, CertFile: "cert.pem", KeyFile: "key.pem"},
+		{Addr: ":8082", Protocol: ProtocolHTTP, TLS: &TLSProfileConfig{Profile: TLSProfileModern}},
+		{Addr: ":8443", Protocol: ProtocolHTTPS},
+		{Addr: ":8444", Protocol: ProtocolHTTPS, CertFile: "/nonexistent/cert.pem", KeyFile: "/nonexistent/key.pem"},
+		{Addr: ":8445", Protocol: ProtocolHTTPS, CertFile: "/nonexistent/cert.pem", KeyFile: "/nonexistent/key.pem", TLS: &TLSProfileConfig{MinVersion: "1.4"}},
+		{Addr: ":21", Protocol: "ftp"},
+		{Protocol: ProtocolHTTP},
+		nil,
+	}}
+
+	var got []string
+	for _, err := range cfg.validateListeners() {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"listener http://:8080: duplicate address :8080",
+		"listener http://:8081: cert_file and key_file are only allowed for https listeners",
+		"listener http://:8082: tls is only allowed for https listeners",
+		"listener https://:8443: no certificate available (set cert_file/key_file on the listener, top level or a virtual host)",
+		"listener https://:8444: certificate file not found: /nonexistent/cert.pem",
+		"listener https://:8445: certificate file not found: /nonexistent/cert.pem",
+		`listener https://:8445: tls: min_version: invalid TLS version "1.4" (must be 1.0, 1.1, 1.2 or 1.3)`,
+		`listeners[7]: invalid protocol "ftp" (must be http or https)`,
+		"listeners[8]: addr is empty",
+		"listeners[9]: empty listener",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("validateListeners() errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,723 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// proxySpecOptions URL 格式代理配置支持的命名选项及其解析函数
+var proxySpecOptions = map[string]func(pc *ProxyConfig, value string) error{
+	"insecure": func(pc *ProxyConfig, value string) error {
+		b, err := parseSpecBool(value)
+		if err != nil {
+			return err
+		}
+		pc.Insecure = b
+		return nil
+	},
+	"ca_file": func(pc *ProxyConfig, value string) error {
+		if value == "" {
+			return fmt.Errorf("CA file path is empty")
+		}
+		specTLS(pc).CAFile = value
+		return nil
+	},
+	"server_name": func(pc *ProxyConfig, value string) error {
+		specTLS(pc).ServerName = value
+		return nil
+	},
+	"pin": func(pc *ProxyConfig, value string) error {
+		if _, err := ParsePin(value); err != nil {
+			return err
+		}
+		t := specTLS(pc)
+		t.Pins = append(t.Pins, value)
+		return nil
+	},
+	"cert_file": func(pc *ProxyConfig, value string) error {
+		if value == "" {
+			return fmt.Errorf("client certificate path is empty")
+		}
+		specTLS(pc).CertFile = value
+		return nil
+	},
+	"key_file": func(pc *ProxyConfig, value string) error {
+		if value == "" {
+			return fmt.Errorf("client key path is empty")
+		}
+		specTLS(pc).KeyFile = value
+		return nil
+	},
+	"pkcs12_file": func(pc *ProxyConfig, value string) error {
+		if value == "" {
+			return fmt.Errorf("PKCS#12 file path is empty")
+		}
+		specTLS(pc).PKCS12File = value
+		return nil
+	},
+	"pkcs12_password": func(pc *ProxyConfig, value string) error {
+		specTLS(pc).PKCS12Password = value
+		return nil
+	},
+	"tls_profile": func(pc *ProxyConfig, value string) error {
+		if _, ok := tlsProfiles[strings.ToLower(value)]; !ok {
+			return fmt.Errorf("must be %s, %s or %s", TLSProfileLegacy, TLSProfileIntermediate, TLSProfileModern)
+		}
+		specTLS(pc).Profile = strings.ToLower(value)
+		return nil
+	},
+	"tls_min_version": func(pc *ProxyConfig, value string) error {
+		if _, err := parseTLSVersion(value); err != nil {
+			return err
+		}
+		specTLS(pc).MinVersion = value
+		return nil
+	},
+	"tls_max_version": func(pc *ProxyConfig, value string) error {
+		if _, err := parseTLSVersion(value); err != nil {
+			return err
+		}
+		specTLS(pc).MaxVersion = value
+		return nil
+	},
+	"tls_ciphers": func(pc *ProxyConfig, value string) error {
+		ciphers := splitSpecList(value)
+		for _, name := range ciphers {
+			if _, err := parseCipherSuite(name); err != nil {
+				return err
+			}
+		}
+		specTLS(pc).Ciphers = ciphers
+		return nil
+	},
+	"tls_curves": func(pc *ProxyConfig, value string) error {
+		curves := splitSpecList(value)
+		for _, name := range curves {
+			if _, ok := tlsCurves[strings.ToLower(name)]; !ok {
+				return fmt.Errorf("unknown curve %q", name)
+			}
+		}
+		specTLS(pc).Curves = curves
+		return nil
+	},
+	"strip_prefix": func(pc *ProxyConfig, value string) error {
+		b, err := parseSpecBool(value)
+		if err != nil {
+			return err
+		}
+		pc.StripPrefix = &b
+		return nil
+	},
+	"replace_prefix": func(pc *ProxyConfig, value string) error {
+		if value == "" {
+			return fmt.Errorf("replacement prefix is empty (use strip_prefix=true to remove the prefix)")
+		}
+		pc.ReplacePrefix = value
+		return nil
+	},
+	"rewrite": func(pc *ProxyConfig, value string) error {
+		match, replace, ok := strings.Cut(value, RewriteSeparator)
+		if !ok || match == "" {
+			return fmt.Errorf("%q must be in the form pattern%sreplacement", value, RewriteSeparator)
+		}
+		pc.Rewrites = append(pc.Rewrites, &RewriteRule{Match: match, Replace: replace})
+		return nil
+	},
+	"rewrite_body": func(pc *ProxyConfig, value string) error {
+		b, err := parseSpecBool(value)
+		if err != nil {
+			return err
+		}
+		if !b {
+			pc.RewriteBody = nil
+		} else if pc.RewriteBody == nil {
+			pc.RewriteBody = &BodyRewriteConfig{}
+		}
+		return nil
+	},
+	"rewrite_root_relative": func(pc *ProxyConfig, value string) error {
+		b, err := parseSpecBool(value)
+		if err != nil {
+			return err
+		}
+		if pc.RewriteBody == nil {
+			pc.RewriteBody = &BodyRewriteConfig{}
+		}
+		pc.RewriteBody.RootRelative = &b
+		return nil
+	},
+	"reverse_rewrite": func(pc *ProxyConfig, value string) error {
+		b, err := parseSpecBool(value)
+		if err != nil {
+			return err
+		}
+		if !b {
+			pc.ReverseRewrite = nil
+		} else if pc.ReverseRewrite == nil {
+			pc.ReverseRewrite = &ReverseRewriteConfig{}
+		}
+		return nil
+	},
+	"cookie_secure": func(pc *ProxyConfig, value string) error {
+		if pc.ReverseRewrite == nil {
+			pc.ReverseRewrite = &ReverseRewriteConfig{}
+		}
+		pc.ReverseRewrite.CookieSecure = value
+		return nil
+	},
+	"preserve_host": func(pc *ProxyConfig, value string) error {
+		b, err := parseSpecBool(value)
+		if err != nil {
+			return err
+		}
+		pc.PreserveHost = b
+		return nil
+	},
+	"forwarded": func(pc *ProxyConfig, value string) error {
+		specForwarded(pc).Mode = value
+		return nil
+	},
+	"trusted_proxies": func(pc *ProxyConfig, value string) error {
+		cidrs := splitSpecList(value)
+		if len(cidrs) == 0 {
+			return fmt.Errorf("trusted_proxies is empty")
+		}
+		f := specForwarded(pc)
+		f.TrustedProxies = append(f.TrustedProxies, cidrs...)
+		return nil
+	},
+	"set_header": func(pc *ProxyConfig, value string) error {
+		return specSetHeader(&pc.RequestHeaders, value, false)
+	},
+	"add_header": func(pc *ProxyConfig, value string) error {
+		return specSetHeader(&pc.RequestHeaders, value, true)
+	},
+	"remove_header": func(pc *ProxyConfig, value string) error {
+		return specRemoveHeader(&pc.RequestHeaders, value)
+	},
+	"set_response_header": func(pc *ProxyConfig, value string) error {
+		return specSetHeader(&pc.ResponseHeaders, value, false)
+	},
+	"add_response_header": func(pc *ProxyConfig, value string) error {
+		return specSetHeader(&pc.ResponseHeaders, value, true)
+	},
+	"remove_response_header": func(pc *ProxyConfig, value string) error {
+		return specRemoveHeader(&pc.ResponseHeaders, value)
+	},
+	"host": func(pc *ProxyConfig, value string) error {
+		hosts := splitSpecList(value)
+		if len(hosts) == 0 {
+			return fmt.Errorf("host is empty")
+		}
+		pc.Hosts = append(pc.Hosts, hosts...)
+		return nil
+	},
+	"method": func(pc *ProxyConfig, value string) error {
+		methods := splitSpecList(strings.ToUpper(value))
+		if len(methods) == 0 {
+			return fmt.Errorf("method is empty")
+		}
+		pc.Methods = append(pc.Methods, methods...)
+		return nil
+	},
+	"priority": func(pc *ProxyConfig, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		pc.Priority = n
+		return nil
+	},
+	"sticky_origin": func(pc *ProxyConfig, value string) error {
+		b, err := parseSpecBool(value)
+		if err != nil {
+			return err
+		}
+		pc.StickyOrigin = b
+		return nil
+	},
+	"upstream": func(pc *ProxyConfig, value string) error {
+		target, err := ParseUpstreamTarget(value)
+		if err != nil {
+			return err
+		}
+		if target.Address == "" {
+			return fmt.Errorf("upstream is empty")
+		}
+		pc.Targets = append(pc.Targets, target)
+		return nil
+	},
+	"lb": func(pc *ProxyConfig, value string) error {
+		specLoadBalancer(pc).Policy = value
+		return nil
+	},
+	"hash_header": func(pc *ProxyConfig, value string) error {
+		lb := specLoadBalancer(pc)
+		lb.HashHeader = value
+		if lb.Policy == "" {
+			lb.Policy = PolicyHash
+		}
+		return nil
+	},
+	"hash_cookie": func(pc *ProxyConfig, value string) error {
+		lb := specLoadBalancer(pc)
+		lb.HashCookie = value
+		if lb.Policy == "" {
+			lb.Policy = PolicyHash
+		}
+		return nil
+	},
+	"health_check": func(pc *ProxyConfig, value string) error {
+		if !strings.HasPrefix(value, "/") {
+			return fmt.Errorf("%q must be a path starting with /", value)
+		}
+		specHealthCheck(pc).Path = value
+		return nil
+	},
+	"health_interval": func(pc *ProxyConfig, value string) error {
+		return parseSpecDuration(value, &specHealthCheck(pc).Interval)
+	},
+	"health_timeout": func(pc *ProxyConfig, value string) error {
+		return parseSpecDuration(value, &specHealthCheck(pc).Timeout)
+	},
+	"circuit_breaker": func(pc *ProxyConfig, value string) error {
+		cb := specCircuitBreaker(pc)
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("%q is not a positive integer", value)
+		}
+		cb.ConsecutiveFailures = n
+		return nil
+	},
+	"cooldown": func(pc *ProxyConfig, value string) error {
+		return parseSpecDuration(value, &specCircuitBreaker(pc).Cooldown)
+	},
+	"retry": func(pc *ProxyConfig, value string) error {
+		r := specRetry(pc)
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("%q is not a positive integer", value)
+		}
+		r.Attempts = n
+		return nil
+	},
+	"retry_on": func(pc *ProxyConfig, value string) error {
+		kinds := splitSpecList(value)
+		if len(kinds) == 0 {
+			return fmt.Errorf("retry_on is empty")
+		}
+		r := specRetry(pc)
+		r.RetryOn = append(r.RetryOn, kinds...)
+		return nil
+	},
+	"retry_backoff": func(pc *ProxyConfig, value string) error {
+		return parseSpecDuration(value, &specRetry(pc).Backoff)
+	},
+	"retry_non_idempotent": func(pc *ProxyConfig, value string) error {
+		b, err := parseSpecBool(value)
+		if err != nil {
+			return err
+		}
+		specRetry(pc).RetryNonIdempotent = b
+		return nil
+	},
+	"dial_timeout": func(pc *ProxyConfig, value string) error {
+		return parseSpecDuration(value, &specTransport(pc).DialTimeout)
+	},
+	"keep_alive": func(pc *ProxyConfig, value string) error {
+		return parseSpecDuration(value, &specTransport(pc).KeepAlive)
+	},
+	"idle_conn_timeout": func(pc *ProxyConfig, value string) error {
+		return parseSpecDuration(value, &specTransport(pc).IdleConnTimeout)
+	},
+	"max_idle_conns_per_host": func(pc *ProxyConfig, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%q is not a non-negative integer", value)
+		}
+		specTransport(pc).MaxIdleConnsPerHost = n
+		return nil
+	},
+	"disable_keep_alives": func(pc *ProxyConfig, value string) error {
+		b, err := parseSpecBool(value)
+		if err != nil {
+			return err
+		}
+		specTransport(pc).DisableKeepAlives = b
+		return nil
+	},
+}
+
+// specTLS 获取代理配置的上游 TLS 配置，未设置时创建
+func specTLS(pc *ProxyConfig) *UpstreamTLSConfig {
+	if pc.TLS == nil {
+		pc.TLS = &UpstreamTLSConfig{}
+	}
+	return pc.TLS
+}
+
+// specTransport 获取代理配置的连接设置，未设置时创建
+func specTransport(pc *ProxyConfig) *TransportConfig {
+	if pc.Transport == nil {
+		pc.Transport = &TransportConfig{}
+	}
+	return pc.Transport
+}
+
+// specForwarded 获取代理配置的转发头配置，未设置时创建
+func specForwarded(pc *ProxyConfig) *ForwardedConfig {
+	if pc.Forwarded == nil {
+		pc.Forwarded = &ForwardedConfig{}
+	}
+	return pc.Forwarded
+}
+
+// specSetHeader 解析 Name:value 格式的头并加入头修改规则，add 为 true 时追加，否则设置
+func specSetHeader(rules **HeaderRules, value string, add bool) error {
+	name, headerValue, err := ParseHeader(value)
+	if err != nil {
+		return err
+	}
+	if *rules == nil {
+		*rules = &HeaderRules{}
+	}
+	target := &(*rules).Set
+	if add {
+		target = &(*rules).Add
+	}
+	if *target == nil {
+		*target = map[string]string{}
+	}
+	(*target)[name] = headerValue
+	return nil
+}
+
+// specRemoveHeader 将逗号分隔的头名称加入头修改规则的移除列表
+func specRemoveHeader(rules **HeaderRules, value string) error {
+	names := splitSpecList(value)
+	if len(names) == 0 {
+		return fmt.Errorf("header name is empty")
+	}
+	if *rules == nil {
+		*rules = &HeaderRules{}
+	}
+	(*rules).Remove = append((*rules).Remove, names...)
+	return nil
+}
+
+// specLoadBalancer 获取代理配置的负载均衡配置，未设置时创建
+func specLoadBalancer(pc *ProxyConfig) *LoadBalancerConfig {
+	if pc.LoadBalancer == nil {
+		pc.LoadBalancer = &LoadBalancerConfig{}
+	}
+	return pc.LoadBalancer
+}
+
+// specHealthCheck 获取代理配置的健康检查配置，未设置时创建（即启用健康检查）
+func specHealthCheck(pc *ProxyConfig) *HealthCheckConfig {
+	if pc.HealthCheck == nil {
+		pc.HealthCheck = &HealthCheckConfig{}
+	}
+	return pc.HealthCheck
+}
+
+// specCircuitBreaker 获取代理配置的熔断器配置，未设置时创建（即启用熔断器）
+func specCircuitBreaker(pc *ProxyConfig) *CircuitBreakerConfig {
+	if pc.CircuitBreaker == nil {
+		pc.CircuitBreaker = &CircuitBreakerConfig{}
+	}
+	return pc.CircuitBreaker
+}
+
+// specRetry 获取代理配置的重试配置，未设置时创建（即启用重试）
+func specRetry(pc *ProxyConfig) *RetryConfig {
+	if pc.Retry == nil {
+		pc.Retry = &RetryConfig{}
+	}
+	return pc.Retry
+}
+
+// parseSpecDuration 解析时间间隔选项
+func parseSpecDuration(value string, d *Duration) error {
+	parsed, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// ParseProxySpec 解析单个代理配置字符串，返回路由名称和代理配置
+// 没有主机和方法限制时路由名称即为路径前缀，否则由方法、主机和路径前缀组合而成（如 GET api.local/api），
+// 保证同一路径前缀在不同主机下的路由互不覆盖
+// 支持两种格式：
+//   - URL 格式：{path_prefix}={scheme}://{host}[:{port}][/{base_path}][?{option}={value}&...]
+//     如 /api=https://api.example.com:8443/v2?insecure=true&strip_prefix=true
+//     如 /api=http://localhost:3000?rewrite=^/api/v1/(.*)->/v1/$1
+//     如 /api/v2=http://localhost:3001?host=api.local:8080&method=GET,POST
+//     如 /api=http://10.0.0.1:8080?upstream=10.0.0.2:8080*3&lb=least_conn
+//   - 旧格式：path_prefix:target_domain[:port][/base_path]:use_https:insecure
+//     如 api:api.example.com:true:false、api:127.0.0.1:3000/v2:false:false
+//
+// 包含 "=" 的配置按 URL 格式解析，否则按旧格式解析
+func ParseProxySpec(spec string) (string, *ProxyConfig, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.Contains(spec, "=") {
+		return parseURLProxySpec(spec)
+	}
+	return parseLegacyProxySpec(spec)
+}
+
+// parseURLProxySpec 解析 URL 格式的代理配置
+func parseURLProxySpec(spec string) (string, *ProxyConfig, error) {
+	prefixPart, targetPart, _ := strings.Cut(spec, "=")
+
+	// 路径前缀可以包含多段，单独的 "/" 表示匹配所有路径
+	prefixPart = strings.TrimSpace(prefixPart)
+	if prefixPart == "" {
+		return "", nil, fmt.Errorf("invalid proxy config %q: path prefix is empty (expected: /path_prefix=scheme://host[:port][/base_path][?options], use / to match all paths)", spec)
+	}
+	pathPrefix := strings.Trim(prefixPart, "/")
+	if strings.Contains(pathPrefix, "//") || strings.ContainsAny(pathPrefix, "?# ") {
+		return "", nil, fmt.Errorf("invalid proxy config %q: invalid path prefix %q", spec, prefixPart)
+	}
+
+	targetPart = strings.TrimSpace(targetPart)
+	if !strings.Contains(targetPart, "://") {
+		return "", nil, fmt.Errorf("invalid proxy config %q: target %q must start with http:// or https://", spec, targetPart)
+	}
+
+	target, err := url.Parse(targetPart)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid proxy config %q: invalid target URL: %v", spec, err)
+	}
+
+	pc := &ProxyConfig{}
+	switch strings.ToLower(target.Scheme) {
+	case "http":
+	case "https":
+		pc.UseHTTPS = true
+	default:
+		return "", nil, fmt.Errorf("invalid proxy config %q: unsupported scheme %q (must be http or https)", spec, target.Scheme)
+	}
+
+	if target.User != nil {
+		return "", nil, fmt.Errorf("invalid proxy config %q: user info in target URL is not supported", spec)
+	}
+	if target.Fragment != "" {
+		return "", nil, fmt.Errorf("invalid proxy config %q: fragment in target URL is not supported", spec)
+	}
+
+	// 主机为空时沿用旧格式 target_domain 为空的行为：使用路径前缀作为目标域名
+	if target.Host != "" {
+		if strings.HasSuffix(target.Host, ":") {
+			return "", nil, fmt.Errorf("invalid proxy config %q: empty port in target URL", spec)
+		}
+		if port := target.Port(); port != "" {
+			n, err := parsePort(port)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid proxy config %q: %v", spec, err)
+			}
+			pc.TargetPort = n
+		}
+		pc.TargetDomain = target.Hostname()
+	} else if target.Path != "" && target.Path != "/" {
+		return "", nil, fmt.Errorf("invalid proxy config %q: base path requires a target host", spec)
+	}
+
+	// 基础路径：转发时拼接在请求路径之前
+	if target.Path != "" && target.Path != "/" {
+		pc.TargetPath = target.Path
+	}
+
+	if err := applyProxySpecOptions(pc, target.RawQuery); err != nil {
+		return "", nil, fmt.Errorf("invalid proxy config %q: %v", spec, err)
+	}
+
+	// 通过 upstream 选项配置了多个上游目标时，URL 中的主机作为第一个目标
+	if len(pc.Targets) > 0 {
+		if pc.TargetDomain == "" {
+			return "", nil, fmt.Errorf("invalid proxy config %q: upstream option requires a target host", spec)
+		}
+		primary := &UpstreamTarget{Address: pc.TargetAddr(""), Weight: 1}
+		pc.Targets = append([]*UpstreamTarget{primary}, pc.Targets...)
+		pc.TargetDomain = ""
+		pc.TargetPort = 0
+	}
+
+	// 多段前缀或带有主机、方法限制的路由需要显式记录路径前缀
+	name := pathPrefix
+	if len(pc.Hosts) > 0 || len(pc.Methods) > 0 {
+		name = strings.Join(pc.Hosts, ",") + "/" + pathPrefix
+		if len(pc.Methods) > 0 {
+			name = strings.Join(pc.Methods, ",") + " " + name
+		}
+	}
+	if name == "" {
+		name = "/"
+	}
+	if name != pathPrefix {
+		pc.PathPrefix = "/" + pathPrefix
+	}
+
+	return name, pc, nil
+}
+
+// splitSpecList 解析逗号分隔的选项值列表，忽略空项
+func splitSpecList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// applyProxySpecOptions 解析 URL 格式中的命名选项
+// 选项按出现顺序依次应用，可重复的选项（如 rewrite）按顺序追加，其余选项以最后一次为准
+// 选项值按路径规则解码（"+" 不会被解码为空格），便于书写正则表达式
+func applyProxySpecOptions(pc *ProxyConfig, rawQuery string) error {
+	if rawQuery == "" {
+		return nil
+	}
+
+	for _, option := range strings.Split(rawQuery, "&") {
+		if option == "" {
+			continue
+		}
+		rawName, rawValue, _ := strings.Cut(option, "=")
+		name, err := url.PathUnescape(rawName)
+		if err != nil {
+			return fmt.Errorf("invalid option name %q: %v", rawName, err)
+		}
+		value, err := url.PathUnescape(rawValue)
+		if err != nil {
+			return fmt.Errorf("invalid value for option %q: %v", name, err)
+		}
+
+		apply, ok := proxySpecOptions[name]
+		if !ok {
+			return fmt.Errorf("unknown option %q (supported: %s)", name, strings.Join(supportedProxySpecOptions(), ", "))
+		}
+		if err := apply(pc, value); err != nil {
+			return fmt.Errorf("invalid value for option %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// supportedProxySpecOptions 获取支持的命名选项列表
+func supportedProxySpecOptions() []string {
+	names := make([]string, 0, len(proxySpecOptions))
+	for name := range proxySpecOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseSpecBool 解析布尔选项，空值视为 true（如 ?insecure）
+func parseSpecBool(value string) (bool, error) {
+	if value == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%q is not a boolean", value)
+	}
+	return b, nil
+}
+
+// parseLegacyProxySpec 解析旧格式的代理配置
+// 格式：path_prefix:target_domain[:port][/base_path]:use_https:insecure
+// 如果 target_domain 为空，则使用 path_prefix 作为目标域名
+// 从两端解析：第一段为路径前缀，最后两段为布尔值，中间部分为目标地址，因此目标地址可以包含端口
+// IPv6 目标地址需要加方括号，如 api:[::1]:false:false、api:[::1]:8080:false:false
+func parseLegacyProxySpec(spec string) (string, *ProxyConfig, error) {
+	parts := strings.Split(spec, ":")
+
+	if len(parts) < 4 {
+		return "", nil, fmt.Errorf("invalid proxy config format: %s (expected: path_prefix:target_domain[:port][/base_path]:use_https:insecure or /path_prefix=scheme://host[:port][/base_path][?options])", spec)
+	}
+
+	n := len(parts)
+	pathPrefix := strings.TrimSpace(parts[0])
+	target := strings.TrimSpace(strings.Join(parts[1:n-2], ":"))
+	useHTTPSStr := strings.TrimSpace(parts[n-2])
+	insecureStr := strings.TrimSpace(parts[n-1])
+
+	// 解析 use_https
+	useHTTPS := false
+	if useHTTPSStr == "true" {
+		useHTTPS = true
+	} else if useHTTPSStr != "false" {
+		return "", nil, fmt.Errorf("invalid use_https value in %s: %s (must be true or false)", spec, useHTTPSStr)
+	}
+
+	// 解析 insecure
+	insecure := false
+	if insecureStr == "true" {
+		insecure = true
+	} else if insecureStr != "false" {
+		return "", nil, fmt.Errorf("invalid insecure value in %s: %s (must be true or false)", spec, insecureStr)
+	}
+
+	pc := &ProxyConfig{
+		UseHTTPS: useHTTPS,
+		Insecure: insecure,
+	}
+
+	// 解析目标地址：target_domain[:port][/base_path]
+	hostPort, basePath, hasPath := strings.Cut(target, "/")
+	if hasPath {
+		if hostPort == "" {
+			return "", nil, fmt.Errorf("invalid proxy config %s: base path requires a target domain", spec)
+		}
+		if basePath != "" {
+			pc.TargetPath = "/" + basePath
+		}
+	}
+
+	pc.TargetDomain = hostPort
+	switch {
+	case strings.HasPrefix(hostPort, "[") && strings.HasSuffix(hostPort, "]"):
+		// 不带端口的 IPv6 地址，如 [::1]
+		pc.TargetDomain = hostPort[1 : len(hostPort)-1]
+		if ip := net.ParseIP(pc.TargetDomain); ip == nil || ip.To4() != nil {
+			return "", nil, fmt.Errorf("invalid target address in %s: %q is not an IPv6 address", spec, hostPort)
+		}
+	case strings.Contains(hostPort, ":"):
+		host, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid target address in %s: %v", spec, err)
+		}
+		n, err := parsePort(port)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid target address in %s: %v", spec, err)
+		}
+		pc.TargetDomain = host
+		pc.TargetPort = n
+	}
+
+	return pathPrefix, pc, nil
+}
+
+// parsePort 解析端口号
+func parsePort(port string) (int, error) {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("invalid port %q (must be between 1 and 65535)", port)
+	}
+	return n, nil
+}
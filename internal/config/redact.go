@@ -0,0 +1,79 @@
+package config
+
+import "net/http"
+
+// RedactedValue 输出或记录配置时替代敏感值的占位符
+const RedactedValue = "***"
+
+// sensitiveHeaders 值属于凭据的请求头，输出配置时隐藏
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// Redacted 获取隐藏了敏感值的配置副本，用于 config print 和日志输出，不修改原配置
+// 隐藏的值：客户端证书的 pkcs12_password，以及 request_headers 中 Authorization、Proxy-Authorization、Cookie 的值
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.ProxyConfigs = redactProxyConfigs(c.ProxyConfigs)
+	if c.VirtualHosts != nil {
+		redacted.VirtualHosts = make([]*VirtualHost, len(c.VirtualHosts))
+		for i, vh := range c.VirtualHosts {
+			if vh == nil {
+				continue
+			}
+			copied := *vh
+			copied.ProxyConfigs = redactProxyConfigs(vh.ProxyConfigs)
+			redacted.VirtualHosts[i] = &copied
+		}
+	}
+	return &redacted
+}
+
+// redactProxyConfigs 获取隐藏了敏感值的代理配置映射副本
+func redactProxyConfigs(configs map[string]*ProxyConfig) map[string]*ProxyConfig {
+	if configs == nil {
+		return nil
+	}
+	redacted := make(map[string]*ProxyConfig, len(configs))
+	for name, pc := range configs {
+		redacted[name] = pc.redacted()
+	}
+	return redacted
+}
+
+// redacted 获取隐藏了敏感值的代理配置副本
+func (p *ProxyConfig) redacted() *ProxyConfig {
+	if p == nil {
+		return nil
+	}
+	copied := *p
+	if p.TLS != nil && p.TLS.PKCS12Password != "" {
+		tls := *p.TLS
+		tls.PKCS12Password = RedactedValue
+		copied.TLS = &tls
+	}
+	if p.RequestHeaders != nil {
+		headers := *p.RequestHeaders
+		headers.Set = redactHeaderValues(p.RequestHeaders.Set)
+		headers.Add = redactHeaderValues(p.RequestHeaders.Add)
+		copied.RequestHeaders = &headers
+	}
+	return &copied
+}
+
+// redactHeaderValues 获取隐藏了凭据类请求头的值的副本
+func redactHeaderValues(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			value = RedactedValue
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// secretConfig 创建包含客户端证书密码和凭据请求头的配置
+func secretConfig() *Config {
+	cfg := LoadConfig()
+	cfg.ProxyConfigs["api"] = &ProxyConfig{
+		TargetDomain: "api.internal",
+		UseHTTPS:     true,
+		TLS:          &UpstreamTLSConfig{PKCS12File: "client.p12", PKCS12Password: "s3cret-p12"},
+		RequestHeaders: &HeaderRules{
+			Set: map[string]string{"authorization": "Bearer s3cret-token", "X-Env": "prod"},
+			Add: map[string]string{"Cookie": "session=s3cret-cookie"},
+		},
+	}
+	cfg.VirtualHosts = []*VirtualHost{{
+		Name:  "site",
+		Hosts: []string{"example.com"},
+		ProxyConfigs: map[string]*ProxyConfig{
+			"api": {TargetDomain: "api.internal", TLS: &UpstreamTLSConfig{PKCS12File: "site.p12", PKCS12Password: "s3cret-site"}},
+		},
+	}}
+	return cfg
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := secretConfig()
+	data, err := json.Marshal(cfg.Redacted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	if strings.Contains(out, "s3cret") {
+		t.Errorf("redacted config contains a secret: %s", out)
+	}
+	for _, want := range []string{`"pkcs12_password":"***"`, `"authorization":"***"`, `"Cookie":"***"`, `"X-Env":"prod"`, `"pkcs12_file":"client.p12"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("redacted config missing %s: %s", want, out)
+		}
+	}
+
+	// 原配置不受影响
+	pc := cfg.ProxyConfigs["api"]
+	if pc.TLS.PKCS12Password != "s3cret-p12" || pc.RequestHeaders.Set["authorization"] != "Bearer s3cret-token" {
+		t.Error("Redacted() modified the original proxy config")
+	}
+	if cfg.VirtualHosts[0].ProxyConfigs["api"].TLS.PKCS12Password != "s3cret-site" {
+		t.Error("Redacted() modified the original virtual host")
+	}
+}
+
+func TestRedactedWithoutSecrets(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.ProxyConfigs["api"] = &ProxyConfig{TargetDomain: "api.internal", TLS: &UpstreamTLSConfig{PKCS12File: "client.p12"}}
+	// 没有密码时不输出占位符
+	if got := cfg.Redacted().ProxyConfigs["api"].TLS.PKCS12Password; got != "" {
+		t.Errorf("pkcs12_password = %q, want empty", got)
+	}
+}
+
+func TestEffectiveValuesRedacted(t *testing.T) {
+	cfg := secretConfig()
+	cfg.SetProxySource("api", SourceFlag+":--proxy")
+	values, err := cfg.EffectiveValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("effective values contain a secret: %s", data)
+	}
+	for _, v := range values {
+		if v.Key == "proxy_configs.api" && v.Source != SourceFlag+":--proxy" {
+			t.Errorf("source of %s = %q, want flag:--proxy", v.Key, v.Source)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 重试的失败类型
+const (
+	RetryOnConnectError    = "connect_error"    // 连接上游失败（请求尚未发出）
+	RetryOnConnectionReset = "connection_reset" // 连接被重置或在收到响应前关闭
+	RetryOnTimeout         = "timeout"          // 等待响应超时
+	RetryOn5xx             = "5xx"              // 任意 5xx 响应
+)
+
+// 重试的默认值
+const (
+	DefaultRetryAttempts    = 3
+	DefaultRetryBackoff     = Duration(100 * time.Millisecond)
+	DefaultRetryMaxBackoff  = Duration(1 * time.Second)
+	DefaultRetryMaxBodySize = 1 << 20
+)
+
+// idempotentMethods 幂等的请求方法（RFC 9110），默认只重试这些方法
+var idempotentMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete,
+}
+
+// RetryConfig 重试配置
+// 请求体在重试前缓存在内存中，超过 max_body_size 的请求不重试
+type RetryConfig struct {
+	Attempts           int      `json:"attempts,omitempty"`             // 总尝试次数（包括第一次），默认 3
+	Methods            []string `json:"methods,omitempty"`              // 允许重试的请求方法，默认为幂等方法（GET、HEAD、OPTIONS、TRACE、PUT、DELETE）
+	RetryNonIdempotent bool     `json:"retry_non_idempotent,omitempty"` // 是否允许重试非幂等方法（如 POST），开启后 methods 默认为所有方法
+	RetryOn            []string `json:"retry_on,omitempty"`             // 重试的失败类型：connect_error、connection_reset、timeout、5xx 或具体状态码，默认 connect_error 和 connection_reset
+	Backoff            Duration `json:"backoff,omitempty"`              // 第一次重试前的等待时间，之后每次翻倍，默认 100ms
+	MaxBackoff         Duration `json:"max_backoff,omitempty"`          // 最长等待时间，默认 1s
+	MaxBodySize        int64    `json:"max_body_size,omitempty"`        // 为重试缓存的最大请求体字节数，默认 1MiB
+}
+
+// WithDefaults 返回填充默认值后的重试配置
+func (r *RetryConfig) WithDefaults() RetryConfig {
+	settings := *r
+	if settings.Attempts == 0 {
+		settings.Attempts = DefaultRetryAttempts
+	}
+	if len(settings.RetryOn) == 0 {
+		settings.RetryOn = []string{RetryOnConnectError, RetryOnConnectionReset}
+	}
+	if settings.Backoff == 0 {
+		settings.Backoff = DefaultRetryBackoff
+	}
+	if settings.MaxBackoff == 0 {
+		settings.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if settings.MaxBodySize == 0 {
+		settings.MaxBodySize = DefaultRetryMaxBodySize
+	}
+	return settings
+}
+
+// AllowsMethod 判断请求方法是否允许重试
+func (r *RetryConfig) AllowsMethod(method string) bool {
+	if len(r.Methods) == 0 {
+		return r.RetryNonIdempotent || isIdempotent(method)
+	}
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetriesOn 判断失败类型是否需要重试
+func (r *RetryConfig) RetriesOn(kind string) bool {
+	for _, on := range r.RetryOn {
+		if on == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// RetriesOnStatus 判断响应状态码是否需要重试
+func (r *RetryConfig) RetriesOnStatus(status int) bool {
+	code := strconv.Itoa(status)
+	for _, on := range r.RetryOn {
+		if on == code || (on == RetryOn5xx && status >= http.StatusInternalServerError) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdempotent 判断请求方法是否幂等
+func isIdempotent(method string) bool {
+	for _, m := range idempotentMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 验证重试配置，name 为路由名称
+func (r *RetryConfig) validate(name string) []error {
+	if r == nil {
+		return nil
+	}
+
+	var errs []error
+	if r.Attempts < 0 {
+		errs = append(errs, fmt.Errorf("proxy %s: retry.attempts must not be negative, got %d", name, r.Attempts))
+	}
+	for _, method := range r.Methods {
+		if !isValidMethod(method) {
+			errs = append(errs, fmt.Errorf("proxy %s: invalid method %q in retry.methods", name, method))
+		} else if !isIdempotent(method) && !r.RetryNonIdempotent {
+			errs = append(errs, fmt.Errorf("proxy %s: retry.methods contains non-idempotent method %s, set retry_non_idempotent to allow it", name, method))
+		}
+	}
+	for _, on := range r.RetryOn {
+		switch on {
+		case RetryOnConnectError, RetryOnConnectionReset, RetryOnTimeout, RetryOn5xx:
+			continue
+		}
+		if status, err := strconv.Atoi(on); err != nil || status < 100 || status > 599 {
+			errs = append(errs, fmt.Errorf("proxy %s: invalid retry_on value %q (must be %s, %s, %s, %s or a status code such as 502)",
+				name, on, RetryOnConnectError, RetryOnConnectionReset, RetryOnTimeout, RetryOn5xx))
+		}
+	}
+	if r.Backoff < 0 || r.MaxBackoff < 0 {
+		errs = append(errs, fmt.Errorf("proxy %s: retry.backoff and retry.max_backoff must not be negative", name))
+	}
+	if r.MaxBodySize < 0 {
+		errs = append(errs, fmt.Errorf("proxy %s: retry.max_body_size must not be negative, got %d", name, r.MaxBodySize))
+	}
+	return errs
+}
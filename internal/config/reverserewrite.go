@@ -0,0 +1,50 @@
+package config
+
+import "fmt"
+
+// Set-Cookie 的 Secure 属性处理方式
+const (
+	CookieSecureAuto  = "auto"  // 客户端通过 HTTP 访问时移除 Secure，通过 HTTPS 访问时保留
+	CookieSecureKeep  = "keep"  // 保留上游设置的 Secure
+	CookieSecureStrip = "strip" // 始终移除 Secure
+)
+
+// ReverseRewriteConfig 响应头反向改写配置
+// 将上游响应头中指向上游的地址映射回代理的路径前缀和客户端访问的主机，避免重定向跳出代理、Cookie 无法回到代理路径
+type ReverseRewriteConfig struct {
+	Location     *bool  `json:"location,omitempty"`      // 是否改写 Location、Content-Location 和 Refresh，默认 true
+	Cookies      *bool  `json:"cookies,omitempty"`       // 是否改写 Set-Cookie 的 Domain、Path 和 Secure 属性，默认 true
+	CookieSecure string `json:"cookie_secure,omitempty"` // Secure 属性的处理方式：auto（默认）、keep、strip
+}
+
+// RewritesLocation 判断是否改写 Location、Content-Location 和 Refresh
+func (r *ReverseRewriteConfig) RewritesLocation() bool {
+	return r.Location == nil || *r.Location
+}
+
+// RewritesCookies 判断是否改写 Set-Cookie
+func (r *ReverseRewriteConfig) RewritesCookies() bool {
+	return r.Cookies == nil || *r.Cookies
+}
+
+// GetCookieSecure 获取 Secure 属性的处理方式，未设置时为 auto
+func (r *ReverseRewriteConfig) GetCookieSecure() string {
+	if r.CookieSecure == "" {
+		return CookieSecureAuto
+	}
+	return r.CookieSecure
+}
+
+// validate 验证响应头反向改写配置，name 为路由名称
+func (r *ReverseRewriteConfig) validate(name string) []error {
+	if r == nil {
+		return nil
+	}
+	switch r.CookieSecure {
+	case "", CookieSecureAuto, CookieSecureKeep, CookieSecureStrip:
+		return nil
+	default:
+		return []error{fmt.Errorf("proxy %s: invalid reverse_rewrite.cookie_secure %q (must be %s, %s or %s)",
+			name, r.CookieSecure, CookieSecureAuto, CookieSecureKeep, CookieSecureStrip)}
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// RewriteSeparator 命令行 rewrite 选项中正则表达式与替换内容的分隔符
+const RewriteSeparator = "->"
+
+// RewriteRule 正则路径重写规则
+type RewriteRule struct {
+	Match   string `json:"match"`   // 匹配请求路径（包含路径前缀）的正则表达式，如 ^/api/v1/(.*)
+	Replace string `json:"replace"` // 替换内容，支持 $1、${name} 引用分组，如 /v1/$1
+
+	re *regexp.Regexp // 编译后的正则表达式
+}
+
+// compile 编译正则表达式，在配置校验时调用
+func (r *RewriteRule) compile() error {
+	if r == nil {
+		return fmt.Errorf("empty rewrite rule")
+	}
+	if r.Match == "" {
+		return fmt.Errorf("match pattern is empty")
+	}
+	re, err := regexp.Compile(r.Match)
+	if err != nil {
+		return fmt.Errorf("invalid match pattern %q: %v", r.Match, err)
+	}
+	r.re = re
+	return nil
+}
+
+// Apply 对路径应用重写规则，返回重写后的路径以及是否匹配
+// 规则未经过配置校验编译时在此编译，编译失败视为不匹配
+func (r *RewriteRule) Apply(path string) (string, bool) {
+	re := r.re
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(r.Match); err != nil {
+			return path, false
+		}
+	}
+	if !re.MatchString(path) {
+		return path, false
+	}
+	return re.ReplaceAllString(path, r.Replace), true
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// 配置值来源
+const (
+	SourceDefault = "default" // 默认值
+	SourceFile    = "file"    // 配置文件
+	SourceEnv     = "env"     // 环境变量
+	SourceFlag    = "flag"    // 命令行参数
+)
+
+// proxyConfigKeyPrefix 代理配置在来源记录中的键前缀，完整键为 proxy_configs.{路由名称}
+const proxyConfigKeyPrefix = "proxy_configs."
+
+// ValueSource 单个配置项的生效值及其来源
+type ValueSource struct {
+	Key    string      `json:"key"`    // 配置项名称（与配置文件中的字段名一致）
+	Value  interface{} `json:"value"`  // 生效值
+	Source string      `json:"source"` // 来源，如 default、file:serve.yaml、env:SERVE_HOST、flag:--host
+}
+
+// SetSource 记录配置项的来源
+func (c *Config) SetSource(key, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[key] = source
+}
+
+// Source 获取配置项的来源，未记录时视为默认值
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// SetProxySource 记录指定路由名称的代理配置来源
+func (c *Config) SetProxySource(name, source string) {
+	c.SetSource(proxyConfigKeyPrefix+name, source)
+}
+
+// EffectiveValues 获取所有配置项的生效值及来源
+// 顶层字段按名称排序，代理配置按路由名称展开为 proxy_configs.{路由名称}；生效值用于输出和日志，其中的敏感值已隐藏（见 Redacted）
+func (c *Config) EffectiveValues() ([]ValueSource, error) {
+	c = c.Redacted()
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	var values []ValueSource
+	for key, raw := range fields {
+		if key == "proxy_configs" {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+		values = append(values, ValueSource{Key: key, Value: value, Source: c.Source(key)})
+	}
+
+	for name, proxyConfig := range c.ProxyConfigs {
+		key := proxyConfigKeyPrefix + name
+		values = append(values, ValueSource{Key: key, Value: proxyConfig, Source: c.Source(key)})
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Key < values[j].Key
+	})
+
+	return values, nil
+}
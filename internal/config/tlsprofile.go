@@ -0,0 +1,253 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// TLS 配置档位
+const (
+	TLSProfileLegacy       = "legacy"       // 默认，兼容 Android 4 等旧客户端：TLS 1.0 起，包含 CBC 和 3DES 加密套件
+	TLSProfileIntermediate = "intermediate" // TLS 1.2 起，仅使用支持前向保密的 AEAD 加密套件
+	TLSProfileModern       = "modern"       // 仅 TLS 1.3
+)
+
+// DefaultTLSProfile 未设置时使用的 TLS 配置档位
+const DefaultTLSProfile = TLSProfileLegacy
+
+// tlsProfiles 各档位的协议版本和加密套件（TLS 1.3 的加密套件不可配置）
+var tlsProfiles = map[string]struct {
+	minVersion uint16
+	ciphers    []uint16
+}{
+	TLSProfileLegacy: {
+		minVersion: tls.VersionTLS10,
+		ciphers: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+		},
+	},
+	TLSProfileIntermediate: {
+		minVersion: tls.VersionTLS12,
+		ciphers: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+	},
+	TLSProfileModern: {
+		minVersion: tls.VersionTLS13,
+	},
+}
+
+// tlsVersions 协议版本名称
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsCurves 密钥交换曲线名称，同时接受常见的别名
+var tlsCurves = map[string]tls.CurveID{
+	"x25519":         tls.X25519,
+	"x25519mlkem768": tls.X25519MLKEM768,
+	"p256":           tls.CurveP256,
+	"p-256":          tls.CurveP256,
+	"secp256r1":      tls.CurveP256,
+	"p384":           tls.CurveP384,
+	"p-384":          tls.CurveP384,
+	"secp384r1":      tls.CurveP384,
+	"p521":           tls.CurveP521,
+	"p-521":          tls.CurveP521,
+	"secp521r1":      tls.CurveP521,
+}
+
+// TLSProfileConfig TLS 协议版本、加密套件和曲线设置，用于 HTTPS 监听器和上游连接
+// 先按 profile 选择档位，再用 min_version、max_version、ciphers、curves 覆盖档位中的对应设置
+type TLSProfileConfig struct {
+	Profile    string   `json:"profile,omitempty"`     // 档位：legacy（默认）、intermediate、modern
+	MinVersion string   `json:"min_version,omitempty"` // 最低协议版本：1.0、1.1、1.2、1.3
+	MaxVersion string   `json:"max_version,omitempty"` // 最高协议版本，默认 1.3
+	Ciphers    []string `json:"ciphers,omitempty"`     // TLS 1.0-1.2 的加密套件（IANA 名称，如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256），按优先级排列
+	Curves     []string `json:"curves,omitempty"`      // 密钥交换曲线：X25519、X25519MLKEM768、P256、P384、P521，为空时使用 Go 的默认值
+}
+
+// GetProfile 获取档位名称，未设置时为 legacy
+func (t *TLSProfileConfig) GetProfile() string {
+	if t == nil || t.Profile == "" {
+		return DefaultTLSProfile
+	}
+	return strings.ToLower(t.Profile)
+}
+
+// Apply 将协议版本、加密套件和曲线设置写入 tls.Config，t 为 nil 时使用默认档位
+func (t *TLSProfileConfig) Apply(cfg *tls.Config) error {
+	profile, ok := tlsProfiles[t.GetProfile()]
+	if !ok {
+		return fmt.Errorf("unknown TLS profile %q (must be %s, %s or %s)",
+			t.Profile, TLSProfileLegacy, TLSProfileIntermediate, TLSProfileModern)
+	}
+	cfg.MinVersion = profile.minVersion
+	cfg.MaxVersion = tls.VersionTLS13
+	cfg.CipherSuites = append([]uint16(nil), profile.ciphers...)
+	cfg.CurvePreferences = nil
+	if t == nil {
+		return nil
+	}
+
+	var err error
+	if t.MinVersion != "" {
+		if cfg.MinVersion, err = parseTLSVersion(t.MinVersion); err != nil {
+			return fmt.Errorf("min_version: %v", err)
+		}
+	}
+	if t.MaxVersion != "" {
+		if cfg.MaxVersion, err = parseTLSVersion(t.MaxVersion); err != nil {
+			return fmt.Errorf("max_version: %v", err)
+		}
+	}
+	if cfg.MinVersion > cfg.MaxVersion {
+		return fmt.Errorf("min_version %s is higher than max_version %s", tlsVersionName(cfg.MinVersion), tlsVersionName(cfg.MaxVersion))
+	}
+
+	if len(t.Ciphers) > 0 {
+		if cfg.MinVersion == tls.VersionTLS13 {
+			return fmt.Errorf("ciphers cannot be configured for TLS 1.3 only (cipher suites are fixed in TLS 1.3)")
+		}
+		cfg.CipherSuites = nil
+		for _, name := range t.Ciphers {
+			id, err := parseCipherSuite(name)
+			if err != nil {
+				return err
+			}
+			cfg.CipherSuites = append(cfg.CipherSuites, id)
+		}
+	}
+	for _, name := range t.Curves {
+		id, ok := tlsCurves[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return fmt.Errorf("unknown curve %q (must be X25519, X25519MLKEM768, P256, P384 or P521)", name)
+		}
+		cfg.CurvePreferences = append(cfg.CurvePreferences, id)
+	}
+	return nil
+}
+
+// Describe 获取生效的协议版本和档位描述，用于日志，如 profile=legacy versions=TLS1.0-TLS1.3
+func (t *TLSProfileConfig) Describe() string {
+	var cfg tls.Config
+	if err := t.Apply(&cfg); err != nil {
+		return "invalid: " + err.Error()
+	}
+	desc := fmt.Sprintf("profile=%s versions=%s-%s", t.GetProfile(), tlsVersionName(cfg.MinVersion), tlsVersionName(cfg.MaxVersion))
+	if t != nil && (t.MinVersion != "" || t.MaxVersion != "" || len(t.Ciphers) > 0 || len(t.Curves) > 0) {
+		desc += " (customized)"
+	}
+	return desc
+}
+
+// WeakSettings 列出生效设置中的弱项：TLS 1.2 以下的协议版本，以及 Go 标记为不安全的加密套件（RC4、3DES、CBC-SHA256 和不支持前向保密的 RSA 密钥交换）
+func (t *TLSProfileConfig) WeakSettings() []string {
+	var cfg tls.Config
+	if err := t.Apply(&cfg); err != nil {
+		return nil
+	}
+
+	var weak []string
+	if cfg.MinVersion < tls.VersionTLS12 {
+		weak = append(weak, fmt.Sprintf("%s enabled", tlsVersionName(cfg.MinVersion)))
+	}
+	if cfg.MinVersion == tls.VersionTLS13 {
+		return weak
+	}
+	insecure := make(map[uint16]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.ID] = true
+	}
+	for _, id := range cfg.CipherSuites {
+		if insecure[id] {
+			weak = append(weak, tls.CipherSuiteName(id))
+		}
+	}
+	return weak
+}
+
+// isSet 判断是否设置了任意一项
+func (t *TLSProfileConfig) isSet() bool {
+	return t != nil && (t.Profile != "" || t.MinVersion != "" || t.MaxVersion != "" || len(t.Ciphers) > 0 || len(t.Curves) > 0)
+}
+
+// validate 验证 TLS 设置，label 为错误信息前缀（如 proxy api: tls、listener https://:8443: tls）
+func (t *TLSProfileConfig) validate(label string) []error {
+	if t == nil {
+		return nil
+	}
+	if err := t.Apply(&tls.Config{}); err != nil {
+		return []error{fmt.Errorf("%s: %v", label, err)}
+	}
+	return nil
+}
+
+// parseTLSVersion 解析协议版本，接受 1.2、TLS1.2、tls1.2 等写法
+func parseTLSVersion(value string) (uint16, error) {
+	name := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "tls")
+	name = strings.TrimSpace(strings.TrimPrefix(name, "v"))
+	version, ok := tlsVersions[name]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version %q (must be 1.0, 1.1, 1.2 or 1.3)", value)
+	}
+	return version, nil
+}
+
+// tlsVersionName 获取协议版本的名称，如 TLS1.2
+func tlsVersionName(version uint16) string {
+	for name, v := range tlsVersions {
+		if v == version {
+			return "TLS" + name
+		}
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
+// parseCipherSuite 按 IANA 名称（不区分大小写）查找加密套件，包括 Go 标记为不安全的加密套件
+func parseCipherSuite(name string) (uint16, error) {
+	name = strings.TrimSpace(name)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if !strings.EqualFold(suite.Name, name) {
+			continue
+		}
+		if !supportsTLS12OrLower(suite) {
+			return 0, fmt.Errorf("cipher suite %s is TLS 1.3 only and cannot be configured", suite.Name)
+		}
+		return suite.ID, nil
+	}
+	return 0, fmt.Errorf("unknown cipher suite %q", name)
+}
+
+// supportsTLS12OrLower 判断加密套件是否可用于 TLS 1.2 及以下版本
+func supportsTLS12OrLower(suite *tls.CipherSuite) bool {
+	for _, v := range suite.SupportedVersions {
+		if v <= tls.VersionTLS12 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"crypto/tls"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultTLSProfile(t *testing.T) {
+	var unset *TLSProfileConfig
+	for _, profile := range []*TLSProfileConfig{unset, {}} {
+		if got := profile.GetProfile(); got != TLSProfileLegacy {
+			t.Errorf("GetProfile() = %q, want %q when unset", got, TLSProfileLegacy)
+		}
+
+		var cfg tls.Config
+		if err := profile.Apply(&cfg); err != nil {
+			t.Fatalf("Apply() error: %v", err)
+		}
+		if cfg.MinVersion != tls.VersionTLS10 {
+			t.Errorf("MinVersion = %s, want TLS1.0 for the default profile", tlsVersionName(cfg.MinVersion))
+		}
+		// 默认档位包含弱项，启动时需要输出警告
+		if weak := profile.WeakSettings(); len(weak) == 0 {
+			t.Error("WeakSettings() is empty for the default profile, want a warning")
+		}
+	}
+}
+
+func TestWeakSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  *TLSProfileConfig
+		wantWeak bool
+	}{
+		{"legacy", &TLSProfileConfig{Profile: TLSProfileLegacy}, true},
+		{"intermediate", &TLSProfileConfig{Profile: TLSProfileIntermediate}, false},
+		{"modern", &TLSProfileConfig{Profile: TLSProfileModern}, false},
+		{"intermediate with TLS 1.1", &TLSProfileConfig{Profile: TLSProfileIntermediate, MinVersion: "1.1"}, true},
+		{"intermediate with 3DES", &TLSProfileConfig{Profile: TLSProfileIntermediate, Ciphers: []string{"TLS_RSA_WITH_3DES_EDE_CBC_SHA"}}, true},
+	}
+	for _, tt := range tests {
+		if weak := tt.profile.WeakSettings(); (len(weak) > 0) != tt.wantWeak {
+			t.Errorf("%s: WeakSettings() = %v, want weak=%t", tt.name, weak, tt.wantWeak)
+		}
+	}
+}
+
+func TestTLSProfileApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		profile    *TLSProfileConfig
+		minVersion uint16
+		maxVersion uint16
+		ciphers    []uint16
+		curves     []tls.CurveID
+	}{
+		{
+			name:       "intermediate",
+			profile:    &TLSProfileConfig{Profile: "Intermediate"},
+			minVersion: tls.VersionTLS12,
+			maxVersion: tls.VersionTLS13,
+			ciphers:    tlsProfiles[TLSProfileIntermediate].ciphers,
+		},
+		{
+			name:       "modern",
+			profile:    &TLSProfileConfig{Profile: TLSProfileModern},
+			minVersion: tls.VersionTLS13,
+			maxVersion: tls.VersionTLS13,
+		},
+		{
+			name: "overrides",
+			profile: &TLSProfileConfig{
+				Profile:    TLSProfileModern,
+				MinVersion: "1.2",
+				MaxVersion: "TLS1.2",
+				Ciphers:    []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+				Curves:     []string{"x25519", "secp384r1"},
+			},
+			minVersion: tls.VersionTLS12,
+			maxVersion: tls.VersionTLS12,
+			ciphers:    []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+			curves:     []tls.CurveID{tls.X25519, tls.CurveP384},
+		},
+	}
+	for _, tt := range tests {
+		cfg := tls.Config{CurvePreferences: []tls.CurveID{tls.CurveP521}}
+		if err := tt.profile.Apply(&cfg); err != nil {
+			t.Errorf("%s: Apply() error: %v", tt.name, err)
+			continue
+		}
+		if cfg.MinVersion != tt.minVersion || cfg.MaxVersion != tt.maxVersion {
+			t.Errorf("%s: versions %s-%s, want %s-%s", tt.name,
+				tlsVersionName(cfg.MinVersion), tlsVersionName(cfg.MaxVersion), tlsVersionName(tt.minVersion), tlsVersionName(tt.maxVersion))
+		}
+		if !reflect.DeepEqual(cfg.CipherSuites, tt.ciphers) {
+			t.Errorf("%s: CipherSuites = %v, want %v", tt.name, cfg.CipherSuites, tt.ciphers)
+		}
+		if !reflect.DeepEqual(cfg.CurvePreferences, tt.curves) {
+			t.Errorf("%s: CurvePreferences = %v, want %v", tt.name, cfg.CurvePreferences, tt.curves)
+		}
+	}
+
+	errs := map[string]*TLSProfileConfig{
+		`unknown TLS profile "strict"`:                         {Profile: "strict"},
+		"ciphers cannot be configured for TLS 1.3":             {Profile: TLSProfileModern, Ciphers: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+		`unknown cipher suite "TLS_RSA_WITH_RC5"`:              {Ciphers: []string{"TLS_RSA_WITH_RC5"}},
+		`unknown curve "P192"`:                                 {Curves: []string{"P192"}},
+		"min_version TLS1.3 is higher than max_version TLS1.2": {MinVersion: "1.3", MaxVersion: "1.2"},
+	}
+	for want, profile := range errs {
+		if err := profile.Apply(&tls.Config{}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Apply(%+v) error = %v, want containing %q", *profile, err, want)
+		}
+	}
+}
+
+func TestTLSProfileDescribe(t *testing.T) {
+	tests := []struct {
+		profile *TLSProfileConfig
+		want    string
+	}{
+		{nil, "profile=legacy versions=TLS1.0-TLS1.3"},
+		{&TLSProfileConfig{Profile: TLSProfileModern}, "profile=modern versions=TLS1.3-TLS1.3"},
+		{&TLSProfileConfig{Profile: TLSProfileIntermediate, MaxVersion: "1.2"}, "profile=intermediate versions=TLS1.2-TLS1.2 (customized)"},
+	}
+	for _, tt := range tests {
+		if got := tt.profile.Describe(); got != tt.want {
+			t.Errorf("Describe() = %q, want %q", got, tt.want)
+		}
+	}
+}
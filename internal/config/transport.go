@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 上游连接设置的默认值
+const (
+	DefaultDialTimeout         = Duration(10 * time.Second)
+	DefaultKeepAlive           = Duration(30 * time.Second)
+	DefaultIdleConnTimeout     = Duration(90 * time.Second)
+	DefaultTLSHandshakeTimeout = Duration(10 * time.Second)
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 32
+)
+
+// TransportConfig 上游连接设置，每个代理路由持有一个长期复用的连接池
+// 未设置（为 0）的项使用默认值
+type TransportConfig struct {
+	DialTimeout           Duration `json:"dial_timeout,omitempty"`            // 建立 TCP 连接的超时时间，默认 10s
+	KeepAlive             Duration `json:"keep_alive,omitempty"`              // TCP keep-alive 探测间隔，默认 30s，为负数时关闭 TCP keep-alive
+	DisableKeepAlives     bool     `json:"disable_keep_alives,omitempty"`     // 是否禁用连接复用，禁用后每个请求使用新连接
+	MaxIdleConns          int      `json:"max_idle_conns,omitempty"`          // 连接池最大空闲连接数，默认 100
+	MaxIdleConnsPerHost   int      `json:"max_idle_conns_per_host,omitempty"` // 每个目标地址的最大空闲连接数，默认 32
+	MaxConnsPerHost       int      `json:"max_conns_per_host,omitempty"`      // 每个目标地址的最大连接数，为 0 时不限制
+	IdleConnTimeout       Duration `json:"idle_conn_timeout,omitempty"`       // 空闲连接保留时间，默认 90s
+	TLSHandshakeTimeout   Duration `json:"tls_handshake_timeout,omitempty"`   // TLS 握手超时时间，默认 10s
+	ResponseHeaderTimeout Duration `json:"response_header_timeout,omitempty"` // 等待上游响应头的超时时间，为 0 时不限制
+}
+
+// WithDefaults 返回填充默认值后的连接设置，t 为 nil 时返回全部默认值
+func (t *TransportConfig) WithDefaults() TransportConfig {
+	var settings TransportConfig
+	if t != nil {
+		settings = *t
+	}
+	if settings.DialTimeout == 0 {
+		settings.DialTimeout = DefaultDialTimeout
+	}
+	if settings.KeepAlive == 0 {
+		settings.KeepAlive = DefaultKeepAlive
+	}
+	if settings.MaxIdleConns == 0 {
+		settings.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if settings.MaxIdleConnsPerHost == 0 {
+		settings.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if settings.IdleConnTimeout == 0 {
+		settings.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if settings.TLSHandshakeTimeout == 0 {
+		settings.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+	return settings
+}
+
+// validate 验证连接设置，name 为路由名称
+func (t *TransportConfig) validate(name string) []error {
+	if t == nil {
+		return nil
+	}
+
+	var errs []error
+	durations := []struct {
+		key   string
+		value Duration
+	}{
+		{"dial_timeout", t.DialTimeout},
+		{"idle_conn_timeout", t.IdleConnTimeout},
+		{"tls_handshake_timeout", t.TLSHandshakeTimeout},
+		{"response_header_timeout", t.ResponseHeaderTimeout},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("proxy %s: transport.%s must not be negative, got %s", name, d.key, d.value))
+		}
+	}
+
+	counts := []struct {
+		key   string
+		value int
+	}{
+		{"max_idle_conns", t.MaxIdleConns},
+		{"max_idle_conns_per_host", t.MaxIdleConnsPerHost},
+		{"max_conns_per_host", t.MaxConnsPerHost},
+	}
+	for _, c := range counts {
+		if c.value < 0 {
+			errs = append(errs, fmt.Errorf("proxy %s: transport.%s must not be negative, got %d", name, c.key, c.value))
+		}
+	}
+
+	return errs
+}
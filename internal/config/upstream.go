@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// 负载均衡策略
+const (
+	PolicyRoundRobin = "round_robin" // 加权轮询（默认）
+	PolicyLeastConn  = "least_conn"  // 加权最少连接
+	PolicyRandomTwo  = "random_two"  // 加权随机选取两个目标，使用连接数较少的一个
+	PolicyHash       = "hash"        // 按请求头或 Cookie 一致性哈希，未携带时按客户端 IP
+)
+
+// MaxUpstreamWeight 上游目标的最大权重
+const MaxUpstreamWeight = 1000
+
+// UpstreamWeightSeparator 命令行 upstream 选项中地址与权重的分隔符，如 10.0.0.2:8080*3
+const UpstreamWeightSeparator = "*"
+
+// UpstreamTarget 上游目标
+type UpstreamTarget struct {
+	Address string `json:"address"`          // 目标地址 host[:port]，同时作为转发请求的 Host 头
+	Weight  int    `json:"weight,omitempty"` // 权重，默认 1
+}
+
+// LoadBalancerConfig 负载均衡配置
+type LoadBalancerConfig struct {
+	Policy     string `json:"policy,omitempty"`      // 负载均衡策略：round_robin（默认）、least_conn、random_two、hash
+	HashHeader string `json:"hash_header,omitempty"` // hash 策略使用的请求头
+	HashCookie string `json:"hash_cookie,omitempty"` // hash 策略使用的 Cookie
+}
+
+// GetPolicy 获取负载均衡策略，未配置时使用轮询
+func (lb *LoadBalancerConfig) GetPolicy() string {
+	if lb == nil || lb.Policy == "" {
+		return PolicyRoundRobin
+	}
+	return lb.Policy
+}
+
+// UpstreamTargets 获取路由的上游目标列表
+// 未配置 targets 时由 target_domain 和 target_port 组成单个目标（未配置目标域名时使用路径前缀）
+func (p *ProxyConfig) UpstreamTargets(pathPrefix string) []*UpstreamTarget {
+	if len(p.Targets) > 0 {
+		return p.Targets
+	}
+	return []*UpstreamTarget{{Address: p.TargetAddr(pathPrefix), Weight: 1}}
+}
+
+// ParseUpstreamTarget 解析命令行中的上游目标，格式 host[:port][*weight]
+func ParseUpstreamTarget(spec string) (*UpstreamTarget, error) {
+	target := &UpstreamTarget{Address: strings.TrimSpace(spec)}
+	if address, weight, ok := strings.Cut(target.Address, UpstreamWeightSeparator); ok {
+		n, err := strconv.Atoi(weight)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight %q in upstream %q", weight, spec)
+		}
+		target.Address = address
+		target.Weight = n
+	}
+	return target, nil
+}
+
+// validateTargets 验证上游目标和负载均衡配置，并规范化目标地址和权重
+func (p *ProxyConfig) validateTargets(name string) []error {
+	var errs []error
+	if len(p.Targets) > 0 && (p.TargetDomain != "" || p.TargetPort != 0) {
+		errs = append(errs, fmt.Errorf("proxy %s: targets cannot be combined with target_domain or target_port", name))
+	}
+
+	seen := make(map[string]bool)
+	for i, target := range p.Targets {
+		if target == nil {
+			errs = append(errs, fmt.Errorf("proxy %s: targets[%d] is empty", name, i))
+			continue
+		}
+		if err := target.normalize(); err != nil {
+			errs = append(errs, fmt.Errorf("proxy %s: targets[%d]: %v", name, i, err))
+			continue
+		}
+		if seen[target.Address] {
+			errs = append(errs, fmt.Errorf("proxy %s: duplicate target %q", name, target.Address))
+		}
+		seen[target.Address] = true
+	}
+
+	if lb := p.LoadBalancer; lb != nil {
+		switch lb.Policy {
+		case "", PolicyRoundRobin, PolicyLeastConn, PolicyRandomTwo:
+			if lb.HashHeader != "" || lb.HashCookie != "" {
+				errs = append(errs, fmt.Errorf("proxy %s: load_balancer.hash_header and hash_cookie require policy %s", name, PolicyHash))
+			}
+		case PolicyHash:
+			if (lb.HashHeader == "") == (lb.HashCookie == "") {
+				errs = append(errs, fmt.Errorf("proxy %s: load_balancer policy %s requires exactly one of hash_header or hash_cookie", name, PolicyHash))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("proxy %s: invalid load_balancer policy %q (must be %s, %s, %s or %s)",
+				name, lb.Policy, PolicyRoundRobin, PolicyLeastConn, PolicyRandomTwo, PolicyHash))
+		}
+	}
+
+	return errs
+}
+
+// normalize 验证并规范化上游目标：IPv6 地址加方括号，权重默认为 1
+func (t *UpstreamTarget) normalize() error {
+	address := strings.TrimSpace(t.Address)
+	if address == "" {
+		return fmt.Errorf("address is empty")
+	}
+	if strings.ContainsAny(address, "/?# ") {
+		return fmt.Errorf("address %q must be host[:port] without scheme or path", t.Address)
+	}
+	// 已加方括号的 IPv6 地址（规范化后的结果）保持不变
+	host := address
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		address = "[" + host + "]"
+	} else if strings.Contains(address, ":") {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return fmt.Errorf("invalid address %q: %v", t.Address, err)
+		}
+		if host == "" {
+			return fmt.Errorf("invalid address %q: host is empty", t.Address)
+		}
+		if _, err := parsePort(port); err != nil {
+			return fmt.Errorf("invalid address %q: %v", t.Address, err)
+		}
+	}
+	t.Address = address
+
+	if t.Weight == 0 {
+		t.Weight = 1
+	}
+	if t.Weight < 0 || t.Weight > MaxUpstreamWeight {
+		return fmt.Errorf("invalid weight %d for %q (must be between 1 and %d)", t.Weight, t.Address, MaxUpstreamWeight)
+	}
+	return nil
+}
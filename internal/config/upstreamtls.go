@@ -0,0 +1,196 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// PinPrefix 证书固定值的前缀，格式与 curl --pinnedpubkey 相同（sha256//base64）
+const PinPrefix = "sha256//"
+
+// UpstreamTLSConfig 上游 TLS 配置（仅在 use_https 为 true 时生效）
+// 用于访问使用私有 CA 签发证书的上游，无需通过 insecure 关闭证书验证；上游要求双向 TLS 时提供客户端证书
+type UpstreamTLSConfig struct {
+	CAFile     string   `json:"ca_file,omitempty"`     // PEM 格式的 CA 证书文件，设置后只信任其中的 CA（不再使用系统根证书）
+	ServerName string   `json:"server_name,omitempty"` // TLS SNI 和证书验证使用的服务器名称，默认为上游目标的主机名
+	Pins       []string `json:"pins,omitempty"`        // 证书公钥（SPKI）的 SHA-256 固定值，证书链中任意一张证书匹配即可，格式为 sha256//base64 或十六进制
+
+	// 客户端证书（mTLS），使用 PEM 格式的证书和私钥，或 PKCS#12 文件，二选一
+	CertFile       string `json:"cert_file,omitempty"`       // PEM 格式的客户端证书文件，可以包含中间证书
+	KeyFile        string `json:"key_file,omitempty"`        // PEM 格式的客户端私钥文件
+	PKCS12File     string `json:"pkcs12_file,omitempty"`     // PKCS#12（.p12/.pfx）格式的客户端证书和私钥
+	PKCS12Password string `json:"pkcs12_password,omitempty"` // PKCS#12 文件的密码，没有密码时留空
+
+	// 协议版本、加密套件和曲线（profile、min_version、max_version、ciphers、curves），未设置时使用 legacy 档位
+	TLSProfileConfig
+}
+
+// GetTLSProfile 获取上游连接的 TLS 档位设置，t 为 nil 时返回 nil（使用默认档位）
+func (t *UpstreamTLSConfig) GetTLSProfile() *TLSProfileConfig {
+	if t == nil {
+		return nil
+	}
+	return &t.TLSProfileConfig
+}
+
+// HasClientCert 判断是否配置了客户端证书
+func (t *UpstreamTLSConfig) HasClientCert() bool {
+	return t != nil && (t.CertFile != "" || t.PKCS12File != "")
+}
+
+// ClientCertPath 获取客户端证书的文件路径，用于日志，未配置时为空
+func (t *UpstreamTLSConfig) ClientCertPath() string {
+	if t == nil {
+		return ""
+	}
+	if t.PKCS12File != "" {
+		return t.PKCS12File
+	}
+	return t.CertFile
+}
+
+// ClientCertFiles 获取客户端证书相关的所有文件，文件变化时需要重新加载证书
+func (t *UpstreamTLSConfig) ClientCertFiles() []string {
+	if t == nil {
+		return nil
+	}
+	if t.PKCS12File != "" {
+		return []string{t.PKCS12File}
+	}
+	if t.CertFile != "" {
+		return []string{t.CertFile, t.KeyFile}
+	}
+	return nil
+}
+
+// LoadClientCert 读取客户端证书和私钥，未配置客户端证书时返回 nil
+func (t *UpstreamTLSConfig) LoadClientCert() (*tls.Certificate, error) {
+	if !t.HasClientCert() {
+		return nil, nil
+	}
+	if t.PKCS12File == "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		return &cert, nil
+	}
+
+	data, err := os.ReadFile(t.PKCS12File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PKCS#12 file: %v", err)
+	}
+	key, leaf, chain, err := pkcs12.DecodeChain(data, t.PKCS12Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PKCS#12 file %s: %v", t.PKCS12File, err)
+	}
+	cert := &tls.Certificate{PrivateKey: key, Leaf: leaf}
+	cert.Certificate = append(cert.Certificate, leaf.Raw)
+	for _, c := range chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert, nil
+}
+
+// LoadCA 读取并解析 CA 证书文件，未设置 ca_file 时返回 nil（使用系统根证书）
+func (t *UpstreamTLSConfig) LoadCA() (*x509.CertPool, error) {
+	if t == nil || t.CAFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(t.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in CA file %s", t.CAFile)
+	}
+	return pool, nil
+}
+
+// PinHashes 解析证书固定值
+func (t *UpstreamTLSConfig) PinHashes() ([][sha256.Size]byte, error) {
+	if t == nil {
+		return nil, nil
+	}
+	hashes := make([][sha256.Size]byte, 0, len(t.Pins))
+	for _, pin := range t.Pins {
+		hash, err := ParsePin(pin)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// ParsePin 解析证书固定值，支持 sha256//base64、sha256/base64、base64 和十六进制（可以用 : 分隔）格式
+func ParsePin(pin string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	value := strings.TrimSpace(pin)
+	value = strings.TrimPrefix(value, PinPrefix)
+	value = strings.TrimPrefix(value, "sha256/")
+
+	var decoded []byte
+	var err error
+	if hexValue := strings.ReplaceAll(value, ":", ""); len(hexValue) == hex.EncodedLen(sha256.Size) {
+		decoded, err = hex.DecodeString(hexValue)
+	} else {
+		decoded, err = base64.StdEncoding.DecodeString(value)
+	}
+	if err != nil || len(decoded) != sha256.Size {
+		return hash, fmt.Errorf("invalid pin %q (expected %sbase64 or hex of a SHA-256 public key hash)", pin, PinPrefix)
+	}
+	copy(hash[:], decoded)
+	return hash, nil
+}
+
+// SPKIPin 计算证书公钥的固定值（sha256//base64 格式）
+func SPKIPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return PinPrefix + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// validate 验证上游 TLS 配置，name 为路由名称
+func (t *UpstreamTLSConfig) validate(name string, useHTTPS bool) []error {
+	if t == nil {
+		return nil
+	}
+
+	var errs []error
+	if !useHTTPS && (t.CAFile != "" || t.ServerName != "" || len(t.Pins) > 0 || t.HasClientCert() || t.KeyFile != "" || t.TLSProfileConfig.isSet()) {
+		errs = append(errs, fmt.Errorf("proxy %s: tls settings require use_https", name))
+	}
+	if _, err := t.LoadCA(); err != nil {
+		errs = append(errs, fmt.Errorf("proxy %s: tls.ca_file: %v", name, err))
+	}
+	if strings.ContainsAny(t.ServerName, "/: ") {
+		errs = append(errs, fmt.Errorf("proxy %s: tls.server_name %q must be a host name without port", name, t.ServerName))
+	}
+	if _, err := t.PinHashes(); err != nil {
+		errs = append(errs, fmt.Errorf("proxy %s: tls.pins: %v", name, err))
+	}
+	errs = append(errs, t.TLSProfileConfig.validate("proxy "+name+": tls")...)
+
+	switch {
+	case t.PKCS12File != "" && (t.CertFile != "" || t.KeyFile != ""):
+		errs = append(errs, fmt.Errorf("proxy %s: tls.pkcs12_file cannot be combined with tls.cert_file or tls.key_file", name))
+	case (t.CertFile == "") != (t.KeyFile == ""):
+		errs = append(errs, fmt.Errorf("proxy %s: tls.cert_file and tls.key_file must be set together", name))
+	case t.PKCS12Password != "" && t.PKCS12File == "":
+		errs = append(errs, fmt.Errorf("proxy %s: tls.pkcs12_password requires tls.pkcs12_file", name))
+	default:
+		if _, err := t.LoadClientCert(); err != nil {
+			errs = append(errs, fmt.Errorf("proxy %s: tls: %v", name, err))
+		}
+	}
+	return errs
+}
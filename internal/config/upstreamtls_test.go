@@ -0,0 +1,51 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePin(t *testing.T) {
+	want := sha256.Sum256([]byte("public key"))
+	b64 := base64.StdEncoding.EncodeToString(want[:])
+	hexValue := hex.EncodeToString(want[:])
+
+	var colons []string
+	for i := 0; i < len(hexValue); i += 2 {
+		colons = append(colons, strings.ToUpper(hexValue[i:i+2]))
+	}
+
+	for _, pin := range []string{PinPrefix + b64, "sha256/" + b64, b64, " " + b64 + " ", hexValue, strings.Join(colons, ":")} {
+		got, err := ParsePin(pin)
+		if err != nil {
+			t.Errorf("ParsePin(%q): %v", pin, err)
+		} else if got != want {
+			t.Errorf("ParsePin(%q) = %x, want %x", pin, got, want)
+		}
+	}
+	for _, pin := range []string{"", "sha256//", PinPrefix + "c2hvcnQ=", hexValue[:62], "md5//" + b64} {
+		if _, err := ParsePin(pin); err == nil {
+			t.Errorf("ParsePin(%q) succeeded, want error", pin)
+		}
+	}
+}
+
+func TestLoadCA(t *testing.T) {
+	if pool, err := (*UpstreamTLSConfig)(nil).LoadCA(); pool != nil || err != nil {
+		t.Errorf("nil config: LoadCA() = %v, %v, want nil, nil", pool, err)
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := (&UpstreamTLSConfig{CAFile: notPEM}).LoadCA()
+	if err == nil || !strings.Contains(err.Error(), "no PEM certificates found") {
+		t.Errorf("LoadCA(non-PEM) error = %v, want no PEM certificates found", err)
+	}
+}
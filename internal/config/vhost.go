@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VirtualHost 虚拟主机配置
+// 每个虚拟主机按 Host 头（HTTPS 时还包括 SNI）匹配，拥有独立的静态文件目录、代理配置、证书和响应头
+type VirtualHost struct {
+	Name         string                  `json:"name"`                    // 名称，用于日志和错误信息
+	Hosts        []string                `json:"hosts"`                   // 匹配的主机，支持 host:port、host（任意端口）和 *.domain 通配
+	Default      bool                    `json:"default,omitempty"`       // 是否为默认主机，没有虚拟主机匹配时使用，未设置时使用顶层配置
+	StaticDir    string                  `json:"static_dir,omitempty"`    // 静态文件目录路径，为空时不提供静态文件服务
+	ProxyConfigs map[string]*ProxyConfig `json:"proxy_configs,omitempty"` // 代理配置映射，格式与顶层 proxy_configs 相同
+	CertFile     string                  `json:"cert_file,omitempty"`     // SSL 证书文件路径，按 SNI 选择
+	KeyFile      string                  `json:"key_file,omitempty"`      // SSL 私钥文件路径
+	Headers      map[string]string       `json:"headers,omitempty"`       // 附加到该主机所有响应上的响应头
+}
+
+// HasCertificate 判断虚拟主机是否配置了证书
+func (v *VirtualHost) HasCertificate() bool {
+	return v.CertFile != "" && v.KeyFile != ""
+}
+
+// validate 验证虚拟主机配置的有效性，并将静态文件目录转换为绝对路径
+func (v *VirtualHost) validate() []error {
+	var errs []error
+	label := fmt.Sprintf("virtual host %s", v.Name)
+
+	if v.Name == "" {
+		errs = append(errs, fmt.Errorf("virtual host name is empty"))
+	}
+	if len(v.Hosts) == 0 && !v.Default {
+		errs = append(errs, fmt.Errorf("%s: hosts is empty", label))
+	}
+	for _, host := range v.Hosts {
+		if strings.TrimSpace(host) == "" || strings.ContainsAny(host, "/ ") {
+			errs = append(errs, fmt.Errorf("%s: invalid host %q", label, host))
+		}
+	}
+
+	if v.CertFile != "" || v.KeyFile != "" {
+		if err := validateCertPair(v.CertFile, v.KeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", label, err))
+		}
+	}
+
+	if v.StaticDir != "" {
+		absPath, err := validateStaticDir(v.StaticDir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", label, err))
+		} else {
+			v.StaticDir = absPath
+		}
+	}
+
+	for _, name := range sortedProxyNames(v.ProxyConfigs) {
+		proxyConfig := v.ProxyConfigs[name]
+		if proxyConfig == nil {
+			errs = append(errs, fmt.Errorf("%s: proxy %s: empty proxy config", label, name))
+			continue
+		}
+		for _, err := range proxyConfig.validate(name) {
+			errs = append(errs, fmt.Errorf("%s: %v", label, err))
+		}
+	}
+
+	for name, value := range v.Headers {
+		if !isToken(name) {
+			errs = append(errs, fmt.Errorf("%s: invalid header name %q", label, name))
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			errs = append(errs, fmt.Errorf("%s: header %s value must not contain line breaks", label, name))
+		}
+	}
+
+	return errs
+}
+
+// validateVirtualHosts 验证所有虚拟主机：名称唯一、最多一个默认主机
+func (c *Config) validateVirtualHosts() []error {
+	var errs []error
+	names := make(map[string]bool)
+	defaults := 0
+
+	for i, vhost := range c.VirtualHosts {
+		if vhost == nil {
+			errs = append(errs, fmt.Errorf("virtual_hosts[%d]: empty virtual host", i))
+			continue
+		}
+		if names[vhost.Name] {
+			errs = append(errs, fmt.Errorf("virtual host %s: duplicate name", vhost.Name))
+		}
+		names[vhost.Name] = true
+		if vhost.Default {
+			defaults++
+		}
+		errs = append(errs, vhost.validate()...)
+	}
+
+	if defaults > 1 {
+		errs = append(errs, fmt.Errorf("at most one virtual host can be marked as default, got %d", defaults))
+	}
+	return errs
+}
+
+// sortedProxyNames 获取排序后的路由名称，保证校验错误顺序稳定
+func sortedProxyNames(proxyConfigs map[string]*ProxyConfig) []string {
+	names := make([]string, 0, len(proxyConfigs))
+	for name := range proxyConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
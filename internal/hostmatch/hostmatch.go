@@ -0,0 +1,59 @@
+package hostmatch
+
+import (
+	"net"
+	"strings"
+)
+
+// 主机匹配规则的精确程度，数值越大越精确
+const (
+	Any      = iota // 未限制主机
+	Wildcard        // 通配主机（*.domain）
+	Name            // 主机名（任意端口）
+	Exact           // 主机名和端口
+)
+
+// Specificity 获取主机匹配规则的精确程度
+// 规则格式：host:port（主机和端口都匹配）、host（任意端口）、*.domain（通配子域名）
+func Specificity(pattern string) int {
+	if strings.HasPrefix(pattern, "*.") {
+		return Wildcard
+	}
+	if _, _, err := net.SplitHostPort(pattern); err == nil {
+		return Exact
+	}
+	return Name
+}
+
+// Match 判断请求的 Host 头（可能包含端口）是否匹配规则，不区分大小写
+func Match(pattern, requestHost string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	requestHost = strings.ToLower(requestHost)
+
+	if Specificity(pattern) == Exact {
+		return pattern == requestHost
+	}
+	return MatchName(pattern, Hostname(requestHost))
+}
+
+// MatchName 判断主机名（不含端口，如 TLS SNI）是否匹配规则，规则中的端口会被忽略
+func MatchName(pattern, hostname string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	hostname = strings.ToLower(hostname)
+
+	switch Specificity(pattern) {
+	case Wildcard:
+		return strings.HasSuffix(hostname, pattern[1:])
+	case Exact:
+		pattern = Hostname(pattern)
+	}
+	return strings.Trim(pattern, "[]") == hostname
+}
+
+// Hostname 去掉 Host 头中的端口部分
+func Hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.Trim(host, "[]")
+}
@@ -0,0 +1,284 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"serve/internal/config"
+)
+
+// hashReplicas 一致性哈希中每单位权重对应的虚拟节点数
+const hashReplicas = 160
+
+// backend 上游目标的运行时状态
+// 热加载时同一路由下地址相同的目标沿用原有状态，统计数据不会清零
+type backend struct {
+	addr   string // 目标地址 host[:port]
+	weight int    // 权重
+
+	active   atomic.Int64  // 进行中的请求数
+	requests atomic.Uint64 // 已转发的请求总数
+	failures atomic.Uint64 // 转发失败（连接错误、超时等）的请求数
+
+	healthy   atomic.Bool // 主动健康检查结果，未启用健康检查时始终为 true
+	mu        sync.Mutex  // 保护最近一次健康检查的结果
+	lastCheck time.Time   // 最近一次健康检查的时间
+	lastError string      // 最近一次健康检查的错误，成功时为空
+
+	breaker atomic.Pointer[circuitBreaker] // 熔断器，未启用时为 nil
+}
+
+// newBackend 创建上游目标，初始状态为健康
+func newBackend(addr string, weight int) *backend {
+	b := &backend{addr: addr, weight: max(weight, 1)}
+	b.healthy.Store(true)
+	return b
+}
+
+// available 判断目标是否可以参与负载均衡：健康检查通过且未熔断
+func (b *backend) available() bool {
+	if !b.healthy.Load() {
+		return false
+	}
+	cb := b.breaker.Load()
+	return cb == nil || cb.ready()
+}
+
+// recordCheck 记录一次健康检查的结果
+func (b *backend) recordCheck(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastCheck = time.Now()
+	b.lastError = ""
+	if err != nil {
+		b.lastError = err.Error()
+	}
+}
+
+// lastCheckResult 获取最近一次健康检查的时间和错误
+func (b *backend) lastCheckResult() (time.Time, string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastCheck, b.lastError
+}
+
+// load 获取按权重归一化的负载比较值，a.load(b) < 0 表示 a 的负载较低
+func (b *backend) load(other *backend) int64 {
+	return b.active.Load()*int64(other.weight) - other.active.Load()*int64(b.weight)
+}
+
+// balancer 负载均衡器，从可用的上游目标中为请求选择一个目标，没有可用目标时返回 nil
+type balancer interface {
+	pick(r *http.Request) *backend
+}
+
+// newBalancer 根据负载均衡配置创建负载均衡器，backends 至少包含一个目标
+func newBalancer(lb *config.LoadBalancerConfig, backends []*backend) balancer {
+	if len(backends) == 1 {
+		return single{backends[0]}
+	}
+
+	switch lb.GetPolicy() {
+	case config.PolicyLeastConn:
+		return &leastConn{backends: backends}
+	case config.PolicyRandomTwo:
+		return newRandomTwo(backends)
+	case config.PolicyHash:
+		return newConsistentHash(lb, backends)
+	default:
+		return newRoundRobin(backends)
+	}
+}
+
+// single 只有一个目标时直接使用该目标
+type single struct {
+	backend *backend
+}
+
+func (s single) pick(*http.Request) *backend {
+	if !s.backend.available() {
+		return nil
+	}
+	return s.backend
+}
+
+// roundRobin 平滑加权轮询，权重高的目标被均匀地穿插选中，而不是连续选中
+type roundRobin struct {
+	mu       sync.Mutex
+	backends []*backend
+	current  []int
+}
+
+func newRoundRobin(backends []*backend) *roundRobin {
+	return &roundRobin{backends: backends, current: make([]int, len(backends))}
+}
+
+func (rr *roundRobin) pick(*http.Request) *backend {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	total, best := 0, -1
+	for i, b := range rr.backends {
+		if !b.available() {
+			continue
+		}
+		rr.current[i] += b.weight
+		total += b.weight
+		if best < 0 || rr.current[i] > rr.current[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	rr.current[best] -= total
+	return rr.backends[best]
+}
+
+// leastConn 加权最少连接，选择 进行中的请求数/权重 最小的目标，相同时轮流选择
+type leastConn struct {
+	backends []*backend
+	next     atomic.Uint64
+}
+
+func (lc *leastConn) pick(*http.Request) *backend {
+	n := len(lc.backends)
+	start := int(lc.next.Add(1) % uint64(n))
+	var best *backend
+	for i := 0; i < n; i++ {
+		b := lc.backends[(start+i)%n]
+		if b.available() && (best == nil || b.load(best) < 0) {
+			best = b
+		}
+	}
+	return best
+}
+
+// randomTwo 按权重随机选取两个不同的目标，使用负载较低的一个（power of two choices）
+type randomTwo struct {
+	backends []*backend
+}
+
+func newRandomTwo(backends []*backend) *randomTwo {
+	return &randomTwo{backends: backends}
+}
+
+func (r *randomTwo) pick(*http.Request) *backend {
+	first := r.weighted(nil)
+	if first == nil {
+		return nil
+	}
+	second := r.weighted(first)
+	if second != nil && second.load(first) < 0 {
+		return second
+	}
+	return first
+}
+
+// weighted 按权重从可用目标中随机选择一个，exclude 不为 nil 时排除该目标，没有可选目标时返回 nil
+func (r *randomTwo) weighted(exclude *backend) *backend {
+	total := 0
+	for _, b := range r.backends {
+		if b != exclude && b.available() {
+			total += b.weight
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	n := rand.IntN(total)
+	for _, b := range r.backends {
+		if b == exclude || !b.available() {
+			continue
+		}
+		if n < b.weight {
+			return b
+		}
+		n -= b.weight
+	}
+	return nil
+}
+
+// consistentHash 一致性哈希，按请求头或 Cookie 选择目标，相同的值总是落到同一个目标
+// 目标增减或不可用时只有少量的值会改变目标（顺着哈希环找到下一个可用目标）；请求未携带时按客户端 IP 哈希
+type consistentHash struct {
+	header string
+	cookie string
+	ring   []ringPoint
+}
+
+// ringPoint 哈希环上的虚拟节点
+type ringPoint struct {
+	hash    uint32
+	backend *backend
+}
+
+func newConsistentHash(lb *config.LoadBalancerConfig, backends []*backend) *consistentHash {
+	h := &consistentHash{header: lb.HashHeader, cookie: lb.HashCookie}
+	for _, b := range backends {
+		for i := 0; i < b.weight*hashReplicas; i++ {
+			h.ring = append(h.ring, ringPoint{hash: hashKey(b.addr + "#" + strconv.Itoa(i)), backend: b})
+		}
+	}
+	sort.Slice(h.ring, func(i, j int) bool {
+		return h.ring[i].hash < h.ring[j].hash
+	})
+	return h
+}
+
+func (h *consistentHash) pick(r *http.Request) *backend {
+	hash := hashKey(h.key(r))
+	i := sort.Search(len(h.ring), func(i int) bool {
+		return h.ring[i].hash >= hash
+	})
+	for n := 0; n < len(h.ring); n++ {
+		if point := h.ring[(i+n)%len(h.ring)]; point.backend.available() {
+			return point.backend
+		}
+	}
+	return nil
+}
+
+// key 获取请求的哈希值来源
+func (h *consistentHash) key(r *http.Request) string {
+	if h.header != "" {
+		if value := r.Header.Get(h.header); value != "" {
+			return value
+		}
+	}
+	if h.cookie != "" {
+		if cookie, err := r.Cookie(h.cookie); err == nil && cookie.Value != "" {
+			return cookie.Value
+		}
+	}
+	return clientIP(r)
+}
+
+// hashKey 计算字符串的哈希值
+// FNV-1a 对相近的字符串（如 addr#1、addr#2）分布不够均匀，再经过一次 64 位混合
+func hashKey(s string) uint32 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	v := h.Sum64()
+	v ^= v >> 33
+	v *= 0xff51afd7ed558ccd
+	v ^= v >> 33
+	return uint32(v)
+}
+
+// clientIP 获取请求的客户端 IP
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,255 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"serve/internal/config"
+)
+
+// testBackends 创建上游目标，weights 为每个目标的权重，地址依次为 b0、b1……
+func testBackends(weights ...int) []*backend {
+	backends := make([]*backend, len(weights))
+	for i, w := range weights {
+		backends[i] = newBackend(fmt.Sprintf("b%d", i), w)
+	}
+	return backends
+}
+
+// openBreaker 为目标设置已熔断的熔断器
+func openBreaker(b *backend) {
+	cb := newCircuitBreaker("test", b.addr, config.CircuitBreakerConfig{ConsecutiveFailures: 1, Cooldown: config.Duration(time.Hour), HalfOpenRequests: 1}, testLogger())
+	cb.record(true, false)
+	b.breaker.Store(cb)
+}
+
+// countPicks 选择 n 次，统计每个目标被选中的次数
+func countPicks(lb balancer, n int) map[string]int {
+	counts := make(map[string]int)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for i := 0; i < n; i++ {
+		if b := lb.pick(req); b != nil {
+			counts[b.addr]++
+		} else {
+			counts[""]++
+		}
+	}
+	return counts
+}
+
+func TestNewBalancer(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{"", "*proxy.roundRobin"},
+		{config.PolicyRoundRobin, "*proxy.roundRobin"},
+		{config.PolicyLeastConn, "*proxy.leastConn"},
+		{config.PolicyRandomTwo, "*proxy.randomTwo"},
+		{config.PolicyHash, "*proxy.consistentHash"},
+	}
+	for _, tt := range tests {
+		lb := newBalancer(&config.LoadBalancerConfig{Policy: tt.policy}, testBackends(1, 1))
+		if got := fmt.Sprintf("%T", lb); got != tt.want {
+			t.Errorf("policy %q: balancer = %s, want %s", tt.policy, got, tt.want)
+		}
+	}
+	if got := fmt.Sprintf("%T", newBalancer(&config.LoadBalancerConfig{Policy: config.PolicyHash}, testBackends(1))); got != "proxy.single" {
+		t.Errorf("single backend: balancer = %s, want proxy.single", got)
+	}
+}
+
+func TestSingle(t *testing.T) {
+	backends := testBackends(1)
+	lb := newBalancer(nil, backends)
+	if got := countPicks(lb, 3); got["b0"] != 3 {
+		t.Errorf("picks = %v, want b0 every time", got)
+	}
+	backends[0].healthy.Store(false)
+	if got := countPicks(lb, 1); got[""] != 1 {
+		t.Errorf("picks = %v, want nil for an unhealthy backend", got)
+	}
+}
+
+func TestRoundRobinDistribution(t *testing.T) {
+	lb := newRoundRobin(testBackends(1, 2, 3))
+	got := countPicks(lb, 600)
+	want := map[string]int{"b0": 100, "b1": 200, "b2": 300}
+	for addr, n := range want {
+		if got[addr] != n {
+			t.Errorf("picks = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestRoundRobinSmooth(t *testing.T) {
+	// 平滑加权轮询：权重高的目标穿插选中，而不是连续选中 5 次
+	lb := newRoundRobin(testBackends(5, 1, 1))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	var seq []string
+	for i := 0; i < 7; i++ {
+		seq = append(seq, lb.pick(req).addr)
+	}
+	if got, want := strings.Join(seq, " "), "b0 b0 b1 b0 b2 b0 b0"; got != want {
+		t.Errorf("sequence = %s, want %s", got, want)
+	}
+}
+
+func TestBalancersSkipUnavailable(t *testing.T) {
+	policies := []string{config.PolicyRoundRobin, config.PolicyLeastConn, config.PolicyRandomTwo, config.PolicyHash}
+	for _, policy := range policies {
+		t.Run(policy, func(t *testing.T) {
+			backends := testBackends(1, 1, 1)
+			lb := newBalancer(&config.LoadBalancerConfig{Policy: policy}, backends)
+
+			backends[0].healthy.Store(false)
+			openBreaker(backends[1])
+			if got := countPicks(lb, 100); got["b2"] != 100 {
+				t.Errorf("picks = %v, want only b2", got)
+			}
+
+			backends[2].healthy.Store(false)
+			if got := countPicks(lb, 10); got[""] != 10 {
+				t.Errorf("picks = %v, want nil when no backend is available", got)
+			}
+
+			backends[0].healthy.Store(true)
+			if got := countPicks(lb, 10); got["b0"] != 10 {
+				t.Errorf("picks = %v, want b0 after it becomes healthy", got)
+			}
+		})
+	}
+}
+
+func TestLeastConn(t *testing.T) {
+	backends := testBackends(1, 1, 2)
+	lb := &leastConn{backends: backends}
+
+	backends[0].active.Store(3)
+	backends[1].active.Store(1)
+	backends[2].active.Store(4) // 按权重归一化后为 2
+	if got := countPicks(lb, 10); got["b1"] != 10 {
+		t.Errorf("picks = %v, want b1 (lowest active/weight)", got)
+	}
+
+	// 负载相同时轮流选择
+	backends[0].active.Store(0)
+	backends[1].active.Store(0)
+	backends[2].active.Store(0)
+	if got := countPicks(lb, 300); got["b0"] != 100 || got["b1"] != 100 || got["b2"] != 100 {
+		t.Errorf("picks = %v, want an even rotation on ties", got)
+	}
+}
+
+func TestRandomTwo(t *testing.T) {
+	backends := testBackends(1, 3)
+	lb := newRandomTwo(backends)
+
+	// 负载相同时按权重随机选择
+	got := countPicks(lb, 10000)
+	if share := float64(got["b1"]) / 10000; share < 0.7 || share > 0.8 {
+		t.Errorf("picks = %v, want about 75%% for b1", got)
+	}
+
+	// 两个候选中选择负载较低的一个
+	backends[1].active.Store(10)
+	if got := countPicks(lb, 100); got["b0"] != 100 {
+		t.Errorf("picks = %v, want b0 (lower load)", got)
+	}
+}
+
+// hashRequest 创建携带哈希请求头的请求
+func hashRequest(key string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User", key)
+	return req
+}
+
+func TestConsistentHashStable(t *testing.T) {
+	lb := newConsistentHash(&config.LoadBalancerConfig{HashHeader: "X-User"}, testBackends(1, 1, 1))
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		first := lb.pick(hashRequest(key))
+		if again := lb.pick(hashRequest(key)); again != first {
+			t.Fatalf("key %s picked %s then %s", key, first.addr, again.addr)
+		}
+		counts[first.addr]++
+	}
+	for addr, n := range counts {
+		if n < 700 || n > 1300 {
+			t.Errorf("picks = %v, want an even spread (%s got %d)", counts, addr, n)
+		}
+	}
+}
+
+func TestConsistentHashKey(t *testing.T) {
+	lb := newConsistentHash(&config.LoadBalancerConfig{HashHeader: "X-User", HashCookie: "session"}, testBackends(1, 1))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if got := lb.key(req); got != "10.0.0.1" {
+		t.Errorf("key without header or cookie = %q, want client IP", got)
+	}
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	if got := lb.key(req); got != "abc" {
+		t.Errorf("key with cookie = %q, want abc", got)
+	}
+	req.Header.Set("X-User", "alice")
+	if got := lb.key(req); got != "alice" {
+		t.Errorf("key with header and cookie = %q, want header value", got)
+	}
+}
+
+func TestConsistentHashRemoveBackend(t *testing.T) {
+	settings := &config.LoadBalancerConfig{HashHeader: "X-User"}
+	before := newConsistentHash(settings, testBackends(1, 1, 1, 1))
+	// 移除 b3，其余目标地址不变
+	after := newConsistentHash(settings, testBackends(1, 1, 1))
+
+	moved := 0
+	for i := 0; i < 2000; i++ {
+		req := hashRequest(fmt.Sprintf("user-%d", i))
+		was, now := before.pick(req).addr, after.pick(req).addr
+		if was == "b3" {
+			moved++
+			continue
+		}
+		if was != now {
+			t.Fatalf("key user-%d moved from %s to %s although %s was not removed", i, was, now, was)
+		}
+	}
+	if moved == 0 {
+		t.Error("no key was mapped to the removed backend")
+	}
+}
+
+func TestConsistentHashUnavailableBackend(t *testing.T) {
+	backends := testBackends(1, 1, 1)
+	lb := newConsistentHash(&config.LoadBalancerConfig{HashHeader: "X-User"}, backends)
+
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		before[key] = lb.pick(hashRequest(key)).addr
+	}
+
+	// 只有原本映射到不可用目标的 key 改变去向
+	backends[1].healthy.Store(false)
+	openBreaker(backends[2])
+	for key, was := range before {
+		now := lb.pick(hashRequest(key)).addr
+		if now != "b0" {
+			t.Fatalf("key %s picked the unavailable backend %s", key, now)
+		}
+		if was == "b0" && now != was {
+			t.Fatalf("key %s moved from %s to %s", key, was, now)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 可以改写的响应体类型
+const (
+	bodyHTML   = "html"
+	bodyCSS    = "css"
+	bodyScript = "script"
+)
+
+// bodyKinds 响应的媒体类型到响应体类型
+var bodyKinds = map[string]string{
+	"text/html":                 bodyHTML,
+	"application/xhtml+xml":     bodyHTML,
+	"text/css":                  bodyCSS,
+	"text/javascript":           bodyScript,
+	"application/javascript":    bodyScript,
+	"application/x-javascript":  bodyScript,
+	"application/ecmascript":    bodyScript,
+	"application/json":          bodyScript,
+	"application/manifest+json": bodyScript,
+}
+
+// urlAttributes 值为单个链接的 HTML 属性
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "poster": true, "data": true,
+	"cite": true, "background": true, "longdesc": true, "manifest": true, "icon": true, "xlink:href": true,
+}
+
+var (
+	cssURLPattern    = regexp.MustCompile(`(?i)url\(\s*("[^"]*"|'[^']*'|[^)'"\s]*)\s*\)`)
+	cssImportPattern = regexp.MustCompile(`(?i)@import\s+("[^"]*"|'[^']*')`)
+	refreshURLPrefix = regexp.MustCompile(`(?i)^\s*\d*\s*[;,]\s*url\s*=\s*`)
+)
+
+// rewriteResponseBody 改写上游响应体中的链接，在 ModifyResponse 中调用
+// 只处理 HTML、CSS、JavaScript 和 JSON 响应，gzip 和 deflate 压缩的响应体解压后改写，改写后不再压缩并更新 Content-Length；
+// 超过大小限制或使用其他压缩方式的响应原样转发
+func rewriteResponseBody(resp *http.Response, route *Route, index *linkIndex, logger *logrus.Logger) error {
+	req := resp.Request
+	if req.Method == http.MethodHead || resp.StatusCode < http.StatusOK ||
+		resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	kind := bodyKinds[mediaType]
+	if kind == "" {
+		return nil
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding != "" && encoding != "identity" && encoding != "gzip" && encoding != "deflate" {
+		logger.Debugf("Skipping body rewrite for %s: unsupported Content-Encoding %q", req.URL.Path, encoding)
+		return nil
+	}
+
+	limit := route.Config.RewriteBody.GetMaxBodySize()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return fmt.Errorf("read response body for rewriting: %w", err)
+	}
+	if int64(len(raw)) > limit {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(raw), resp.Body), resp.Body}
+		logger.Debugf("Skipping body rewrite for %s: body exceeds rewrite_body.max_body_size %d", req.URL.Path, limit)
+		return nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+
+	body := raw
+	if encoding == "gzip" || encoding == "deflate" {
+		body, err = decompressBody(raw, encoding, limit)
+		if err != nil {
+			logger.Debugf("Skipping body rewrite for %s: %v", req.URL.Path, err)
+			return nil
+		}
+	}
+
+	lr := &linkRewriter{
+		index:        index,
+		route:        route,
+		host:         req.Context().Value(targetKey{}).(*target).vars.r.Host,
+		rootRelative: route.Config.RewriteBody.RewritesRootRelative(),
+	}
+	var rewritten []byte
+	switch kind {
+	case bodyHTML:
+		rewritten = lr.html(body)
+	case bodyCSS:
+		rewritten = lr.css(body)
+	default:
+		rewritten = lr.absolute(body)
+	}
+	if lr.rewritten == 0 {
+		return nil
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(rewritten))
+	resp.ContentLength = int64(len(rewritten))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(rewritten)))
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-MD5")
+	logger.Debugf("Rewrote %d link(s) in %s response body of %s (%d -> %d bytes)", lr.rewritten, kind, req.URL.Path, len(body), len(rewritten))
+	return nil
+}
+
+// decompressBody 解压 gzip 或 deflate 响应体，解压后超过 limit 时返回错误
+func decompressBody(raw []byte, encoding string, limit int64) ([]byte, error) {
+	var r io.Reader
+	var err error
+	if encoding == "gzip" {
+		r, err = gzip.NewReader(bytes.NewReader(raw))
+	} else {
+		// deflate 应为 zlib 格式，部分服务器直接发送原始 deflate 数据
+		if r, err = zlib.NewReader(bytes.NewReader(raw)); err != nil {
+			r, err = flate.NewReader(bytes.NewReader(raw)), nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s body: %v", encoding, err)
+	}
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s body: %v", encoding, err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("decompressed body exceeds rewrite_body.max_body_size %d", limit)
+	}
+	return body, nil
+}
+
+// htmlAttr HTML 标签的属性
+type htmlAttr struct {
+	name       string // 小写的属性名
+	start, end int    // 整个属性在文档中的位置
+	valStart   int    // 属性值（不含引号）的开始位置，没有值时为 -1
+	valEnd     int    // 属性值的结束位置
+}
+
+// htmlTag HTML 开始标签
+type htmlTag struct {
+	name        string // 小写的标签名
+	attrs       []htmlAttr
+	end         int  // 标签结束（">" 之后）的位置
+	selfClosing bool // 是否以 "/>" 结束
+}
+
+// htmlEdit 对文档的一处修改
+type htmlEdit struct {
+	start, end int
+	value      string
+}
+
+// html 改写 HTML 文档中的链接：链接属性、srcset、style 属性、meta refresh，以及 <style> 和 <script> 的内容
+// 按标签扫描文档，注释和结束标签原样保留；改写了链接的 <script> 和 <link> 会移除 integrity 属性，因为引用的资源可能被改写
+func (lr *linkRewriter) html(src []byte) []byte {
+	var out bytes.Buffer
+	last := 0
+	for i := 0; i < len(src); {
+		lt := bytes.IndexByte(src[i:], '<')
+		if lt < 0 {
+			break
+		}
+		i += lt
+		rest := src[i:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			end := bytes.Index(rest[4:], []byte("-->"))
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += 4 + end + 3
+			}
+			continue
+		case len(rest) > 1 && (rest[1] == '!' || rest[1] == '?' || rest[1] == '/'):
+			end := bytes.IndexByte(rest, '>')
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end + 1
+			}
+			continue
+		case len(rest) < 2 || !isASCIILetter(rest[1]):
+			i++
+			continue
+		}
+
+		tag, ok := parseHTMLTag(src, i)
+		if !ok {
+			break
+		}
+		for _, edit := range lr.tagEdits(src, tag) {
+			out.Write(src[last:edit.start])
+			out.WriteString(edit.value)
+			last = edit.end
+		}
+		i = tag.end
+
+		if (tag.name == "script" || tag.name == "style") && !tag.selfClosing {
+			end := indexFold(src[i:], "</"+tag.name)
+			if end < 0 {
+				end = len(src) - i
+			}
+			content := src[i : i+end]
+			var rewritten []byte
+			if tag.name == "style" {
+				rewritten = lr.css(content)
+			} else {
+				rewritten = lr.absolute(content)
+			}
+			if !bytes.Equal(rewritten, content) {
+				out.Write(src[last:i])
+				out.Write(rewritten)
+				last = i + end
+			}
+			i += end
+		}
+	}
+	if last == 0 {
+		return src
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
+
+// tagEdits 计算标签中需要改写的属性，按位置排序
+func (lr *linkRewriter) tagEdits(src []byte, tag *htmlTag) []htmlEdit {
+	var edits []htmlEdit
+	rewroteRef := false
+	refresh := false
+	if tag.name == "meta" {
+		for _, attr := range tag.attrs {
+			if attr.name == "http-equiv" && attr.valStart >= 0 && strings.EqualFold(strings.TrimSpace(string(src[attr.valStart:attr.valEnd])), "refresh") {
+				refresh = true
+			}
+		}
+	}
+
+	integrity := -1
+	for i, attr := range tag.attrs {
+		if attr.name == "integrity" {
+			integrity = i
+			continue
+		}
+		if attr.valStart < 0 {
+			continue
+		}
+		value := string(src[attr.valStart:attr.valEnd])
+		var rewritten string
+		var ok bool
+		switch {
+		case urlAttributes[attr.name]:
+			rewritten, ok = lr.rewriteURL(value)
+			rewroteRef = rewroteRef || ok
+		case attr.name == "srcset" || attr.name == "imagesrcset":
+			rewritten, ok = lr.srcset(value)
+		case attr.name == "style":
+			rewritten = string(lr.css([]byte(value)))
+			ok = rewritten != value
+		case attr.name == "content" && refresh:
+			rewritten, ok = lr.refresh(value)
+		}
+		if ok {
+			edits = append(edits, htmlEdit{start: attr.valStart, end: attr.valEnd, value: rewritten})
+		}
+	}
+
+	if rewroteRef && integrity >= 0 && (tag.name == "script" || tag.name == "link") {
+		attr := tag.attrs[integrity]
+		edits = append(edits, htmlEdit{start: attr.start, end: attr.end})
+		sortEdits(edits)
+	}
+	return edits
+}
+
+// sortEdits 按位置排序修改
+func sortEdits(edits []htmlEdit) {
+	for i := 1; i < len(edits); i++ {
+		for j := i; j > 0 && edits[j].start < edits[j-1].start; j-- {
+			edits[j], edits[j-1] = edits[j-1], edits[j]
+		}
+	}
+}
+
+// srcset 改写 srcset 属性中每个候选图片的链接，如 "/a.png 1x, https://cdn.example.com/b.png 2x"
+func (lr *linkRewriter) srcset(value string) (string, bool) {
+	if strings.Contains(value, "data:") {
+		return value, false
+	}
+	candidates := strings.Split(value, ",")
+	changed := false
+	for i, candidate := range candidates {
+		trimmed := strings.TrimLeft(candidate, " \t\r\n")
+		url, descriptor, _ := strings.Cut(trimmed, " ")
+		if rewritten, ok := lr.rewriteURL(url); ok {
+			candidates[i] = candidate[:len(candidate)-len(trimmed)] + rewritten
+			if descriptor != "" {
+				candidates[i] += " " + descriptor
+			}
+			changed = true
+		}
+	}
+	return strings.Join(candidates, ","), changed
+}
+
+// refresh 改写 meta refresh 的 content 属性中的链接，如 "0; url=/login"
+func (lr *linkRewriter) refresh(value string) (string, bool) {
+	loc := refreshURLPrefix.FindStringIndex(value)
+	if loc == nil {
+		return value, false
+	}
+	url := value[loc[1]:]
+	quote := ""
+	if len(url) > 0 && (url[0] == '"' || url[0] == '\'') {
+		quote = url[:1]
+		url = strings.TrimSuffix(url[1:], quote)
+	}
+	rewritten, ok := lr.rewriteURL(url)
+	if !ok {
+		return value, false
+	}
+	if quote != "" {
+		rewritten = quote + rewritten + quote
+	}
+	return value[:loc[1]] + rewritten, true
+}
+
+// css 改写 CSS 中 url() 和 @import 引用的链接
+func (lr *linkRewriter) css(src []byte) []byte {
+	src = lr.replaceCSS(src, cssURLPattern)
+	return lr.replaceCSS(src, cssImportPattern)
+}
+
+// replaceCSS 改写正则第一个分组匹配的链接，分组可以带引号
+func (lr *linkRewriter) replaceCSS(src []byte, pattern *regexp.Regexp) []byte {
+	matches := pattern.FindAllSubmatchIndex(src, -1)
+	if len(matches) == 0 {
+		return src
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, m := range matches {
+		start, end := m[2], m[3]
+		value := string(src[start:end])
+		quote := ""
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			quote = value[:1]
+			value = value[1 : len(value)-1]
+		}
+		rewritten, ok := lr.rewriteURL(value)
+		if !ok {
+			continue
+		}
+		out.Write(src[last:start])
+		out.WriteString(quote + rewritten + quote)
+		last = end
+	}
+	if last == 0 {
+		return src
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
+
+// parseHTMLTag 解析从 i 开始（"<" 的位置）的开始标签，标签不完整时返回 false
+func parseHTMLTag(src []byte, i int) (*htmlTag, bool) {
+	j := i + 1
+	for j < len(src) && (isASCIILetter(src[j]) || src[j] >= '0' && src[j] <= '9' || src[j] == '-' || src[j] == ':') {
+		j++
+	}
+	tag := &htmlTag{name: strings.ToLower(string(src[i+1 : j]))}
+
+	for {
+		for j < len(src) && isHTMLSpace(src[j]) {
+			j++
+		}
+		if j >= len(src) {
+			return nil, false
+		}
+		switch src[j] {
+		case '>':
+			tag.end = j + 1
+			return tag, true
+		case '/':
+			if j+1 < len(src) && src[j+1] == '>' {
+				tag.selfClosing = true
+				tag.end = j + 2
+				return tag, true
+			}
+			j++
+			continue
+		}
+
+		start := j
+		for j < len(src) && !isHTMLSpace(src[j]) && src[j] != '=' && src[j] != '>' && src[j] != '/' {
+			j++
+		}
+		if j == start {
+			// 孤立的 "="
+			j++
+			continue
+		}
+		attr := htmlAttr{name: strings.ToLower(string(src[start:j])), start: start, valStart: -1}
+
+		k := j
+		for k < len(src) && isHTMLSpace(src[k]) {
+			k++
+		}
+		if k < len(src) && src[k] == '=' {
+			k++
+			for k < len(src) && isHTMLSpace(src[k]) {
+				k++
+			}
+			if k >= len(src) {
+				return nil, false
+			}
+			if q := src[k]; q == '"' || q == '\'' {
+				end := bytes.IndexByte(src[k+1:], q)
+				if end < 0 {
+					return nil, false
+				}
+				attr.valStart, attr.valEnd = k+1, k+1+end
+				j = attr.valEnd + 1
+			} else {
+				attr.valStart = k
+				for k < len(src) && !isHTMLSpace(src[k]) && src[k] != '>' {
+					k++
+				}
+				attr.valEnd = k
+				j = k
+			}
+		}
+		attr.end = j
+		tag.attrs = append(tag.attrs, attr)
+	}
+}
+
+// indexFold 不区分大小写查找以 "<" 开头的 ASCII 子串（如 "</script"）
+func indexFold(s []byte, substr string) int {
+	for i := 0; ; i++ {
+		j := bytes.IndexByte(s[i:], substr[0])
+		if j < 0 {
+			return -1
+		}
+		i += j
+		if i+len(substr) > len(s) {
+			return -1
+		}
+		if bytes.EqualFold(s[i:i+len(substr)], []byte(substr)) {
+			return i
+		}
+	}
+}
+
+// isASCIILetter 判断是否为 ASCII 字母
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isHTMLSpace 判断是否为 HTML 空白字符
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,308 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"serve/internal/config"
+)
+
+// rewriteTestTable 响应体改写测试使用的路由表：两个以目标域名作为路径前缀的路由
+func rewriteTestTable() *RouteTable {
+	return NewRouteTable(map[string]*config.ProxyConfig{
+		"www.example.com": {UseHTTPS: true, RewriteBody: &config.BodyRewriteConfig{}},
+		"cdn.example.com": {UseHTTPS: true, RewriteBody: &config.BodyRewriteConfig{}},
+	})
+}
+
+// newTestLinkRewriter 创建 www.example.com 路由的链接改写器
+func newTestLinkRewriter(rootRelative bool) *linkRewriter {
+	table := rewriteTestTable()
+	var route *Route
+	for _, r := range table.Routes() {
+		if r.Name == "www.example.com" {
+			route = r
+		}
+	}
+	return &linkRewriter{index: table.links, route: route, host: "192.168.1.2:8080", rootRelative: rootRelative}
+}
+
+func TestLinkRewriterHTML(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           string
+		want         string
+		rootRelative bool
+	}{
+		{
+			name: "double quoted absolute link",
+			in:   `<a href="https://www.example.com/a/b?x=1#top">`,
+			want: `<a href="/www.example.com/a/b?x=1#top">`,
+		},
+		{
+			name: "single quoted protocol-relative link",
+			in:   `<img src='//cdn.example.com/i.png'>`,
+			want: `<img src='/cdn.example.com/i.png'>`,
+		},
+		{
+			name: "unquoted attribute",
+			in:   `<a href=https://www.example.com/a class=x>`,
+			want: `<a href=/www.example.com/a class=x>`,
+		},
+		{
+			name: "upper case tag and attribute",
+			in:   `<A HREF="HTTPS://WWW.EXAMPLE.COM/a">`,
+			want: `<A HREF="/www.example.com/a">`,
+		},
+		{
+			name:         "root-relative link",
+			in:           `<link rel="stylesheet" href="/style.css">`,
+			want:         `<link rel="stylesheet" href="/www.example.com/style.css">`,
+			rootRelative: true,
+		},
+		{
+			name: "root-relative link kept when disabled",
+			in:   `<link rel="stylesheet" href="/style.css">`,
+			want: `<link rel="stylesheet" href="/style.css">`,
+		},
+		{
+			name: "other host untouched",
+			in:   `<a href="https://other.example.org/a">`,
+			want: `<a href="https://other.example.org/a">`,
+		},
+		{
+			name:         "srcset",
+			in:           `<img srcset="/a.png 1x, https://cdn.example.com/b.png 2x">`,
+			want:         `<img srcset="/www.example.com/a.png 1x, /cdn.example.com/b.png 2x">`,
+			rootRelative: true,
+		},
+		{
+			name: "srcset with data URL untouched",
+			in:   `<img srcset="data:image/png;base64,AAAA 1x, https://cdn.example.com/b.png 2x">`,
+			want: `<img srcset="data:image/png;base64,AAAA 1x, https://cdn.example.com/b.png 2x">`,
+		},
+		{
+			name: "base href",
+			in:   `<head><base href="https://www.example.com/app/"><a href="page">`,
+			want: `<head><base href="/www.example.com/app/"><a href="page">`,
+		},
+		{
+			name:         "comment untouched",
+			in:           `<!-- <a href="/x"> --><a href="/y">`,
+			want:         `<!-- <a href="/x"> --><a href="/www.example.com/y">`,
+			rootRelative: true,
+		},
+		{
+			name:         "script body rewrites absolute links only",
+			in:           `<script>fetch("https://www.example.com/api"); s = '<a href="/x">';</script><a href="/y">`,
+			want:         `<script>fetch("/www.example.com/api"); s = '<a href="/x">';</script><a href="/www.example.com/y">`,
+			rootRelative: true,
+		},
+		{
+			name:         "style body",
+			in:           `<style>body { background: url(/bg.png) } @import "https://cdn.example.com/c.css";</style>`,
+			want:         `<style>body { background: url(/www.example.com/bg.png) } @import "/cdn.example.com/c.css";</style>`,
+			rootRelative: true,
+		},
+		{
+			name:         "style attribute",
+			in:           `<div style="background: url('/bg.png')">`,
+			want:         `<div style="background: url('/www.example.com/bg.png')">`,
+			rootRelative: true,
+		},
+		{
+			name:         "meta refresh",
+			in:           `<meta http-equiv="Refresh" content="0; url=/login">`,
+			want:         `<meta http-equiv="Refresh" content="0; url=/www.example.com/login">`,
+			rootRelative: true,
+		},
+		{
+			name: "integrity removed from rewritten script",
+			in:   `<script src="https://cdn.example.com/a.js" integrity="sha384-abc" crossorigin></script>`,
+			want: `<script src="/cdn.example.com/a.js"  crossorigin></script>`,
+		},
+		{
+			name: "integrity kept when link is not rewritten",
+			in:   `<script src="https://other.example.org/a.js" integrity="sha384-abc"></script>`,
+			want: `<script src="https://other.example.org/a.js" integrity="sha384-abc"></script>`,
+		},
+		{
+			name: "unterminated tag left as is",
+			in:   `<p>text</p><a href="https://www.example.com/a`,
+			want: `<p>text</p><a href="https://www.example.com/a`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr := newTestLinkRewriter(tt.rootRelative)
+			if got := string(lr.html([]byte(tt.in))); got != tt.want {
+				t.Errorf("html(%s)\n got %s\nwant %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkRewriterCSS(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`a { background: url(/a.png) }`, `a { background: url(/www.example.com/a.png) }`},
+		{`a { background: url( "/a.png" ) }`, `a { background: url( "/www.example.com/a.png" ) }`},
+		{`a { background: URL('https://cdn.example.com/a.png') }`, `a { background: URL('/cdn.example.com/a.png') }`},
+		{`@import '/base.css';`, `@import '/www.example.com/base.css';`},
+		{`a { background: url(data:image/png;base64,AAAA) }`, `a { background: url(data:image/png;base64,AAAA) }`},
+		{`a { background: url(img/a.png) }`, `a { background: url(img/a.png) }`},
+	}
+
+	for _, tt := range tests {
+		lr := newTestLinkRewriter(true)
+		if got := string(lr.css([]byte(tt.in))); got != tt.want {
+			t.Errorf("css(%s)\n got %s\nwant %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLinkRewriterAbsolute(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`fetch("https://www.example.com/api/x?y=1")`, `fetch("/www.example.com/api/x?y=1")`},
+		{`{"url":"https:\/\/cdn.example.com\/a\/b.js"}`, `{"url":"\/cdn.example.com\/a\/b.js"}`},
+		{`load('//www.example.com')`, `load('/www.example.com/')`},
+		{`location = "https://www.example.com.cn/x"`, `location = "https://www.example.com.cn/x"`},
+		{`var a = "/api/x"`, `var a = "/api/x"`},
+	}
+
+	for _, tt := range tests {
+		lr := newTestLinkRewriter(true)
+		if got := string(lr.absolute([]byte(tt.in))); got != tt.want {
+			t.Errorf("absolute(%s)\n got %s\nwant %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// compress 使用指定编码压缩数据
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		return data
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestRewriteResponseBody(t *testing.T) {
+	const page = `<a href="https://www.example.com/a">`
+	const rewrittenPage = `<a href="/www.example.com/a">`
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		encoding    string // 上游响应使用的压缩方式，raw-deflate 表示不带 zlib 头的 deflate 数据
+		body        string
+		maxBodySize int64
+		want        string // 期望的响应体（解压后），为空时期望原样转发
+	}{
+		{name: "html", contentType: "text/html; charset=utf-8", body: page, want: rewrittenPage},
+		{name: "gzip html", contentType: "text/html", encoding: "gzip", body: page, want: rewrittenPage},
+		{name: "deflate html", contentType: "text/html", encoding: "deflate", body: page, want: rewrittenPage},
+		{name: "raw deflate html", contentType: "text/html", encoding: "raw-deflate", body: page, want: rewrittenPage},
+		{name: "css", contentType: "text/css", body: `a{background:url(https://www.example.com/a.png)}`, want: `a{background:url(/www.example.com/a.png)}`},
+		{name: "json", contentType: "application/json", body: `{"u":"https://www.example.com/a"}`, want: `{"u":"/www.example.com/a"}`},
+		{name: "image passed through", contentType: "image/svg+xml", body: page},
+		{name: "plain text passed through", contentType: "text/plain", body: page},
+		{name: "no content type passed through", body: page},
+		{name: "unsupported encoding passed through", contentType: "text/html", encoding: "br", body: page},
+		{name: "head passed through", method: http.MethodHead, contentType: "text/html", body: page},
+		{name: "no links keeps encoding", contentType: "text/html", encoding: "gzip", body: `<p>hello</p>`},
+		{name: "larger than max_body_size passed through", contentType: "text/html", body: page, maxBodySize: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := rewriteTestTable()
+			var route *Route
+			for _, r := range table.Routes() {
+				if r.Name == "www.example.com" {
+					route = r
+				}
+			}
+			route.Config.RewriteBody.MaxBodySize = tt.maxBodySize
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "http://192.168.1.2:8080/www.example.com/", nil)
+			req = withTarget(req, &target{vars: &requestVars{r: req}, links: table.links})
+
+			raw := compress(t, tt.encoding, []byte(tt.body))
+			resp := &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{},
+				Body:          io.NopCloser(bytes.NewReader(raw)),
+				ContentLength: int64(len(raw)),
+				Request:       req,
+			}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.encoding == "raw-deflate" {
+				resp.Header.Set("Content-Encoding", "deflate")
+			} else if tt.encoding != "" {
+				resp.Header.Set("Content-Encoding", tt.encoding)
+			}
+			resp.Header.Set("Content-Length", strconv.Itoa(len(raw)))
+			header := resp.Header.Clone()
+
+			if err := rewriteResponseBody(resp, route, table.links, testLogger()); err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want == "" {
+				if !bytes.Equal(got, raw) {
+					t.Errorf("body changed: got %q, want %q", got, raw)
+				}
+				if resp.Header.Get("Content-Encoding") != header.Get("Content-Encoding") || resp.Header.Get("Content-Length") != header.Get("Content-Length") {
+					t.Errorf("headers changed: got %v, want %v", resp.Header, header)
+				}
+				return
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+				t.Errorf("Content-Encoding = %q, want it removed", enc)
+			}
+			if cl := resp.Header.Get("Content-Length"); cl != strconv.Itoa(len(tt.want)) || resp.ContentLength != int64(len(tt.want)) {
+				t.Errorf("Content-Length = %s (%d), want %d", cl, resp.ContentLength, len(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"serve/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// circuitState 熔断器状态
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // 正常转发
+	circuitOpen                         // 熔断中，冷却期内不转发
+	circuitHalfOpen                     // 冷却期结束，放行少量试探请求
+)
+
+// String 获取熔断器状态名称
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker 上游目标的熔断器，根据实际转发结果进行被动异常检测
+type circuitBreaker struct {
+	route    string
+	addr     string
+	settings config.CircuitBreakerConfig
+	logger   *logrus.Logger
+	now      func() time.Time // 获取当前时间，测试中替换为假时钟
+
+	mu        sync.Mutex
+	state     circuitState
+	failures  int       // 连续失败次数
+	openUntil time.Time // 熔断冷却期的结束时间
+	trials    int       // 半开状态下进行中的试探请求数
+}
+
+// newCircuitBreaker 创建熔断器，初始状态为关闭（正常转发）
+func newCircuitBreaker(route, addr string, settings config.CircuitBreakerConfig, logger *logrus.Logger) *circuitBreaker {
+	return &circuitBreaker{
+		route:    route,
+		addr:     addr,
+		settings: settings,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// ready 判断目标当前是否可以参与负载均衡，不改变熔断器状态
+func (cb *circuitBreaker) ready() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		return !cb.now().Before(cb.openUntil)
+	case circuitHalfOpen:
+		return cb.trials < cb.settings.HalfOpenRequests
+	default:
+		return true
+	}
+}
+
+// allow 在转发前调用，冷却期结束时进入半开状态
+// 返回是否允许转发，以及本次请求是否占用了半开状态的试探名额
+func (cb *circuitBreaker) allow() (ok bool, trial bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if cb.now().Before(cb.openUntil) {
+			return false, false
+		}
+		cb.state = circuitHalfOpen
+		cb.trials = 0
+		cb.logger.Infof("Circuit half-open for upstream %s of proxy route %s, allowing %d trial request(s)",
+			cb.addr, cb.route, cb.settings.HalfOpenRequests)
+		fallthrough
+	case circuitHalfOpen:
+		if cb.trials >= cb.settings.HalfOpenRequests {
+			return false, false
+		}
+		cb.trials++
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// record 在转发结束后调用，记录转发结果
+// failed 表示 5xx 响应、连接错误或超时，trial 为 allow 返回的试探标记
+func (cb *circuitBreaker) record(failed, trial bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitClosed:
+		if !failed {
+			cb.failures = 0
+			return
+		}
+		cb.failures++
+		if cb.failures >= cb.settings.ConsecutiveFailures {
+			cb.open()
+			cb.logger.Warnf("Circuit opened for upstream %s of proxy route %s after %d consecutive failures, cooling down for %s",
+				cb.addr, cb.route, cb.failures, cb.settings.Cooldown)
+		}
+	case circuitHalfOpen:
+		// 进入半开状态之前发出的请求不影响试探结果
+		if !trial {
+			return
+		}
+		cb.trials--
+		if failed {
+			cb.failures++
+			cb.open()
+			cb.logger.Warnf("Circuit reopened for upstream %s of proxy route %s after a failed trial request, cooling down for %s",
+				cb.addr, cb.route, cb.settings.Cooldown)
+			return
+		}
+		cb.state = circuitClosed
+		cb.failures = 0
+		cb.logger.Infof("Circuit closed for upstream %s of proxy route %s after a successful trial request", cb.addr, cb.route)
+	}
+}
+
+// release 转发被客户端取消时调用，释放试探名额，不计入转发结果
+func (cb *circuitBreaker) release(trial bool) {
+	if !trial {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == circuitHalfOpen && cb.trials > 0 {
+		cb.trials--
+	}
+}
+
+// open 进入熔断状态，调用方需要持有锁
+func (cb *circuitBreaker) open() {
+	cb.state = circuitOpen
+	cb.openUntil = cb.now().Add(cb.settings.Cooldown.Duration())
+	cb.trials = 0
+}
+
+// retryAfter 获取熔断冷却期的剩余时间，未熔断时返回 0
+func (cb *circuitBreaker) retryAfter() time.Duration {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state != circuitOpen {
+		return 0
+	}
+	return cb.openUntil.Sub(cb.now())
+}
+
+// snapshot 获取熔断器状态和连续失败次数
+func (cb *circuitBreaker) snapshot() (circuitState, int) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state, cb.failures
+}
@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"serve/internal/config"
+)
+
+// fakeClock 测试使用的假时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newTestBreaker 创建使用假时钟的熔断器：连续失败 3 次熔断，冷却 10s，半开状态放行 halfOpen 个试探请求
+func newTestBreaker(halfOpen int) (*circuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	settings := config.CircuitBreakerConfig{ConsecutiveFailures: 3, Cooldown: config.Duration(10 * time.Second), HalfOpenRequests: halfOpen}
+	cb := newCircuitBreaker("api", "10.0.0.1:80", settings, testLogger())
+	cb.now = clock.Now
+	return cb, clock
+}
+
+// assertState 检查熔断器状态和连续失败次数
+func assertState(t *testing.T, cb *circuitBreaker, state circuitState, failures int) {
+	t.Helper()
+	if s, f := cb.snapshot(); s != state || f != failures {
+		t.Fatalf("state = %s (failures=%d), want %s (failures=%d)", s, f, state, failures)
+	}
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	cb, _ := newTestBreaker(1)
+
+	cb.record(true, false)
+	cb.record(true, false)
+	assertState(t, cb, circuitClosed, 2)
+
+	// 成功的请求清零连续失败次数
+	cb.record(false, false)
+	assertState(t, cb, circuitClosed, 0)
+
+	for i := 0; i < 3; i++ {
+		if ok, trial := cb.allow(); !ok || trial {
+			t.Fatalf("allow() = %t, %t while closed, want true, false", ok, trial)
+		}
+		cb.record(true, false)
+	}
+	assertState(t, cb, circuitOpen, 3)
+
+	if cb.ready() {
+		t.Error("ready() = true while open")
+	}
+	if ok, _ := cb.allow(); ok {
+		t.Error("allow() = true while open")
+	}
+	if got := cb.retryAfter(); got != 10*time.Second {
+		t.Errorf("retryAfter() = %s, want 10s", got)
+	}
+}
+
+func TestCircuitBreakerHalfOpenSuccess(t *testing.T) {
+	cb, clock := newTestBreaker(1)
+	for i := 0; i < 3; i++ {
+		cb.record(true, false)
+	}
+
+	clock.advance(9 * time.Second)
+	if cb.ready() {
+		t.Fatal("ready() = true before the cooldown ends")
+	}
+	if got := cb.retryAfter(); got != time.Second {
+		t.Errorf("retryAfter() = %s, want 1s", got)
+	}
+
+	clock.advance(time.Second)
+	if !cb.ready() {
+		t.Fatal("ready() = false after the cooldown")
+	}
+	// ready 不改变状态
+	assertState(t, cb, circuitOpen, 3)
+
+	ok, trial := cb.allow()
+	if !ok || !trial {
+		t.Fatalf("allow() = %t, %t after the cooldown, want a trial request", ok, trial)
+	}
+	assertState(t, cb, circuitHalfOpen, 3)
+	if got := cb.retryAfter(); got != 0 {
+		t.Errorf("retryAfter() = %s while half-open, want 0", got)
+	}
+
+	cb.record(false, trial)
+	assertState(t, cb, circuitClosed, 0)
+}
+
+func TestCircuitBreakerHalfOpenFailure(t *testing.T) {
+	cb, clock := newTestBreaker(1)
+	for i := 0; i < 3; i++ {
+		cb.record(true, false)
+	}
+	clock.advance(10 * time.Second)
+
+	_, trial := cb.allow()
+	cb.record(true, trial)
+	assertState(t, cb, circuitOpen, 4)
+
+	// 重新熔断后冷却期从失败时开始计算
+	if got := cb.retryAfter(); got != 10*time.Second {
+		t.Errorf("retryAfter() = %s, want 10s", got)
+	}
+	clock.advance(5 * time.Second)
+	if ok, _ := cb.allow(); ok {
+		t.Error("allow() = true during the new cooldown")
+	}
+}
+
+func TestCircuitBreakerHalfOpenRequests(t *testing.T) {
+	cb, clock := newTestBreaker(2)
+	for i := 0; i < 3; i++ {
+		cb.record(true, false)
+	}
+	clock.advance(10 * time.Second)
+
+	_, trial1 := cb.allow()
+	ok, trial2 := cb.allow()
+	if !ok || !trial2 {
+		t.Fatalf("second allow() = %t, %t, want a second trial request", ok, trial2)
+	}
+	if ok, _ := cb.allow(); ok {
+		t.Fatal("third allow() = true, want the half-open limit of 2 to be enforced")
+	}
+	if cb.ready() {
+		t.Error("ready() = true with all trial slots taken")
+	}
+
+	// 客户端取消的试探请求释放名额
+	cb.release(trial1)
+	if !cb.ready() {
+		t.Error("ready() = false after a trial slot was released")
+	}
+	ok, trial3 := cb.allow()
+	if !ok || !trial3 {
+		t.Fatalf("allow() = %t, %t after release, want a trial request", ok, trial3)
+	}
+
+	// 进入半开状态之前发出的请求不影响试探结果
+	cb.record(true, false)
+	assertState(t, cb, circuitHalfOpen, 3)
+
+	cb.record(false, trial2)
+	assertState(t, cb, circuitClosed, 0)
+
+	// 熔断器关闭后迟到的试探结果按普通请求统计
+	cb.record(true, trial3)
+	assertState(t, cb, circuitClosed, 1)
+}
+
+func TestCircuitStateString(t *testing.T) {
+	for state, want := range map[circuitState]string{circuitClosed: "closed", circuitOpen: "open", circuitHalfOpen: "half_open"} {
+		if got := state.String(); got != want {
+			t.Errorf("%d.String() = %q, want %q", state, got, want)
+		}
+	}
+}
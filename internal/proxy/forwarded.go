@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"serve/internal/config"
+)
+
+// xForwardedHeaders 转发给上游的 X-Forwarded-* 头
+var xForwardedHeaders = []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Forwarded-Prefix"}
+
+// setForwardedHeaders 按路由的转发头配置设置转发给上游的 X-Forwarded-* 或 Forwarded 头
+// 由 RoundTrip 在 ReverseProxy 追加 X-Forwarded-For 之后调用，未配置时保持 ReverseProxy 的默认行为（只追加 X-Forwarded-For）
+func setForwardedHeaders(req *http.Request, pc *config.ProxyConfig, pathPrefix string) {
+	f := pc.Forwarded
+	if f == nil {
+		return
+	}
+
+	ip := clientIP(req)
+	trusted := f.IsTrusted(ip)
+	if !trusted {
+		// 不可信的客户端发送的转发头可能是伪造的，全部丢弃后重新生成
+		for _, name := range xForwardedHeaders {
+			req.Header.Del(name)
+		}
+		req.Header.Del("Forwarded")
+	}
+
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+
+	if f.SendsXForwarded() {
+		if !trusted {
+			// 可信代理的 X-Forwarded-For 已由 ReverseProxy 在其后追加客户端地址
+			req.Header.Set("X-Forwarded-For", ip)
+		}
+		setHeaderIfAbsent(req.Header, "X-Forwarded-Proto", proto)
+		setHeaderIfAbsent(req.Header, "X-Forwarded-Host", req.Host)
+		if pathPrefix != "" && (pc.ReplacePrefix != "" || pc.ShouldStripPrefix()) {
+			// 上游收到的路径不包含路径前缀，通过 X-Forwarded-Prefix 告知上游，可信代理传来的前缀拼接在前面
+			req.Header.Set("X-Forwarded-Prefix", joinURLPath(req.Header.Get("X-Forwarded-Prefix"), "/"+pathPrefix))
+		}
+	} else {
+		for _, name := range xForwardedHeaders {
+			req.Header.Del(name)
+		}
+	}
+
+	if f.SendsForwarded() {
+		element := "for=" + forwardedNode(ip) + ";host=" + forwardedValue(req.Host) + ";proto=" + proto
+		if prior := req.Header.Values("Forwarded"); len(prior) > 0 {
+			element = strings.Join(prior, ", ") + ", " + element
+		}
+		req.Header.Set("Forwarded", element)
+	} else {
+		req.Header.Del("Forwarded")
+	}
+}
+
+// setHeaderIfAbsent 头不存在时设置
+func setHeaderIfAbsent(h http.Header, name, value string) {
+	if h.Get(name) == "" {
+		h.Set(name, value)
+	}
+}
+
+// forwardedNode 格式化 Forwarded 头的 for 参数（RFC 7239 第 6 节），IPv6 地址加方括号并加引号
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return forwardedValue(ip)
+}
+
+// forwardedValue 格式化 Forwarded 头的参数值，不是 token 时使用 quoted-string
+func forwardedValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	for _, r := range value {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune("()<>@,;:\\\"/[]?={}", r) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	}
+	return value
+}
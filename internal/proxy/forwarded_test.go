@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"serve/internal/config"
+)
+
+// forwardedRequest 通过指定配置的代理路由转发请求，返回上游收到的请求头和 Host
+func forwardedRequest(t *testing.T, pc *config.ProxyConfig, remoteAddr string, header http.Header) (http.Header, string) {
+	t.Helper()
+	var got http.Header
+	var gotHost string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, gotHost = r.Header.Clone(), r.Host
+	}))
+	defer upstream.Close()
+
+	pc.TargetDomain = upstream.Listener.Addr().String()
+	h := NewHandler(map[string]*config.ProxyConfig{"api": pc}, testLogger())
+	defer h.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Host = "www.local"
+	req.RemoteAddr = remoteAddr
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusOK)
+	}
+	return got, gotHost
+}
+
+func TestForwardedHeaders(t *testing.T) {
+	strip := true
+	spoofed := http.Header{
+		"X-Forwarded-For":    {"1.2.3.4"},
+		"X-Forwarded-Proto":  {"https"},
+		"X-Forwarded-Host":   {"evil.local"},
+		"X-Forwarded-Prefix": {"/outer"},
+		"Forwarded":          {"for=1.2.3.4;proto=https"},
+	}
+	trusted := &config.ForwardedConfig{Mode: config.ForwardedModeBoth, TrustedProxies: []string{"10.0.0.0/8"}}
+
+	tests := []struct {
+		name       string
+		forwarded  *config.ForwardedConfig
+		remoteAddr string
+		header     http.Header
+		want       map[string]string // 期望的头，值为空表示不应存在
+	}{
+		{
+			name:       "default appends X-Forwarded-For only",
+			remoteAddr: "192.0.2.1:40000",
+			header:     http.Header{"X-Forwarded-For": {"1.2.3.4"}, "X-Forwarded-Proto": {"https"}},
+			want: map[string]string{
+				"X-Forwarded-For":   "1.2.3.4, 192.0.2.1",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "",
+				"Forwarded":         "",
+			},
+		},
+		{
+			name:       "trusted proxy appends",
+			forwarded:  trusted,
+			remoteAddr: "10.0.0.5:40000",
+			header:     spoofed,
+			want: map[string]string{
+				"X-Forwarded-For":    "1.2.3.4, 10.0.0.5",
+				"X-Forwarded-Proto":  "https",
+				"X-Forwarded-Host":   "evil.local",
+				"X-Forwarded-Prefix": "/outer/api",
+				"Forwarded":          "for=1.2.3.4;proto=https, for=10.0.0.5;host=www.local;proto=http",
+			},
+		},
+		{
+			name:       "untrusted client replaces",
+			forwarded:  trusted,
+			remoteAddr: "192.0.2.1:40000",
+			header:     spoofed,
+			want: map[string]string{
+				"X-Forwarded-For":    "192.0.2.1",
+				"X-Forwarded-Proto":  "http",
+				"X-Forwarded-Host":   "www.local",
+				"X-Forwarded-Prefix": "/api",
+				"Forwarded":          "for=192.0.2.1;host=www.local;proto=http",
+			},
+		},
+		{
+			// 逐跳头在转发头之前移除，客户端不能借此移除或伪造生成的转发头
+			name:       "untrusted client lists forwarded headers in Connection",
+			forwarded:  &config.ForwardedConfig{},
+			remoteAddr: "[2001:db8::1]:40000",
+			header: http.Header{
+				"Connection":        {"X-Forwarded-For, X-Forwarded-Proto"},
+				"X-Forwarded-For":   {"1.2.3.4"},
+				"X-Forwarded-Proto": {"https"},
+			},
+			want: map[string]string{
+				"X-Forwarded-For":   "2001:db8::1",
+				"X-Forwarded-Proto": "http",
+				"X-Forwarded-Host":  "www.local",
+			},
+		},
+		{
+			name:       "forwarded mode drops X-Forwarded",
+			forwarded:  &config.ForwardedConfig{Mode: config.ForwardedModeRFC7239},
+			remoteAddr: "[2001:db8::1]:40000",
+			header:     spoofed,
+			want: map[string]string{
+				"X-Forwarded-For":   "",
+				"X-Forwarded-Proto": "",
+				"Forwarded":         `for="[2001:db8::1]";host=www.local;proto=http`,
+			},
+		},
+		{
+			name:       "none mode",
+			forwarded:  &config.ForwardedConfig{Mode: config.ForwardedModeNone, TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.0.0.5:40000",
+			header:     http.Header{"Connection": {"X-Forwarded-For"}, "X-Forwarded-For": {"1.2.3.4"}, "Forwarded": {"for=1.2.3.4"}},
+			want: map[string]string{
+				"X-Forwarded-For": "",
+				"Forwarded":       "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := &config.ProxyConfig{StripPrefix: &strip, Forwarded: tt.forwarded}
+			got, _ := forwardedRequest(t, pc, tt.remoteAddr, tt.header)
+			for name, want := range tt.want {
+				if values := got.Values(name); want == "" && len(values) > 0 {
+					t.Errorf("%s = %q, want absent", name, values)
+				} else if want != "" && (len(values) != 1 || values[0] != want) {
+					t.Errorf("%s = %q, want %q", name, values, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPreserveHost(t *testing.T) {
+	if _, host := forwardedRequest(t, &config.ProxyConfig{PreserveHost: true}, "192.0.2.1:40000", nil); host != "www.local" {
+		t.Errorf("preserve_host: upstream Host = %q, want %q", host, "www.local")
+	}
+	if _, host := forwardedRequest(t, &config.ProxyConfig{}, "192.0.2.1:40000", nil); host == "www.local" {
+		t.Error("without preserve_host: upstream received the original Host")
+	}
+
+	// request_headers 中设置的 Host 优先
+	pc := &config.ProxyConfig{
+		PreserveHost:   true,
+		RequestHeaders: &config.HeaderRules{Set: map[string]string{"Host": "internal.local"}},
+	}
+	if _, host := forwardedRequest(t, pc, "192.0.2.1:40000", nil); host != "internal.local" {
+		t.Errorf("request_headers Host: upstream Host = %q, want %q", host, "internal.local")
+	}
+}
+
+func TestForwardedValue(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"www.local", "www.local"},
+		{"www.local:8443", `"www.local:8443"`},
+		{"", `""`},
+		{`a"b\c`, `"a\"b\\c"`},
+	}
+	for _, tt := range tests {
+		if got := forwardedValue(tt.value); got != tt.want {
+			t.Errorf("forwardedValue(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+	if got := forwardedNode("::1"); got != `"[::1]"` {
+		t.Errorf("forwardedNode(::1) = %s", got)
+	}
+}
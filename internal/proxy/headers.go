@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"serve/internal/config"
+)
+
+// requestVars 请求头和响应头模板变量的取值，同一个请求的请求头和响应头使用相同的值
+type requestVars struct {
+	r         *http.Request // 客户端的原始请求
+	requestID string        // 请求 ID，首次使用时确定
+}
+
+// lookup 获取模板变量的值
+func (v *requestVars) lookup(name string) string {
+	switch name {
+	case config.HeaderVarClientIP:
+		return clientIP(v.r)
+	case config.HeaderVarRequestID:
+		if v.requestID == "" {
+			v.requestID = v.r.Header.Get("X-Request-Id")
+		}
+		if v.requestID == "" {
+			v.requestID = newRequestID()
+		}
+		return v.requestID
+	case config.HeaderVarHost:
+		return v.r.Host
+	case config.HeaderVarScheme:
+		if v.r.TLS != nil {
+			return "https"
+		}
+		return "http"
+	default:
+		return ""
+	}
+}
+
+// newRequestID 生成随机的请求 ID（32 位十六进制）
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// applyHeaderRules 按 remove、set、add 的顺序修改头，返回 Host 头的设置值（未设置时为空）
+// Host 不在头列表中，由调用方设置到请求上
+func applyHeaderRules(h http.Header, rules *config.HeaderRules, vars *requestVars) string {
+	if rules.IsEmpty() {
+		return ""
+	}
+
+	for _, name := range rules.Remove {
+		h.Del(name)
+	}
+
+	host := ""
+	for name, value := range rules.Set {
+		value = config.ExpandHeaderValue(value, vars.lookup)
+		if http.CanonicalHeaderKey(name) == "Host" {
+			host = value
+			continue
+		}
+		h.Set(name, value)
+	}
+	for name, value := range rules.Add {
+		h.Add(name, config.ExpandHeaderValue(value, vars.lookup))
+	}
+	return host
+}
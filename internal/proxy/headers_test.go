@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"serve/internal/config"
+)
+
+func TestApplyHeaderRules(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	r.Host = "www.local"
+	r.RemoteAddr = "10.0.0.1:40000"
+	r.Header.Set("X-Request-Id", "req-1")
+	vars := &requestVars{r: r}
+
+	h := http.Header{}
+	h.Set("X-Old", "old")
+	h.Set("X-Env", "prod")
+	h.Add("X-Tag", "a")
+	rules := &config.HeaderRules{
+		// 先移除再设置，X-Env 最终为设置的值；追加在设置之后，保留已有的值
+		Remove: []string{"X-Old", "X-Env"},
+		Set: map[string]string{
+			"X-Env":       "staging",
+			"X-Client":    "{client_ip}",
+			"X-Origin":    "{scheme}://{host}",
+			"X-Unknown":   "{user}",
+			"host":        "internal.{host}",
+			"X-Request":   "{request_id}",
+			"X-Untouched": "plain",
+		},
+		Add: map[string]string{"X-Tag": "b", "X-Request": "{request_id}"},
+	}
+
+	host := applyHeaderRules(h, rules, vars)
+	if host != "internal.www.local" {
+		t.Errorf("host = %q, want %q", host, "internal.www.local")
+	}
+	want := http.Header{
+		"X-Env":       {"staging"},
+		"X-Client":    {"10.0.0.1"},
+		"X-Origin":    {"http://www.local"},
+		"X-Unknown":   {"{user}"},
+		"X-Request":   {"req-1", "req-1"},
+		"X-Untouched": {"plain"},
+		"X-Tag":       {"a", "b"},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("headers = %v, want %v", h, want)
+	}
+
+	if host := applyHeaderRules(h, nil, vars); host != "" {
+		t.Errorf("nil rules: host = %q, want empty", host)
+	}
+}
+
+func TestRequestVarsRequestID(t *testing.T) {
+	vars := &requestVars{r: httptest.NewRequest(http.MethodGet, "/", nil)}
+	id := vars.lookup(config.HeaderVarRequestID)
+	if len(id) != 32 {
+		t.Fatalf("generated request ID %q, want 32 hex characters", id)
+	}
+	// 同一个请求的请求头和响应头使用相同的请求 ID
+	if again := vars.lookup(config.HeaderVarRequestID); again != id {
+		t.Errorf("second lookup = %q, want %q", again, id)
+	}
+}
+
+func TestServeHTTPHeaderRules(t *testing.T) {
+	var got http.Header
+	var gotHost string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, gotHost = r.Header.Clone(), r.Host
+		w.Header().Set("Server", "backend")
+		w.Header().Set("X-Powered-By", "php")
+		w.Header().Set("X-Up-Hop", "1")
+		w.Header().Set("Connection", "X-Up-Hop")
+	}))
+	defer upstream.Close()
+
+	h := NewHandler(map[string]*config.ProxyConfig{
+		"api": {
+			TargetDomain: upstream.Listener.Addr().String(),
+			RequestHeaders: &config.HeaderRules{
+				Set:    map[string]string{"X-Api-Key": "secret", "Host": "{host}", "X-Real-Ip": "{client_ip}"},
+				Add:    map[string]string{"X-Tag": "proxy"},
+				Remove: []string{"X-Forwarded-For", "Cookie"},
+			},
+			ResponseHeaders: &config.HeaderRules{
+				Set:    map[string]string{"X-Up-Hop": "rule", "X-Request-Id": "{request_id}"},
+				Remove: []string{"Server", "X-Powered-By"},
+			},
+		},
+	}, testLogger())
+	defer h.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Host = "www.local"
+	req.RemoteAddr = "10.0.0.1:40000"
+	req.Header.Set("Cookie", "session=1")
+	req.Header.Set("X-Tag", "client")
+	req.Header.Set("X-Request-Id", "req-1")
+	// 客户端通过 Connection 头列出的逐跳头会被移除，但不能移除规则设置的头
+	req.Header.Set("Connection", "X-Api-Key, X-Hop")
+	req.Header.Set("X-Api-Key", "forged")
+	req.Header.Set("X-Hop", "1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if gotHost != "www.local" {
+		t.Errorf("upstream Host = %q, want %q", gotHost, "www.local")
+	}
+	if v := got.Get("X-Api-Key"); v != "secret" {
+		t.Errorf("X-Api-Key = %q, want %q", v, "secret")
+	}
+	if v := got.Get("X-Real-Ip"); v != "10.0.0.1" {
+		t.Errorf("X-Real-Ip = %q, want %q", v, "10.0.0.1")
+	}
+	if v := got.Values("X-Tag"); !reflect.DeepEqual(v, []string{"client", "proxy"}) {
+		t.Errorf("X-Tag = %v, want [client proxy]", v)
+	}
+	for _, name := range []string{"X-Forwarded-For", "Cookie", "X-Hop"} {
+		if v, ok := got[name]; ok {
+			t.Errorf("%s = %v, want removed", name, v)
+		}
+	}
+
+	// 上游的逐跳头先被移除，响应头规则再设置
+	if v := rec.Header().Get("X-Up-Hop"); v != "rule" {
+		t.Errorf("response X-Up-Hop = %q, want %q", v, "rule")
+	}
+	if v := rec.Header().Get("X-Request-Id"); v != "req-1" {
+		t.Errorf("response X-Request-Id = %q, want %q", v, "req-1")
+	}
+	for _, name := range []string{"Server", "X-Powered-By"} {
+		if v := rec.Header().Get(name); v != "" {
+			t.Errorf("response %s = %q, want removed", name, v)
+		}
+	}
+}
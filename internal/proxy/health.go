@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"serve/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// healthCheckUserAgent 健康检查请求的 User-Agent
+const healthCheckUserAgent = "serve-health-check"
+
+// healthChecker 路由的主动健康检查，每个上游目标一个 goroutine
+// 热加载时随路由的上游转发器一起重建，目标的健康状态保存在 backend 中，不会因热加载丢失
+type healthChecker struct {
+	route    string
+	scheme   string
+	settings config.HealthCheckConfig
+	client   *http.Client
+	logger   *logrus.Logger
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// newHealthChecker 创建健康检查并为每个上游目标启动检查
+// 检查请求使用路由的连接池，因此与转发请求使用相同的 TLS 设置
+func newHealthChecker(route *Route, transport *http.Transport, backends []*backend, logger *logrus.Logger) *healthChecker {
+	c := &healthChecker{
+		route:    route.Name,
+		scheme:   "http",
+		settings: route.Config.HealthCheck.WithDefaults(),
+		client: &http.Client{
+			Transport: transport,
+			// 不跟随重定向，3xx 由 expected_status 判断
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger: logger,
+		stop:   make(chan struct{}),
+	}
+	if route.Config.UseHTTPS {
+		c.scheme = "https"
+	}
+
+	for _, b := range backends {
+		go c.run(b)
+	}
+	logger.Debugf("Health checks started for proxy route %s: path=%s, interval=%s, timeout=%s, healthy_threshold=%d, unhealthy_threshold=%d",
+		c.route, c.settings.Path, c.settings.Interval, c.settings.Timeout, c.settings.HealthyThreshold, c.settings.UnhealthyThreshold)
+	return c
+}
+
+// close 停止所有检查
+func (c *healthChecker) close() {
+	c.once.Do(func() {
+		close(c.stop)
+	})
+}
+
+// run 定期检查单个上游目标，启动后立即进行第一次检查
+func (c *healthChecker) run(b *backend) {
+	ticker := time.NewTicker(c.settings.Interval.Duration())
+	defer ticker.Stop()
+
+	successes, failures := 0, 0
+	for {
+		err := c.probe(b)
+		b.recordCheck(err)
+
+		if err == nil {
+			successes, failures = successes+1, 0
+			if !b.healthy.Load() && successes >= c.settings.HealthyThreshold {
+				b.healthy.Store(true)
+				c.logger.Infof("Upstream %s of proxy route %s is healthy again after %d consecutive successful health checks",
+					b.addr, c.route, successes)
+			}
+		} else {
+			successes, failures = 0, failures+1
+			c.logger.Debugf("Health check failed for upstream %s of proxy route %s (%d/%d): %v",
+				b.addr, c.route, failures, c.settings.UnhealthyThreshold, err)
+			if b.healthy.Load() && failures >= c.settings.UnhealthyThreshold {
+				b.healthy.Store(false)
+				c.logger.Warnf("Upstream %s of proxy route %s marked unhealthy after %d consecutive failed health checks: %v",
+					b.addr, c.route, failures, err)
+			}
+		}
+
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe 向上游目标发送一次检查请求
+func (c *healthChecker) probe(b *backend) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.settings.Timeout.Duration())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.scheme+"://"+b.addr+c.settings.Path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", healthCheckUserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if !c.settings.IsExpectedStatus(resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"serve/internal/config"
+)
+
+// waitFor 等待条件成立，超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// fastHealthCheck 检查间隔很短、阈值为 1 的健康检查配置
+func fastHealthCheck(path string) *config.HealthCheckConfig {
+	return &config.HealthCheckConfig{
+		Path:               path,
+		Interval:           config.Duration(20 * time.Millisecond),
+		Timeout:            config.Duration(10 * time.Millisecond),
+		HealthyThreshold:   1,
+		UnhealthyThreshold: 1,
+	}
+}
+
+func TestHealthCheckTogglesUpstream(t *testing.T) {
+	var healthy atomic.Bool
+	var probeAgent atomic.Value
+	healthy.Store(true)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			probeAgent.Store(r.UserAgent())
+			if !healthy.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	h := NewHandler(map[string]*config.ProxyConfig{
+		"api": {TargetDomain: upstream.Listener.Addr().String(), HealthCheck: fastHealthCheck("/healthz")},
+	}, testLogger())
+	defer h.Close()
+
+	status := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+		return rec.Code
+	}
+	upstreamHealthy := func() bool { return h.Stats()[0].Upstreams[0].Healthy }
+
+	waitFor(t, "first health check", func() bool { return h.Stats()[0].Upstreams[0].LastCheck != nil })
+	if code := status(); code != http.StatusOK {
+		t.Fatalf("healthy upstream: status %d, want %d", code, http.StatusOK)
+	}
+	if agent := probeAgent.Load(); agent != healthCheckUserAgent {
+		t.Errorf("health check User-Agent = %v, want %q", agent, healthCheckUserAgent)
+	}
+
+	healthy.Store(false)
+	waitFor(t, "upstream marked unhealthy", func() bool { return !upstreamHealthy() })
+	if code := status(); code != http.StatusServiceUnavailable {
+		t.Fatalf("no healthy upstream: status %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if stats := h.Stats()[0].Upstreams[0]; stats.LastError != "unexpected status 503" {
+		t.Errorf("last_error = %q, want %q", stats.LastError, "unexpected status 503")
+	}
+
+	healthy.Store(true)
+	waitFor(t, "upstream healthy again", upstreamHealthy)
+	if code := status(); code != http.StatusOK {
+		t.Fatalf("recovered upstream: status %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestHealthCheckStopsOnReload(t *testing.T) {
+	var probes atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+	}))
+	defer upstream.Close()
+
+	h := NewHandler(map[string]*config.ProxyConfig{
+		"api": {TargetDomain: upstream.Listener.Addr().String(), HealthCheck: fastHealthCheck("/")},
+	}, testLogger())
+	waitFor(t, "first health check", func() bool { return probes.Load() > 0 })
+
+	// 热加载移除健康检查后旧的检查停止
+	h.Update(map[string]*config.ProxyConfig{
+		"api": {TargetDomain: upstream.Listener.Addr().String()},
+	})
+	time.Sleep(50 * time.Millisecond)
+	stopped := probes.Load()
+	time.Sleep(100 * time.Millisecond)
+	if got := probes.Load(); got != stopped {
+		t.Errorf("health checks continued after reload: %d probes, want %d", got, stopped)
+	}
+	if h.Stats()[0].HealthCheck {
+		t.Error("route still reports health_check after it was removed")
+	}
+}
@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// linkIndex 代理目标地址到路由的索引，用于改写响应体中指向代理目标的链接
+type linkIndex struct {
+	hosts   map[string][]*Route // 小写的目标地址（host 或 host:port）到路由，按路由优先级排序
+	pattern *regexp.Regexp      // 匹配指向任意目标地址的绝对链接的开头（协议和地址），没有目标地址时为 nil
+}
+
+// newLinkIndex 根据路由表中所有路由的上游目标构建链接索引
+func newLinkIndex(routes []*Route) *linkIndex {
+	index := &linkIndex{hosts: make(map[string][]*Route)}
+	for _, route := range routes {
+		for _, target := range route.Config.UpstreamTargets(route.PathPrefix) {
+			addr := strings.ToLower(target.Address)
+			if addr == "" || containsRoute(index.hosts[addr], route) {
+				continue
+			}
+			index.hosts[addr] = append(index.hosts[addr], route)
+		}
+	}
+	if len(index.hosts) == 0 {
+		return index
+	}
+
+	// 地址按长度降序排列，保证较长的地址优先匹配
+	hosts := make([]string, 0, len(index.hosts))
+	for addr := range index.hosts {
+		host := addr
+		if h, _, err := net.SplitHostPort(addr); err == nil {
+			host = h
+			if strings.Contains(h, ":") {
+				host = "[" + h + "]"
+			}
+		}
+		hosts = append(hosts, regexp.QuoteMeta(host))
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if len(hosts[i]) != len(hosts[j]) {
+			return len(hosts[i]) > len(hosts[j])
+		}
+		return hosts[i] < hosts[j]
+	})
+	index.pattern = regexp.MustCompile(`(?i)(?:https?:)?(?:/|\\/){2}(?:` + strings.Join(hosts, "|") + `)(?::\d+)?`)
+	return index
+}
+
+// lookup 查找地址对应的路由，链接中的默认端口（80、443）与不带端口的目标地址相同
+func (index *linkIndex) lookup(host string) []*Route {
+	host = strings.ToLower(host)
+	if routes, ok := index.hosts[host]; ok {
+		return routes
+	}
+	if h, port, err := net.SplitHostPort(host); err == nil && (port == "80" || port == "443") {
+		if strings.Contains(h, ":") {
+			h = "[" + h + "]"
+		}
+		return index.hosts[h]
+	}
+	return nil
+}
+
+// containsRoute 判断路由是否在列表中
+func containsRoute(routes []*Route, route *Route) bool {
+	for _, r := range routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// linkRewriter 单个响应的链接改写器
+type linkRewriter struct {
+	index        *linkIndex
+	route        *Route // 响应所属的路由，以 / 开头的链接按此路由还原
+	host         string // 客户端请求的 Host，只改写到与此主机匹配的路由
+	rootRelative bool   // 是否改写以 / 开头的链接
+	rewritten    int    // 改写的链接数
+}
+
+// rewriteURL 改写单个链接（HTML 属性或 CSS url() 的值）
+// 指向代理目标的绝对链接和协议相对链接改写为经过对应路径前缀的链接，以 / 开头的链接加上当前路由的路径前缀
+func (lr *linkRewriter) rewriteURL(raw string) (string, bool) {
+	value := strings.TrimSpace(raw)
+	lead := raw[:strings.Index(raw, value)]
+	trail := raw[len(lead)+len(value):]
+
+	var rewritten string
+	var ok bool
+	switch {
+	case hasSchemePrefix(value, "http://"), hasSchemePrefix(value, "https://"), strings.HasPrefix(value, "//"):
+		rest := value[strings.Index(value, "//")+2:]
+		end := strings.IndexAny(rest, "/?#")
+		if end < 0 {
+			end = len(rest)
+		}
+		path, suffix := splitURLPath(rest[end:])
+		if rewritten, ok = lr.mapHost(rest[:end], path); ok {
+			rewritten += suffix
+		}
+	case strings.HasPrefix(value, "/") && lr.rootRelative:
+		path, suffix := splitURLPath(value)
+		if rewritten, ok = reversePath(lr.route.Config, lr.route.PathPrefix, path); ok {
+			rewritten += suffix
+		}
+	}
+	if !ok || rewritten == value {
+		return raw, false
+	}
+	lr.rewritten++
+	return lead + rewritten + trail, true
+}
+
+// mapHost 将目标地址上的路径还原为经过代理的请求路径
+func (lr *linkRewriter) mapHost(host, path string) (string, bool) {
+	if path == "" {
+		path = "/"
+	}
+	for _, route := range lr.index.lookup(host) {
+		if !route.matchHost(lr.host) {
+			continue
+		}
+		if p, ok := reversePath(route.Config, route.PathPrefix, path); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// absolute 改写文本（JavaScript、JSON 等）中指向代理目标的绝对链接和协议相对链接，支持 JSON 转义的 \/
+// 无法确定边界的以 / 开头的路径不改写
+func (lr *linkRewriter) absolute(src []byte) []byte {
+	if lr.index.pattern == nil {
+		return src
+	}
+	matches := lr.index.pattern.FindAllIndex(src, -1)
+	if len(matches) == 0 {
+		return src
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		// 地址之后还有主机名字符说明是其他域名（如 example.com.cn），协议相对链接之前是字母数字说明是其他协议
+		if end < len(src) && isHostChar(src[end]) {
+			continue
+		}
+		if src[start] != 'h' && src[start] != 'H' && start > 0 && (isHostChar(src[start-1]) || src[start-1] == ':') {
+			continue
+		}
+
+		match := src[start:end]
+		escaped := bytes.Contains(match, []byte(`\/`))
+		host := string(match[bytes.LastIndexByte(match, '/')+1:])
+		p := end
+		for p < len(src) {
+			if escaped && src[p] == '\\' && p+1 < len(src) && src[p+1] == '/' {
+				p += 2
+				continue
+			}
+			if !isPathChar(src[p]) {
+				break
+			}
+			p++
+		}
+		path := string(src[end:p])
+		if escaped {
+			path = strings.ReplaceAll(path, `\/`, "/")
+		}
+
+		rewritten, ok := lr.mapHost(host, path)
+		if !ok {
+			continue
+		}
+		if escaped {
+			rewritten = strings.ReplaceAll(rewritten, "/", `\/`)
+		}
+		out.Write(src[last:start])
+		out.WriteString(rewritten)
+		last = p
+		lr.rewritten++
+	}
+	if last == 0 {
+		return src
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
+
+// hasSchemePrefix 判断链接是否以指定协议开头（不区分大小写）
+func hasSchemePrefix(value, scheme string) bool {
+	return len(value) >= len(scheme) && strings.EqualFold(value[:len(scheme)], scheme)
+}
+
+// splitURLPath 将链接拆分为路径和查询参数、片段部分
+func splitURLPath(value string) (string, string) {
+	if i := strings.IndexAny(value, "?#"); i >= 0 {
+		return value[:i], value[i:]
+	}
+	return value, ""
+}
+
+// isHostChar 判断是否为主机名中的字符
+func isHostChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_'
+}
+
+// isPathChar 判断是否为文本中链接路径的字符，不包括常用作字符串或 url() 边界的引号、括号和逗号
+func isPathChar(c byte) bool {
+	return isHostChar(c) || strings.IndexByte("~!$&*+=:@%/", c) >= 0
+}
@@ -1,13 +1,11 @@
 package proxy
 
 import (
-	"crypto/tls"
 	"fmt"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
-	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"serve/internal/config"
 
@@ -16,163 +14,183 @@ import (
 
 // Handler 反向代理处理器
 type Handler struct {
-	config *config.Config
+	routes atomic.Pointer[RouteTable] // 当前生效的路由表快照，热加载时整体替换
 	logger *logrus.Logger
 }
 
 // NewHandler 创建反向代理处理器
-func NewHandler(cfg *config.Config, logger *logrus.Logger) *Handler {
-	return &Handler{
-		config: cfg,
+func NewHandler(proxyConfigs map[string]*config.ProxyConfig, logger *logrus.Logger) *Handler {
+	h := &Handler{
 		logger: logger,
 	}
+	h.Update(proxyConfigs)
+	return h
 }
 
-// ServeHTTP 处理代理请求
-func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// 解析请求路径
-	path := r.URL.Path
+// Update 根据代理配置重建路由表并原子替换，进行中的请求继续使用旧路由表
+// 每个路由持有长期复用的反向代理和连接池，同名路由的连接设置未变化时沿用原有连接池，
+// 不再使用的连接池在替换后关闭空闲连接，旧路由表的健康检查在替换后停止
+func (h *Handler) Update(proxyConfigs map[string]*config.ProxyConfig) {
+	previous := make(map[string]*upstream)
+	if old := h.routes.Load(); old != nil {
+		for _, route := range old.Routes() {
+			previous[route.Name] = route.upstream
+		}
+	}
 
-	// 移除开头的 "/"
-	path = strings.TrimPrefix(path, "/")
+	table := NewRouteTable(proxyConfigs)
+	reused := make(map[*http.Transport]bool)
+	for i, route := range table.Routes() {
+		route.upstream = newUpstream(route, previous[route.Name], h.logger)
+		reused[route.upstream.transport] = true
+		settings := route.Config.Transport.WithDefaults()
+		h.logger.Debugf("Proxy route #%d: name=%s, prefix=/%s, hosts=%v, methods=%v, priority=%d, dial_timeout=%s, keep_alive=%s, idle_conn_timeout=%s, max_idle_conns_per_host=%d",
+			i, route.Name, route.PathPrefix, route.Config.Hosts, route.Config.Methods, route.Config.Priority,
+			settings.DialTimeout, settings.KeepAlive, settings.IdleConnTimeout, settings.MaxIdleConnsPerHost)
+		if len(route.upstream.backends) > 1 {
+			h.logger.Infof("Proxy route %s: load balancing across %d upstreams (policy=%s): %s",
+				route.Name, len(route.upstream.backends), route.upstream.policy, formatBackends(route.upstream.backends))
+		}
+	}
+	h.routes.Store(table)
 
-	// 分割路径，第一段作为目标域名
-	pathParts := strings.Split(path, "/")
-	if len(pathParts) == 0 || pathParts[0] == "" {
-		h.logger.Warnf("Invalid proxy path: %s", r.URL.Path)
-		http.Error(w, "Invalid proxy path", http.StatusBadRequest)
-		return
+	for _, u := range previous {
+		u.close()
+		if !reused[u.transport] {
+			u.transport.CloseIdleConnections()
+		}
 	}
+}
 
-	pathPrefix := pathParts[0]
+// Close 停止所有路由的健康检查并关闭空闲连接，关闭后处理器仍然可以转发请求
+func (h *Handler) Close() {
+	for _, route := range h.routes.Load().Routes() {
+		route.upstream.close()
+		route.upstream.transport.CloseIdleConnections()
+	}
+}
 
-	// 检查是否存在该路径前缀的代理配置
-	proxyConfig, exists := h.config.GetProxyConfig(pathPrefix)
+// ServeHTTP 处理代理请求
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 查找匹配的代理路由
+	table := h.routes.Load()
+	route, exists := table.Match(r)
 	if !exists {
-		h.logger.Debugf("No proxy config found for path prefix: %s", pathPrefix)
-		http.Error(w, fmt.Sprintf("No proxy configuration found for path prefix: %s", pathPrefix), http.StatusNotFound)
+		h.logger.Debugf("No proxy route found for request: %s %s%s", r.Method, r.Host, r.URL.Path)
+		http.Error(w, fmt.Sprintf("No proxy configuration found for path: %s", r.URL.Path), http.StatusNotFound)
 		return
 	}
 
-	// 确定目标域名：如果配置中指定了目标域名则使用配置的，否则使用路径第一段
-	targetDomain := proxyConfig.TargetDomain
-	if targetDomain == "" {
-		targetDomain = pathPrefix
-	}
+	proxyConfig := route.Config
+	pathPrefix := route.PathPrefix
+	h.logger.Debugf("Matched proxy route: %s (prefix=/%s) for %s %s%s", route.Name, pathPrefix, r.Method, r.Host, r.URL.Path)
 
 	// 构建目标 URL
-	// 如果配置了目标域名，保留路径前缀；如果未配置目标域名，移除路径前缀
-	var targetPath string
-	if proxyConfig.TargetDomain != "" {
-		// 配置了目标域名，保留路径前缀
-		targetPath = "/" + strings.Join(pathParts, "/")
-	} else {
-		// 未配置目标域名，移除路径前缀
-		if len(pathParts) > 1 {
-			targetPath = "/" + strings.Join(pathParts[1:], "/")
-		} else {
-			targetPath = "/"
-		}
-	}
+	// 按重写规则和前缀配置计算转发路径，再拼接目标基础路径，目标地址由负载均衡器在转发时选择
+	targetPath, decision := rewritePath(proxyConfig, pathPrefix, r.URL.Path)
+	targetPath = joinURLPath(proxyConfig.TargetPath, targetPath)
+	h.logger.Debugf("Path rewrite: %s -> %s (%s, target_path=%q)", r.URL.Path, targetPath, decision, proxyConfig.TargetPath)
+
+	h.forward(w, r, table, route, targetPath, false)
+}
 
+// forward 将请求转发到路由的上游，targetPath 为转发路径（已拼接目标基础路径），
+// sticky 表示请求未匹配路由、按来源页面转发
+func (h *Handler) forward(w http.ResponseWriter, r *http.Request, table *RouteTable, route *Route, targetPath string, sticky bool) {
 	// 确定协议 scheme
 	scheme := "http"
-	if proxyConfig.UseHTTPS {
+	if route.Config.UseHTTPS {
 		scheme = "https"
 	}
 
-	// 构建完整的目标 URL
-	targetURL := fmt.Sprintf("%s://%s%s", scheme, targetDomain, targetPath)
+	t := &target{
+		scheme:     scheme,
+		path:       targetPath,
+		pathPrefix: route.PathPrefix,
+		original:   r.URL.RequestURI(),
+		vars:       &requestVars{r: r},
+		links:      table.links,
+		sticky:     sticky,
+	}
 
-	// 添加查询参数
-	if r.URL.RawQuery != "" {
-		targetURL += "?" + r.URL.RawQuery
+	// 允许重试的请求先缓存请求体，以便重新发送
+	if retry := route.upstream.retry; retry != nil && retry.AllowsMethod(r.Method) {
+		body, ok, err := bufferRequestBody(r, retry.MaxBodySize)
+		if err != nil {
+			h.logger.Errorf("Failed to read request body for %s %s: %v", r.Method, t.original, err)
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		t.body, t.retryable = body, ok
+		if !ok {
+			h.logger.Debugf("Request body of %s %s exceeds retry.max_body_size %d, retries disabled for this request", r.Method, t.original, retry.MaxBodySize)
+		}
 	}
 
-	h.logger.Infof("Proxying request: %s %s -> %s", r.Method, r.URL.String(), targetURL)
+	// 使用路由共享的反向代理执行代理请求，查询参数保持不变
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w}
+	route.upstream.proxy.ServeHTTP(recorder, withTarget(r, t))
 
-	// 解析目标 URL
-	parsedURL, err := url.Parse(targetURL)
-	if err != nil {
-		h.logger.Errorf("Failed to parse target URL: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	// 访问日志：包含每次转发尝试的上游目标和结果
+	h.logger.Infof("Proxy access: %s %s route=%s status=%d attempts=%d upstreams=[%s] duration=%s",
+		r.Method, t.original, route.Name, recorder.statusCode(), len(t.attempts), formatAttempts(t.attempts), time.Since(start).Round(time.Microsecond))
+}
+
+// Stats 获取所有代理路由的负载均衡和健康检查状态，按路由优先级排序
+func (h *Handler) Stats() []RouteStats {
+	routes := h.routes.Load().Routes()
+	stats := make([]RouteStats, 0, len(routes))
+	for _, route := range routes {
+		stats = append(stats, route.upstream.stats(route.Name))
 	}
+	return stats
+}
 
-	// 创建反向代理
-	proxy := httputil.NewSingleHostReverseProxy(parsedURL)
-
-	// 配置传输层，处理 SSL 证书验证和 Android 4 兼容性
-	if proxyConfig.UseHTTPS {
-		transportConfig := &http.Transport{
-			TLSClientConfig: &tls.Config{
-				MinVersion: tls.VersionTLS10, // 支持 TLS 1.0（Android 4 支持的最低版本）
-				MaxVersion: tls.VersionTLS13, // 支持到 TLS 1.3
-				// 使用兼容 Android 4 的加密套件
-				CipherSuites: []uint16{
-					tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-					tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-					tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
-					tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-					tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-					tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
-					// 现代加密套件（优先）
-					tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-					tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-					tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-					tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-					tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-				},
-				PreferServerCipherSuites: true,
-			},
-		}
-		
-		if proxyConfig.Insecure {
-			// 跳过 SSL 证书验证
-			transportConfig.TLSClientConfig.InsecureSkipVerify = true
-			h.logger.Debugf("SSL certificate verification disabled for: %s", targetDomain)
-		}
-		
-		proxy.Transport = transportConfig
-		h.logger.Debugf("Path prefix: %s, Target domain: %s (Android 4 compatible TLS)", pathPrefix, targetDomain)
+// formatBackends 格式化上游目标列表，用于日志
+func formatBackends(backends []*backend) string {
+	parts := make([]string, 0, len(backends))
+	for _, b := range backends {
+		parts = append(parts, fmt.Sprintf("%s(weight=%d)", b.addr, b.weight))
 	}
+	return strings.Join(parts, ", ")
+}
 
-	// 修改请求，设置正确的 Host 头
-	originalDirector := proxy.Director
-	proxy.Director = func(req *http.Request) {
-		originalDirector(req)
-		req.Host = targetDomain
-		req.URL.Host = targetDomain
-		req.URL.Scheme = scheme
-		req.URL.Path = targetPath
-
-		// 保留原始查询参数
-		if r.URL.RawQuery != "" {
-			req.URL.RawQuery = r.URL.RawQuery
-		}
+// IsProxyRequest 判断请求是否匹配代理路由
+func (h *Handler) IsProxyRequest(r *http.Request) bool {
+	_, exists := h.routes.Load().Match(r)
+	return exists
+}
 
-		h.logger.Debugf("Proxy request details: Method=%s, URL=%s, Host=%s, PathPrefix=%s, TargetDomain=%s",
-			req.Method, req.URL.String(), req.Host, pathPrefix, targetDomain)
-	}
+// statusRecorder 记录响应状态码，用于访问日志
+// 实现 Unwrap，ReverseProxy 可以通过 http.ResponseController 刷新响应和升级连接
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
 
-	// 执行代理请求
-	proxy.ServeHTTP(w, r)
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
 }
 
-// IsProxyPath 判断请求路径是否为代理路径
-// 检查路径的第一段是否匹配已配置的代理路径前缀
-func (h *Handler) IsProxyPath(path string) bool {
-	// 清理路径
-	path = filepath.Clean(path)
-	path = strings.TrimPrefix(path, "/")
-
-	// 分割路径
-	pathParts := strings.Split(path, "/")
-	if len(pathParts) == 0 || pathParts[0] == "" {
-		return false
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
 	}
+	return r.ResponseWriter.Write(b)
+}
 
-	pathPrefix := pathParts[0]
-	_, exists := h.config.GetProxyConfig(pathPrefix)
-	return exists
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// statusCode 获取响应状态码，尚未写入响应时返回 200
+func (r *statusRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
 }
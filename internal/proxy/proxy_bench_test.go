@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"serve/internal/config"
+)
+
+// benchmarkUpstream 启动 HTTPS 上游，返回指向它的代理配置
+func benchmarkUpstream(b *testing.B) (*httptest.Server, map[string]*config.ProxyConfig) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	b.Cleanup(server.Close)
+
+	return server, map[string]*config.ProxyConfig{
+		"api": {
+			TargetDomain: server.Listener.Addr().String(),
+			UseHTTPS:     true,
+			Insecure:     true,
+		},
+	}
+}
+
+// serveBenchmark 通过 handler 发送 b.N 个请求
+func serveBenchmark(b *testing.B, handler http.Handler) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/ping", nil))
+		if rec.Code != http.StatusOK {
+			b.Fatalf("unexpected status %d", rec.Code)
+		}
+	}
+}
+
+// BenchmarkProxyPerRequestTransport 每个请求新建连接池（每次都重新建立 TCP 连接和 TLS 握手）
+func BenchmarkProxyPerRequestTransport(b *testing.B) {
+	server, proxyConfigs := benchmarkUpstream(b)
+	target, _ := url.Parse(server.URL)
+
+	serveBenchmark(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		transport, _ := newTransport(&Route{Name: "api", Config: proxyConfigs["api"]}, testLogger())
+		defer transport.CloseIdleConnections()
+		proxy := &httputil.ReverseProxy{
+			Transport: transport,
+			Rewrite:   func(pr *httputil.ProxyRequest) { pr.SetURL(target) },
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+}
+
+// BenchmarkProxyPooledTransport 路由共享长期复用的连接池
+func BenchmarkProxyPooledTransport(b *testing.B) {
+	_, proxyConfigs := benchmarkUpstream(b)
+	serveBenchmark(b, NewHandler(proxyConfigs, testLogger()))
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"serve/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testLogger 返回丢弃输出的日志记录器
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestServeHTTPTargetPortAndPath(t *testing.T) {
+	var gotPath, gotQuery string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	h := NewHandler(map[string]*config.ProxyConfig{
+		"api": {TargetDomain: u.Hostname(), TargetPort: port, TargetPath: "/v2/"},
+	}, testLogger())
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/api", "/v2/api"},
+		{"/api/users", "/v2/api/users"},
+		{"/api/users/?page=2", "/v2/api/users/"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, want %d", tt.path, rec.Code, http.StatusOK)
+		}
+		if gotPath != tt.want {
+			t.Errorf("%s: upstream path %q, want %q", tt.path, gotPath, tt.want)
+		}
+	}
+	if gotQuery != "page=2" {
+		t.Errorf("upstream query %q, want %q", gotQuery, "page=2")
+	}
+}
+
+func TestUpdateReusesTransport(t *testing.T) {
+	h := NewHandler(map[string]*config.ProxyConfig{
+		"api": {TargetDomain: "10.0.0.1"},
+		"www": {TargetDomain: "10.0.0.2"},
+	}, testLogger())
+	transports := func() map[string]*http.Transport {
+		m := make(map[string]*http.Transport)
+		for _, route := range h.routes.Load().Routes() {
+			m[route.Name] = route.upstream.transport
+		}
+		return m
+	}
+	before := transports()
+
+	// 目标地址变化不影响连接池，连接设置变化时新建连接池
+	h.Update(map[string]*config.ProxyConfig{
+		"api": {TargetDomain: "10.0.0.3"},
+		"www": {TargetDomain: "10.0.0.2", Transport: &config.TransportConfig{MaxIdleConnsPerHost: 8}},
+		"new": {TargetDomain: "10.0.0.4"},
+	})
+	after := transports()
+
+	if after["api"] != before["api"] {
+		t.Errorf("api: transport replaced, want reused")
+	}
+	if after["www"] == before["www"] {
+		t.Errorf("www: transport reused after settings change, want new")
+	}
+	if got := after["www"].MaxIdleConnsPerHost; got != 8 {
+		t.Errorf("www: MaxIdleConnsPerHost = %d, want 8", got)
+	}
+	if after["new"] == nil || after["new"] == before["api"] {
+		t.Errorf("new: want a separate transport")
+	}
+}
+
+func TestServeHTTPBalancesTargets(t *testing.T) {
+	counts := make(map[string]int)
+	var mu sync.Mutex
+	newTarget := func() *httptest.Server {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			counts[r.Host]++
+			mu.Unlock()
+		}))
+		t.Cleanup(upstream.Close)
+		return upstream
+	}
+	a, b := newTarget(), newTarget()
+	addrA, addrB := a.Listener.Addr().String(), b.Listener.Addr().String()
+
+	h := NewHandler(map[string]*config.ProxyConfig{
+		"api": {Targets: []*config.UpstreamTarget{{Address: addrA, Weight: 1}, {Address: addrB, Weight: 3}}},
+	}, testLogger())
+	for i := 0; i < 8; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, rec.Code)
+		}
+	}
+
+	// 转发请求的 Host 头为选中的目标地址
+	if counts[addrA] != 2 || counts[addrB] != 6 {
+		t.Errorf("requests per target = %v, want %s:2 %s:6", counts, addrA, addrB)
+	}
+}
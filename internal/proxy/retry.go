@@ -0,0 +1,286 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"serve/internal/config"
+)
+
+// failureError 转发失败但不属于可识别的失败类型
+const failureError = "error"
+
+// unavailableError 路由没有可用的上游目标
+type unavailableError struct {
+	retryAfter time.Duration // 熔断冷却期的剩余时间，不是因为熔断而不可用时为 0
+}
+
+func (e *unavailableError) Error() string {
+	if e.retryAfter > 0 {
+		return fmt.Sprintf("upstream circuit open, retry after %s", e.retryAfter.Round(time.Second))
+	}
+	return "no healthy upstream available"
+}
+
+// attempt 单次转发尝试的结果，用于访问日志
+type attempt struct {
+	addr   string // 上游目标地址
+	result string // 响应状态码或失败类型
+}
+
+// formatAttempts 格式化转发尝试列表，如 10.0.0.1:8080 connect_error, 10.0.0.2:8080 200
+func formatAttempts(attempts []attempt) string {
+	parts := make([]string, 0, len(attempts))
+	for _, a := range attempts {
+		parts = append(parts, a.addr+" "+a.result)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// bufferRequestBody 为重试缓存请求体
+// 请求体不超过 limit 时完整读入内存并替换为可重复读取的请求体，返回缓存的内容和 true；
+// 超过 limit 时已读取的部分与剩余部分重新拼接，请求照常转发但不能重试，返回 false
+func bufferRequestBody(r *http.Request, limit int64) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(body)) > limit {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false, nil
+	}
+
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true, nil
+}
+
+// RoundTrip 实现 http.RoundTripper，由 ReverseProxy 调用
+// 每次尝试由负载均衡器选择上游目标（重试时优先选择尚未尝试过的目标），并记录统计数据和熔断器结果；
+// 满足重试条件时按指数退避等待后重新转发
+func (u *upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := req.Context().Value(targetKey{}).(*target)
+
+	// 转发头和请求头修改规则在 ReverseProxy 移除逐跳头（包括客户端在 Connection 中列出的头）和追加 X-Forwarded-For 之后执行，
+	// 设置的头不会被客户端通过 Connection 头移除
+	setForwardedHeaders(req, u.config, t.pathPrefix)
+	if host := applyHeaderRules(req.Header, u.config.RequestHeaders, t.vars); host != "" {
+		t.host = host
+	} else if u.config.PreserveHost {
+		t.host = req.Host
+	}
+
+	maxAttempts := 1
+	if u.retry != nil && t.retryable {
+		maxAttempts = u.retry.Attempts
+	}
+
+	next, breaker, trial := u.acquire(req, nil)
+	if next == nil {
+		return nil, &unavailableError{retryAfter: u.retryAfter()}
+	}
+	tried := []*backend{next}
+
+	for n := 1; ; n++ {
+		b := next
+		outreq := req
+		if n > 1 {
+			outreq = req.Clone(req.Context())
+		}
+		outreq.Host = b.addr
+		if t.host != "" {
+			outreq.Host = t.host
+		}
+		outreq.URL.Host = b.addr
+		if t.body != nil {
+			outreq.Body = io.NopCloser(bytes.NewReader(t.body))
+		}
+		u.logger.Debugf("Proxying request: %s %s -> %s (attempt %d/%d)", req.Method, t.original, outreq.URL.String(), n, maxAttempts)
+
+		b.requests.Add(1)
+		b.active.Add(1)
+		resp, err := u.transport.RoundTrip(outreq)
+
+		// 客户端取消请求不视为上游失败，也不再重试
+		if req.Context().Err() != nil {
+			b.active.Add(-1)
+			if breaker != nil {
+				breaker.release(trial)
+			}
+			if err == nil {
+				resp.Body.Close()
+				err = req.Context().Err()
+			}
+			t.attempts = append(t.attempts, attempt{addr: b.addr, result: "canceled"})
+			return nil, err
+		}
+
+		kind := ""
+		if err != nil {
+			b.active.Add(-1)
+			b.failures.Add(1)
+			kind = failureKind(err)
+			t.attempts = append(t.attempts, attempt{addr: b.addr, result: kind})
+		} else {
+			if resp.StatusCode == http.StatusSwitchingProtocols {
+				// 协议升级（如 WebSocket）的响应体需要保持 io.ReadWriteCloser，不做包装
+				b.active.Add(-1)
+			} else {
+				resp.Body = &trackedBody{ReadCloser: resp.Body, done: func() { b.active.Add(-1) }}
+			}
+			t.attempts = append(t.attempts, attempt{addr: b.addr, result: strconv.Itoa(resp.StatusCode)})
+		}
+		if breaker != nil {
+			breaker.record(err != nil || breaker.settings.IsFailureStatus(resp.StatusCode), trial)
+		}
+
+		if n >= maxAttempts || !u.shouldRetry(resp, kind) {
+			return resp, err
+		}
+
+		// 先选择下一个目标，没有可用目标时返回本次结果
+		next, breaker, trial = u.acquire(req, tried)
+		if next == nil {
+			u.logger.Debugf("No upstream available for retrying %s %s on proxy route %s", req.Method, t.original, u.route)
+			return resp, err
+		}
+		tried = append(tried, next)
+
+		reason := kind
+		if err == nil {
+			reason = "status " + strconv.Itoa(resp.StatusCode)
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		}
+		delay := u.backoff(n)
+		u.logger.Warnf("Retrying proxy request %s %s on route %s after %s from %s (attempt %d/%d, next upstream %s, backoff %s)",
+			req.Method, t.original, u.route, reason, b.addr, n+1, maxAttempts, next.addr, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			if breaker != nil {
+				breaker.release(trial)
+			}
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// acquire 选择上游目标并通过其熔断器的准入检查
+// tried 为已经尝试过的目标，负载均衡器选中已尝试的目标时再选择几次，尽量换到其他目标；
+// 选择和准入检查不是原子的，选中的目标可能在准入前被并发请求熔断或占满试探名额，此时继续选择其他目标
+func (u *upstream) acquire(r *http.Request, tried []*backend) (*backend, *circuitBreaker, bool) {
+	var denied []*backend
+	for len(denied) < len(u.backends) {
+		b := u.pickExcept(r, tried, denied)
+		if b == nil {
+			return nil, nil, false
+		}
+		breaker := b.breaker.Load()
+		if breaker == nil {
+			return b, nil, false
+		}
+		if ok, trial := breaker.allow(); ok {
+			return b, breaker, trial
+		}
+		denied = append(denied, b)
+	}
+	return nil, nil, false
+}
+
+// pickExcept 选择上游目标，选中 tried 或 denied 中的目标时再选择几次
+// 仍然选中 denied 中的目标时返回 nil，仍然选中 tried 中的目标时返回该目标（重试同一个目标）
+func (u *upstream) pickExcept(r *http.Request, tried, denied []*backend) *backend {
+	var b *backend
+	for i := 0; i <= len(tried)+len(denied); i++ {
+		b = u.pick(r)
+		if b == nil || (!containsBackend(tried, b) && !containsBackend(denied, b)) {
+			return b
+		}
+	}
+	if containsBackend(denied, b) {
+		return nil
+	}
+	return b
+}
+
+// shouldRetry 判断转发结果是否需要重试
+func (u *upstream) shouldRetry(resp *http.Response, kind string) bool {
+	if kind != "" {
+		return u.retry.RetriesOn(kind)
+	}
+	return u.retry.RetriesOnStatus(resp.StatusCode)
+}
+
+// backoff 计算第 n 次重试前的等待时间：从 backoff 开始每次翻倍，不超过 max_backoff，并加入随机抖动
+func (u *upstream) backoff(n int) time.Duration {
+	delay := u.retry.Backoff.Duration() << (n - 1)
+	if maxDelay := u.retry.MaxBackoff.Duration(); delay > maxDelay || delay <= 0 {
+		delay = maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// failureKind 识别转发错误的失败类型
+func failureKind(err error) string {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return config.RetryOnConnectError
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return config.RetryOnTimeout
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return config.RetryOnConnectionReset
+	}
+	return failureError
+}
+
+// containsBackend 判断目标是否在列表中
+func containsBackend(backends []*backend, b *backend) bool {
+	for _, item := range backends {
+		if item == b {
+			return true
+		}
+	}
+	return false
+}
+
+// trackedBody 响应体关闭时回调，用于统计进行中的请求数（包括响应体传输阶段）
+type trackedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
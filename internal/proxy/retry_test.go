@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"serve/internal/config"
+)
+
+// recordingUpstream 记录收到的请求体并返回固定状态码的上游
+type recordingUpstream struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies []string
+}
+
+func newRecordingUpstream(t *testing.T, status int) *recordingUpstream {
+	u := &recordingUpstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		u.mu.Lock()
+		u.bodies = append(u.bodies, string(body))
+		u.mu.Unlock()
+		w.WriteHeader(status)
+		io.WriteString(w, "from "+r.Host)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// received 获取收到的请求体
+func (u *recordingUpstream) received() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.bodies...)
+}
+
+// addr 获取上游地址
+func (u *recordingUpstream) addr() string {
+	return strings.TrimPrefix(u.URL, "http://")
+}
+
+// newRetryHandler 创建依次轮询 failing 和 healthy 两个上游的代理处理器
+func newRetryHandler(t *testing.T, failing, healthy *recordingUpstream, retry *config.RetryConfig) *Handler {
+	h := NewHandler(map[string]*config.ProxyConfig{
+		"api": {
+			Targets: []*config.UpstreamTarget{{Address: failing.addr(), Weight: 1}, {Address: healthy.addr(), Weight: 1}},
+			Retry:   retry,
+		},
+	}, testLogger())
+	t.Cleanup(h.Close)
+	return h
+}
+
+func TestRoundTripRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		body         string
+		retry        config.RetryConfig
+		wantStatus   int
+		wantReplayed bool // 请求体是否被重新发送给第二个上游
+		wantFailBody string
+	}{
+		{
+			name:         "body replayed on retry",
+			method:       http.MethodPut,
+			body:         "hello world",
+			retry:        config.RetryConfig{RetryOn: []string{config.RetryOn5xx}},
+			wantStatus:   http.StatusOK,
+			wantReplayed: true,
+			wantFailBody: "hello world",
+		},
+		{
+			name:         "request without body retried",
+			method:       http.MethodGet,
+			retry:        config.RetryConfig{RetryOn: []string{"503"}},
+			wantStatus:   http.StatusOK,
+			wantReplayed: true,
+		},
+		{
+			name:         "body larger than max_body_size not retried",
+			method:       http.MethodPut,
+			body:         "hello world",
+			retry:        config.RetryConfig{RetryOn: []string{config.RetryOn5xx}, MaxBodySize: 5},
+			wantStatus:   http.StatusServiceUnavailable,
+			wantFailBody: "hello world",
+		},
+		{
+			name:         "non-idempotent method not retried",
+			method:       http.MethodPost,
+			body:         "order",
+			retry:        config.RetryConfig{RetryOn: []string{config.RetryOn5xx}},
+			wantStatus:   http.StatusServiceUnavailable,
+			wantFailBody: "order",
+		},
+		{
+			name:         "non-idempotent method retried when allowed",
+			method:       http.MethodPost,
+			body:         "order",
+			retry:        config.RetryConfig{RetryOn: []string{config.RetryOn5xx}, RetryNonIdempotent: true},
+			wantStatus:   http.StatusOK,
+			wantReplayed: true,
+			wantFailBody: "order",
+		},
+		{
+			name:       "status not in retry_on not retried",
+			method:     http.MethodGet,
+			retry:      config.RetryConfig{RetryOn: []string{"502"}},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing := newRecordingUpstream(t, http.StatusServiceUnavailable)
+			healthy := newRecordingUpstream(t, http.StatusOK)
+			retry := tt.retry
+			retry.Backoff = config.Duration(time.Millisecond)
+			settings := retry.WithDefaults()
+			h := newRetryHandler(t, failing, healthy, &settings)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, "/api/x", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := failing.received(); len(got) != 1 || got[0] != tt.wantFailBody {
+				t.Errorf("failing upstream received %q, want one request with body %q", got, tt.wantFailBody)
+			}
+			got := healthy.received()
+			if !tt.wantReplayed {
+				if len(got) != 0 {
+					t.Errorf("healthy upstream received %q, want no retry", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0] != tt.body {
+				t.Errorf("healthy upstream received %q, want one request with body %q", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestRoundTripAttempts(t *testing.T) {
+	failing := newRecordingUpstream(t, http.StatusBadGateway)
+	h := NewHandler(map[string]*config.ProxyConfig{
+		"api": {
+			Targets: []*config.UpstreamTarget{{Address: failing.addr(), Weight: 1}},
+			Retry:   &config.RetryConfig{Attempts: 3, RetryOn: []string{config.RetryOn5xx}, Backoff: config.Duration(time.Millisecond), MaxBackoff: config.Duration(time.Second), MaxBodySize: 1024},
+		},
+	}, testLogger())
+	defer h.Close()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/x", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502 after the last attempt", rec.Code)
+	}
+	// 只有一个目标时重试同一个目标，总尝试次数不超过 attempts
+	if got := len(failing.received()); got != 3 {
+		t.Errorf("upstream received %d requests, want 3", got)
+	}
+}
+
+func TestBackoffCapped(t *testing.T) {
+	u := &upstream{retry: &config.RetryConfig{Backoff: config.Duration(100 * time.Millisecond), MaxBackoff: config.Duration(300 * time.Millisecond)}}
+	for n := 1; n <= 70; n++ {
+		// 100ms、200ms 之后一直为 max_backoff（位移溢出时同样如此）
+		base := 300 * time.Millisecond
+		if n <= 2 {
+			base = 100 * time.Millisecond << (n - 1)
+		}
+		for i := 0; i < 20; i++ {
+			d := u.backoff(n)
+			if d > 300*time.Millisecond {
+				t.Fatalf("backoff(%d) = %s, exceeds max_backoff 300ms", n, d)
+			}
+			if d < base/2 {
+				t.Fatalf("backoff(%d) = %s, want at least %s", n, d, base/2)
+			}
+		}
+	}
+}
+
+// sequenceBalancer 按顺序返回目标的负载均衡器，模拟选择后目标状态被并发请求改变
+type sequenceBalancer struct {
+	backends []*backend
+	next     int
+}
+
+func (s *sequenceBalancer) pick(*http.Request) *backend {
+	b := s.backends[s.next%len(s.backends)]
+	s.next++
+	return b
+}
+
+// fullHalfOpenBreaker 创建处于半开状态且试探名额已被占满的熔断器
+func fullHalfOpenBreaker(b *backend) {
+	cb, clock := newTestBreaker(1)
+	for i := 0; i < 3; i++ {
+		cb.record(true, false)
+	}
+	clock.advance(10 * time.Second)
+	cb.allow()
+	b.breaker.Store(cb)
+}
+
+func TestAcquireSkipsDeniedBackend(t *testing.T) {
+	backends := testBackends(1, 1)
+	fullHalfOpenBreaker(backends[0])
+	u := &upstream{backends: backends, balancer: &sequenceBalancer{backends: backends}}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	b, breaker, trial := u.acquire(req, nil)
+	if b != backends[1] || breaker != nil || trial {
+		t.Fatalf("acquire() = %v, %v, %t, want the second backend", b, breaker, trial)
+	}
+
+	fullHalfOpenBreaker(backends[1])
+	if b, _, _ := u.acquire(req, nil); b != nil {
+		t.Fatalf("acquire() = %s, want nil when every breaker denies", b.addr)
+	}
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"serve/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// rewriteResponseHeaders 将上游响应头中指向上游的地址映射回代理的路径前缀和客户端访问的主机，在 ModifyResponse 中调用
+//   - Location、Content-Location：指向代理目标的绝对链接改写为客户端访问的协议和主机加上对应的路径前缀，以 / 开头的链接加上当前路由的路径前缀
+//   - Refresh：改写其中 url= 之后的链接
+//   - Set-Cookie：移除与客户端访问的主机不匹配的 Domain，将 Path 映射到路径前缀之下，按 cookie_secure 处理 Secure
+func rewriteResponseHeaders(resp *http.Response, route *Route, t *target, logger *logrus.Logger) {
+	settings := route.Config.ReverseRewrite
+	r := t.vars.r
+	lr := &linkRewriter{index: t.links, route: route, host: r.Host, rootRelative: true}
+
+	if settings.RewritesLocation() {
+		base := t.vars.lookup(config.HeaderVarScheme) + "://" + r.Host
+		for _, name := range []string{"Location", "Content-Location"} {
+			value := resp.Header.Get(name)
+			if value == "" {
+				continue
+			}
+			if rewritten, ok := lr.rewriteURL(value); ok {
+				if !strings.HasPrefix(strings.TrimSpace(value), "/") || strings.HasPrefix(strings.TrimSpace(value), "//") {
+					rewritten = base + rewritten
+				}
+				resp.Header.Set(name, rewritten)
+				logger.Debugf("Rewrote %s header for route %s: %s -> %s", name, route.Name, value, rewritten)
+			}
+		}
+		if value := resp.Header.Get("Refresh"); value != "" {
+			if rewritten, ok := lr.refresh(value); ok {
+				resp.Header.Set("Refresh", rewritten)
+				logger.Debugf("Rewrote Refresh header for route %s: %s -> %s", route.Name, value, rewritten)
+			}
+		}
+	}
+
+	if settings.RewritesCookies() {
+		cookies := resp.Header.Values("Set-Cookie")
+		if len(cookies) == 0 {
+			return
+		}
+		strip := settings.GetCookieSecure() == config.CookieSecureStrip ||
+			(settings.GetCookieSecure() == config.CookieSecureAuto && r.TLS == nil)
+		rewritten := make([]string, 0, len(cookies))
+		for _, cookie := range cookies {
+			c := rewriteSetCookie(cookie, route, r.Host, strip)
+			if c != cookie {
+				logger.Debugf("Rewrote Set-Cookie header for route %s: %s -> %s", route.Name, cookie, c)
+			}
+			rewritten = append(rewritten, c)
+		}
+		resp.Header["Set-Cookie"] = rewritten
+	}
+}
+
+// rewriteSetCookie 改写单个 Set-Cookie 头的属性，其余内容保持原样
+// stripSecure 为 true 时移除 Secure，同时移除依赖 Secure 的 SameSite=None 和 Partitioned，否则浏览器会拒绝该 Cookie
+func rewriteSetCookie(cookie string, route *Route, clientHost string, stripSecure bool) string {
+	host := strings.ToLower(clientHost)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	parts := strings.Split(cookie, ";")
+	out := []string{parts[0]}
+	for _, part := range parts[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "domain":
+			// 客户端访问的主机不属于该域名时浏览器会拒绝 Cookie，移除后 Cookie 只属于客户端访问的主机
+			domain := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(value), "."))
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				continue
+			}
+		case "path":
+			path := strings.TrimSpace(value)
+			mapped, ok := reversePath(route.Config, route.PathPrefix, path)
+			if !ok {
+				// 上游路径无法通过此路由访问时限定在路径前缀之下
+				mapped = "/" + route.PathPrefix
+			}
+			if len(mapped) > 1 {
+				mapped = strings.TrimSuffix(mapped, "/")
+			}
+			if mapped != path {
+				part = " Path=" + mapped
+			}
+		case "secure", "partitioned":
+			if stripSecure {
+				continue
+			}
+		case "samesite":
+			if stripSecure && strings.EqualFold(strings.TrimSpace(value), "none") {
+				continue
+			}
+		}
+		out = append(out, part)
+	}
+	return strings.Join(out, ";")
+}
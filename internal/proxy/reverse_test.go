@@ -0,0 +1,306 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"serve/internal/config"
+)
+
+// reverseTestTable 创建响应头反向改写测试使用的路由表
+//   - app：上游 app.internal 的 /v1 映射到 /app
+//   - keep：上游 keep.internal，保留路径前缀 /keep
+func reverseTestTable(settings *config.ReverseRewriteConfig) *RouteTable {
+	strip := true
+	return NewRouteTable(map[string]*config.ProxyConfig{
+		"app":  {TargetDomain: "app.internal", UseHTTPS: true, TargetPath: "/v1", StripPrefix: &strip, ReverseRewrite: settings},
+		"keep": {TargetDomain: "keep.internal", ReverseRewrite: settings},
+	})
+}
+
+// reverseTestRoute 获取路由表中指定名称的路由
+func reverseTestRoute(t *testing.T, table *RouteTable, name string) *Route {
+	t.Helper()
+	for _, route := range table.Routes() {
+		if route.Name == name {
+			return route
+		}
+	}
+	t.Fatalf("route %s not found", name)
+	return nil
+}
+
+func TestRewriteSetCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		route  string
+		host   string
+		strip  bool
+		cookie string
+		want   string
+	}{
+		{
+			name:   "no attributes",
+			route:  "app",
+			host:   "192.168.1.2:8080",
+			cookie: "sid=1",
+			want:   "sid=1",
+		},
+		{
+			name:   "upstream domain removed",
+			route:  "app",
+			host:   "192.168.1.2:8080",
+			cookie: "sid=1; Domain=app.internal; HttpOnly",
+			want:   "sid=1; HttpOnly",
+		},
+		{
+			name:   "domain matching client host kept",
+			route:  "app",
+			host:   "www.example.com",
+			cookie: "sid=1; Domain=.example.com",
+			want:   "sid=1; Domain=.example.com",
+		},
+		{
+			name:   "domain compared case-insensitively without port",
+			route:  "app",
+			host:   "Example.COM:8443",
+			cookie: "sid=1; domain=example.com",
+			want:   "sid=1; domain=example.com",
+		},
+		{
+			name:   "domain of sibling host removed",
+			route:  "app",
+			host:   "www.example.com",
+			cookie: "sid=1; Domain=api.example.com",
+			want:   "sid=1",
+		},
+		{
+			name:   "path mapped under route prefix",
+			route:  "app",
+			host:   "192.168.1.2:8080",
+			cookie: "sid=1; Path=/v1/account; HttpOnly",
+			want:   "sid=1; Path=/app/account; HttpOnly",
+		},
+		{
+			name:   "target path root mapped to route prefix",
+			route:  "app",
+			host:   "192.168.1.2:8080",
+			cookie: "sid=1; Path=/v1/",
+			want:   "sid=1; Path=/app",
+		},
+		{
+			name:   "path outside target path limited to route prefix",
+			route:  "app",
+			host:   "192.168.1.2:8080",
+			cookie: "sid=1; Path=/",
+			want:   "sid=1; Path=/app",
+		},
+		{
+			name:   "path outside kept prefix limited to route prefix",
+			route:  "keep",
+			host:   "192.168.1.2:8080",
+			cookie: "sid=1; Path=/other",
+			want:   "sid=1; Path=/keep",
+		},
+		{
+			name:   "path inside kept prefix unchanged",
+			route:  "keep",
+			host:   "192.168.1.2:8080",
+			cookie: "sid=1; path=/keep/x",
+			want:   "sid=1; path=/keep/x",
+		},
+		{
+			name:   "value containing equals sign preserved",
+			route:  "app",
+			host:   "192.168.1.2:8080",
+			cookie: "token=a=b; Path=/v1/x",
+			want:   "token=a=b; Path=/app/x",
+		},
+		{
+			name:   "secure kept",
+			route:  "app",
+			host:   "192.168.1.2:8080",
+			cookie: "sid=1; Secure; SameSite=None; Partitioned",
+			want:   "sid=1; Secure; SameSite=None; Partitioned",
+		},
+		{
+			name:   "secure stripped with SameSite=None and Partitioned",
+			route:  "app",
+			host:   "192.168.1.2:8080",
+			strip:  true,
+			cookie: "sid=1; Secure; SameSite=None; Partitioned; HttpOnly",
+			want:   "sid=1; HttpOnly",
+		},
+		{
+			name:   "secure stripped keeps SameSite=Lax",
+			route:  "app",
+			host:   "192.168.1.2:8080",
+			strip:  true,
+			cookie: "sid=1; secure; SameSite=Lax",
+			want:   "sid=1; SameSite=Lax",
+		},
+		{
+			name:   "all attributes rewritten",
+			route:  "app",
+			host:   "192.168.1.2:8080",
+			strip:  true,
+			cookie: "sid=1; Domain=app.internal; Path=/v1/a; Secure; SameSite=none",
+			want:   "sid=1; Path=/app/a",
+		},
+	}
+
+	table := reverseTestTable(&config.ReverseRewriteConfig{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := reverseTestRoute(t, table, tt.route)
+			if got := rewriteSetCookie(tt.cookie, route, tt.host, tt.strip); got != tt.want {
+				t.Errorf("rewriteSetCookie(%q) = %q, want %q", tt.cookie, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteResponseHeaders(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name     string
+		settings config.ReverseRewriteConfig
+		https    bool // 客户端是否通过 HTTPS 访问
+		header   http.Header
+		want     http.Header
+	}{
+		{
+			name:   "absolute location over plain HTTP",
+			header: http.Header{"Location": {"https://app.internal/v1/login?next=%2F#form"}},
+			want:   http.Header{"Location": {"http://192.168.1.2:8080/app/login?next=%2F#form"}},
+		},
+		{
+			name:   "absolute location over HTTPS",
+			https:  true,
+			header: http.Header{"Location": {"https://app.internal/v1/login"}},
+			want:   http.Header{"Location": {"https://192.168.1.2:8080/app/login"}},
+		},
+		{
+			name:   "protocol-relative location",
+			header: http.Header{"Location": {"//app.internal/v1/a"}},
+			want:   http.Header{"Location": {"http://192.168.1.2:8080/app/a"}},
+		},
+		{
+			name:   "root-relative location",
+			header: http.Header{"Location": {"/v1/login"}},
+			want:   http.Header{"Location": {"/app/login"}},
+		},
+		{
+			name:   "location to another route's upstream",
+			header: http.Header{"Location": {"http://keep.internal/keep/x"}},
+			want:   http.Header{"Location": {"http://192.168.1.2:8080/keep/x"}},
+		},
+		{
+			name:   "external location unchanged",
+			header: http.Header{"Location": {"https://login.example.com/v1/sso"}},
+			want:   http.Header{"Location": {"https://login.example.com/v1/sso"}},
+		},
+		{
+			name:   "root-relative location outside target path unchanged",
+			header: http.Header{"Location": {"/other"}},
+			want:   http.Header{"Location": {"/other"}},
+		},
+		{
+			name:   "content-location",
+			header: http.Header{"Content-Location": {"https://app.internal/v1/doc.json"}},
+			want:   http.Header{"Content-Location": {"http://192.168.1.2:8080/app/doc.json"}},
+		},
+		{
+			name:   "refresh",
+			header: http.Header{"Refresh": {"5; url=https://app.internal/v1/done"}},
+			want:   http.Header{"Refresh": {"5; url=/app/done"}},
+		},
+		{
+			name:   "quoted root-relative refresh",
+			header: http.Header{"Refresh": {"0; URL='/v1/next'"}},
+			want:   http.Header{"Refresh": {"0; URL='/app/next'"}},
+		},
+		{
+			name:   "refresh without url unchanged",
+			header: http.Header{"Refresh": {"30"}},
+			want:   http.Header{"Refresh": {"30"}},
+		},
+		{
+			name:     "location disabled",
+			settings: config.ReverseRewriteConfig{Location: &disabled},
+			header:   http.Header{"Location": {"https://app.internal/v1/login"}, "Refresh": {"0; url=/v1/next"}},
+			want:     http.Header{"Location": {"https://app.internal/v1/login"}, "Refresh": {"0; url=/v1/next"}},
+		},
+		{
+			name:   "cookie_secure=auto over plain HTTP strips Secure",
+			header: http.Header{"Set-Cookie": {"sid=1; Path=/v1; Secure; SameSite=None", "theme=dark; Domain=app.internal"}},
+			want:   http.Header{"Set-Cookie": {"sid=1; Path=/app", "theme=dark"}},
+		},
+		{
+			name:   "cookie_secure=auto over HTTPS keeps Secure",
+			https:  true,
+			header: http.Header{"Set-Cookie": {"sid=1; Path=/v1; Secure; SameSite=None"}},
+			want:   http.Header{"Set-Cookie": {"sid=1; Path=/app; Secure; SameSite=None"}},
+		},
+		{
+			name:     "cookie_secure=keep over plain HTTP",
+			settings: config.ReverseRewriteConfig{CookieSecure: config.CookieSecureKeep},
+			header:   http.Header{"Set-Cookie": {"sid=1; Secure"}},
+			want:     http.Header{"Set-Cookie": {"sid=1; Secure"}},
+		},
+		{
+			name:     "cookie_secure=strip over HTTPS",
+			settings: config.ReverseRewriteConfig{CookieSecure: config.CookieSecureStrip},
+			https:    true,
+			header:   http.Header{"Set-Cookie": {"sid=1; Secure; Partitioned"}},
+			want:     http.Header{"Set-Cookie": {"sid=1"}},
+		},
+		{
+			name:   "cookie path outside route prefix",
+			header: http.Header{"Set-Cookie": {"sid=1; Path=/admin"}},
+			want:   http.Header{"Set-Cookie": {"sid=1; Path=/app"}},
+		},
+		{
+			name:     "cookies disabled",
+			settings: config.ReverseRewriteConfig{Cookies: &disabled},
+			header:   http.Header{"Set-Cookie": {"sid=1; Domain=app.internal; Path=/v1; Secure"}},
+			want:     http.Header{"Set-Cookie": {"sid=1; Domain=app.internal; Path=/v1; Secure"}},
+		},
+		{
+			name:   "other headers untouched",
+			header: http.Header{"Link": {"<https://app.internal/v1/style.css>; rel=preload"}, "X-Upstream": {"https://app.internal/v1/"}},
+			want:   http.Header{"Link": {"<https://app.internal/v1/style.css>; rel=preload"}, "X-Upstream": {"https://app.internal/v1/"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := tt.settings
+			table := reverseTestTable(&settings)
+			route := reverseTestRoute(t, table, "app")
+
+			req := httptest.NewRequest(http.MethodGet, "http://192.168.1.2:8080/app/page", nil)
+			if tt.https {
+				req.TLS = &tls.ConnectionState{}
+			}
+			resp := &http.Response{Header: tt.header.Clone(), Request: req}
+			rewriteResponseHeaders(resp, route, &target{vars: &requestVars{r: req}, links: table.links}, testLogger())
+
+			for name, want := range tt.want {
+				got := resp.Header.Values(name)
+				if len(got) != len(want) {
+					t.Errorf("%s = %q, want %q", name, got, want)
+					continue
+				}
+				for i := range want {
+					if got[i] != want[i] {
+						t.Errorf("%s = %q, want %q", name, got, want)
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"serve/internal/config"
+)
+
+// rewritePath 根据代理配置计算转发路径（不含目标基础路径）
+// 处理顺序：
+//  1. 按顺序匹配正则重写规则，第一条匹配的规则生效
+//  2. 没有规则匹配时按前缀处理：replace_prefix 替换前缀，否则根据 strip_prefix 移除或保留前缀
+//
+// 返回转发路径以及决策说明，用于 debug 日志追踪路由决策
+func rewritePath(proxyConfig *config.ProxyConfig, pathPrefix, requestPath string) (string, string) {
+	for i, rule := range proxyConfig.Rewrites {
+		if rewritten, ok := rule.Apply(requestPath); ok {
+			if !strings.HasPrefix(rewritten, "/") {
+				rewritten = "/" + rewritten
+			}
+			return rewritten, fmt.Sprintf("rewrite rule #%d %q -> %q", i, rule.Match, rule.Replace)
+		}
+	}
+
+	// 请求路径中路径前缀之后的部分（路径前缀可以包含多段，为空时匹配所有路径）
+	rest := "/" + strings.TrimPrefix(requestPath, "/")
+	if pathPrefix != "" {
+		rest = strings.TrimPrefix(rest, "/"+pathPrefix)
+	}
+	if rest == "" {
+		rest = "/"
+	}
+
+	switch {
+	case proxyConfig.ReplacePrefix != "":
+		return joinURLPath(proxyConfig.ReplacePrefix, rest), fmt.Sprintf("replace prefix /%s with %s", pathPrefix, proxyConfig.ReplacePrefix)
+	case proxyConfig.ShouldStripPrefix():
+		return rest, fmt.Sprintf("strip prefix /%s", pathPrefix)
+	default:
+		return "/" + strings.TrimPrefix(requestPath, "/"), fmt.Sprintf("keep prefix /%s", pathPrefix)
+	}
+}
+
+// joinURLPath 拼接基础路径和请求路径，保证两者之间只有一个 "/"
+// 请求路径为 "/" 时保留基础路径末尾的 "/"（如 /v2/ + / = /v2/），
+// 其余情况下请求路径末尾的 "/" 原样保留（如 /v2 + /a/ = /v2/a/）
+func joinURLPath(base, path string) string {
+	if base == "" || base == "/" {
+		return path
+	}
+	if !strings.HasPrefix(base, "/") {
+		base = "/" + base
+	}
+	if path == "" || path == "/" {
+		if strings.HasSuffix(base, "/") || path == "" {
+			return base
+		}
+		return base + "/"
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// reversePath 根据代理配置将上游路径还原为经过代理的请求路径，是 rewritePath 前缀处理的逆过程
+// 上游路径不在目标基础路径或替换前缀之下、或保留前缀时不以路径前缀开头，说明该路径无法通过此路由访问，返回 false
+// 正则重写规则无法还原，按前缀处理的规则还原
+func reversePath(proxyConfig *config.ProxyConfig, pathPrefix, upstreamPath string) (string, bool) {
+	p := "/" + strings.TrimPrefix(upstreamPath, "/")
+	p, ok := trimPathPrefix(p, proxyConfig.TargetPath)
+	if !ok {
+		return "", false
+	}
+
+	prefix := ""
+	if pathPrefix != "" {
+		prefix = "/" + pathPrefix
+	}
+	switch {
+	case proxyConfig.ReplacePrefix != "":
+		rest, ok := trimPathPrefix(p, proxyConfig.ReplacePrefix)
+		if !ok {
+			return "", false
+		}
+		return joinURLPath(prefix, rest), true
+	case proxyConfig.ShouldStripPrefix():
+		return joinURLPath(prefix, p), true
+	default:
+		if _, ok := trimPathPrefix(p, prefix); !ok {
+			return "", false
+		}
+		return p, true
+	}
+}
+
+// trimPathPrefix 按路径段边界移除路径前缀（/v2 可以从 /v2、/v2/x 中移除，不能从 /v2x 中移除），返回剩余路径
+func trimPathPrefix(p, prefix string) (string, bool) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return p, true
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	switch {
+	case p == prefix:
+		return "/", true
+	case strings.HasPrefix(p, prefix+"/"):
+		return p[len(prefix):], true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"serve/internal/config"
+)
+
+func TestRewritePath(t *testing.T) {
+	strip, keep := true, false
+	tests := []struct {
+		name     string
+		config   *config.ProxyConfig
+		path     string
+		want     string
+		decision string
+	}{
+		{"default keeps prefix with domain", &config.ProxyConfig{TargetDomain: "api.local"}, "/api/users", "/api/users", "keep prefix /api"},
+		{"default strips prefix without domain", &config.ProxyConfig{}, "/api/users", "/users", "strip prefix /api"},
+		{"strip prefix root", &config.ProxyConfig{StripPrefix: &strip}, "/api", "/", "strip prefix /api"},
+		{"explicit keep", &config.ProxyConfig{StripPrefix: &keep}, "/api/users/", "/api/users/", "keep prefix /api"},
+		{"explicit strip", &config.ProxyConfig{TargetDomain: "api.local", StripPrefix: &strip}, "/api/users/", "/users/", "strip prefix /api"},
+		{"replace prefix", &config.ProxyConfig{ReplacePrefix: "/backend/", StripPrefix: &keep}, "/api/users", "/backend/users", "replace prefix /api with /backend/"},
+		{"replace prefix root", &config.ProxyConfig{ReplacePrefix: "/backend"}, "/api", "/backend/", "replace prefix /api with /backend"},
+		{
+			"first matching rule wins",
+			&config.ProxyConfig{ReplacePrefix: "/backend", Rewrites: []*config.RewriteRule{
+				{Match: "^/api/v1/(.*)", Replace: "/v1/$1"},
+				{Match: "^/api/(.*)", Replace: "/all/$1"},
+			}},
+			"/api/v1/users", "/v1/users", `rewrite rule #0 "^/api/v1/(.*)" -> "/v1/$1"`,
+		},
+		{
+			"later rule matches",
+			&config.ProxyConfig{Rewrites: []*config.RewriteRule{
+				{Match: "^/api/v1/(.*)", Replace: "/v1/$1"},
+				{Match: "^/api/(?P<rest>.*)", Replace: "${rest}"},
+			}},
+			"/api/v2/users", "/v2/users", `rewrite rule #1`,
+		},
+		{
+			"no rule matches falls back to prefix",
+			&config.ProxyConfig{ReplacePrefix: "/backend", Rewrites: []*config.RewriteRule{{Match: "^/api/v1/", Replace: "/v1/"}}},
+			"/api/v2/users", "/backend/v2/users", "replace prefix /api with /backend",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, decision := rewritePath(tt.config, "api", tt.path)
+			if got != tt.want {
+				t.Errorf("rewritePath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+			if !strings.HasPrefix(decision, tt.decision) {
+				t.Errorf("decision = %q, want prefix %q", decision, tt.decision)
+			}
+		})
+	}
+}
+
+func TestRewritePathPrefixes(t *testing.T) {
+	strip := true
+	tests := []struct {
+		pathPrefix, path, want string
+	}{
+		{"api/v2", "/api/v2/users", "/users"},
+		{"api/v2", "/api/v2", "/"},
+		{"", "/users", "/users"},
+		{"", "/", "/"},
+	}
+
+	for _, tt := range tests {
+		got, _ := rewritePath(&config.ProxyConfig{StripPrefix: &strip}, tt.pathPrefix, tt.path)
+		if got != tt.want {
+			t.Errorf("rewritePath(%q, %q) = %q, want %q", tt.pathPrefix, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestJoinURLPath(t *testing.T) {
+	tests := []struct {
+		base, path, want string
+	}{
+		{"", "/api/users", "/api/users"},
+		{"/", "/api/users", "/api/users"},
+		{"/v2", "/users", "/v2/users"},
+		{"/v2/", "/users", "/v2/users"},
+		{"v2", "/users", "/v2/users"},
+		{"/v2", "users", "/v2/users"},
+		{"/v2", "/users/", "/v2/users/"},
+		{"/v2", "/", "/v2/"},
+		{"/v2/", "/", "/v2/"},
+		{"/v2", "", "/v2"},
+	}
+
+	for _, tt := range tests {
+		if got := joinURLPath(tt.base, tt.path); got != tt.want {
+			t.Errorf("joinURLPath(%q, %q) = %q, want %q", tt.base, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"serve/internal/config"
+	"serve/internal/hostmatch"
+)
+
+// Route 代理路由
+type Route struct {
+	Name       string              // 路由名称（代理配置映射的 key）
+	PathPrefix string              // 路径前缀（不含首尾 "/"，匹配所有路径时为空字符串）
+	Config     *config.ProxyConfig // 代理配置
+
+	hosts    []string        // 小写的主机匹配规则（格式见 hostmatch 包）
+	methods  map[string]bool // 允许的请求方法，为空时允许所有方法
+	upstream *upstream       // 上游转发器，由 Handler.Update 创建
+}
+
+// RouteTable 代理路由表，路由按优先级排序，匹配时返回第一个满足条件的路由
+type RouteTable struct {
+	routes []*Route
+	links  *linkIndex // 目标地址到路由的索引，用于响应体链接改写
+}
+
+// NewRouteTable 根据代理配置构建路由表
+// 路由按以下顺序排序，保证匹配结果确定：
+//  1. priority 降序
+//  2. 主机限制的精确程度（host:port > host > *.domain > 不限制）
+//  3. 路径前缀段数降序，段数相同时按长度降序（最长前缀优先）
+//  4. 有方法限制的路由优先
+//  5. 路由名称升序
+func NewRouteTable(proxyConfigs map[string]*config.ProxyConfig) *RouteTable {
+	table := &RouteTable{}
+	for name, proxyConfig := range proxyConfigs {
+		if proxyConfig == nil {
+			continue
+		}
+		route := &Route{
+			Name:       name,
+			PathPrefix: proxyConfig.RoutePrefix(name),
+			Config:     proxyConfig,
+		}
+		for _, host := range proxyConfig.Hosts {
+			route.hosts = append(route.hosts, strings.ToLower(strings.TrimSpace(host)))
+		}
+		if len(proxyConfig.Methods) > 0 {
+			route.methods = make(map[string]bool, len(proxyConfig.Methods))
+			for _, method := range proxyConfig.Methods {
+				route.methods[strings.ToUpper(method)] = true
+			}
+		}
+		table.routes = append(table.routes, route)
+	}
+
+	sort.SliceStable(table.routes, func(i, j int) bool {
+		a, b := table.routes[i], table.routes[j]
+		if a.Config.Priority != b.Config.Priority {
+			return a.Config.Priority > b.Config.Priority
+		}
+		if ha, hb := a.hostSpecificity(), b.hostSpecificity(); ha != hb {
+			return ha > hb
+		}
+		if sa, sb := a.segments(), b.segments(); sa != sb {
+			return sa > sb
+		}
+		if len(a.PathPrefix) != len(b.PathPrefix) {
+			return len(a.PathPrefix) > len(b.PathPrefix)
+		}
+		if (a.methods != nil) != (b.methods != nil) {
+			return a.methods != nil
+		}
+		return a.Name < b.Name
+	})
+	table.links = newLinkIndex(table.routes)
+
+	return table
+}
+
+// Routes 获取按优先级排序的路由列表
+func (t *RouteTable) Routes() []*Route {
+	return t.routes
+}
+
+// Match 查找与请求匹配的路由
+func (t *RouteTable) Match(r *http.Request) (*Route, bool) {
+	requestPath := cleanRequestPath(r.URL.Path)
+	for _, route := range t.routes {
+		if route.matchPath(requestPath) && route.matchHost(r.Host) && route.matchMethod(r.Method) {
+			return route, true
+		}
+	}
+	return nil, false
+}
+
+// matchPath 判断请求路径是否匹配路径前缀，按路径段边界匹配（/api 匹配 /api、/api/x，不匹配 /apix）
+func (route *Route) matchPath(requestPath string) bool {
+	if route.PathPrefix == "" {
+		return true
+	}
+	prefix := "/" + route.PathPrefix
+	return requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+}
+
+// matchHost 判断请求的 Host 头是否匹配
+func (route *Route) matchHost(requestHost string) bool {
+	if len(route.hosts) == 0 {
+		return true
+	}
+	for _, host := range route.hosts {
+		if hostmatch.Match(host, requestHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMethod 判断请求方法是否匹配
+func (route *Route) matchMethod(method string) bool {
+	return route.methods == nil || route.methods[method]
+}
+
+// hostSpecificity 获取路由主机限制中最精确的程度
+func (route *Route) hostSpecificity() int {
+	specificity := hostmatch.Any
+	for _, host := range route.hosts {
+		if s := hostmatch.Specificity(host); s > specificity {
+			specificity = s
+		}
+	}
+	return specificity
+}
+
+// segments 获取路径前缀的段数
+func (route *Route) segments() int {
+	if route.PathPrefix == "" {
+		return 0
+	}
+	return strings.Count(route.PathPrefix, "/") + 1
+}
+
+// cleanRequestPath 清理请求路径，用于路由匹配
+func cleanRequestPath(requestPath string) string {
+	if !strings.HasPrefix(requestPath, "/") {
+		requestPath = "/" + requestPath
+	}
+	return path.Clean(requestPath)
+}
@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"serve/internal/config"
+)
+
+func TestRouteTableOrder(t *testing.T) {
+	// 每组中 first 只在被测规则上占优，在其余规则（包括路由名称）上都处于劣势
+	tests := []struct {
+		name          string
+		first, second *config.ProxyConfig
+	}{
+		{
+			"priority before host specificity",
+			&config.ProxyConfig{PathPrefix: "/", Priority: 1},
+			&config.ProxyConfig{PathPrefix: "/api/v1/users", Hosts: []string{"a.local:8080"}, Methods: []string{"GET"}},
+		},
+		{
+			"negative priority last",
+			&config.ProxyConfig{PathPrefix: "/"},
+			&config.ProxyConfig{PathPrefix: "/api/v1", Hosts: []string{"a.local:8080"}, Priority: -1},
+		},
+		{
+			"host:port before host",
+			&config.ProxyConfig{PathPrefix: "/", Hosts: []string{"a.local:8080"}},
+			&config.ProxyConfig{PathPrefix: "/api/v1", Hosts: []string{"a.local"}, Methods: []string{"GET"}},
+		},
+		{
+			"host before wildcard",
+			&config.ProxyConfig{PathPrefix: "/", Hosts: []string{"a.local"}},
+			&config.ProxyConfig{PathPrefix: "/api/v1", Hosts: []string{"*.local"}, Methods: []string{"GET"}},
+		},
+		{
+			"wildcard before any host",
+			&config.ProxyConfig{PathPrefix: "/", Hosts: []string{"*.local"}},
+			&config.ProxyConfig{PathPrefix: "/api/v1", Methods: []string{"GET"}},
+		},
+		{
+			"most specific host of a route counts",
+			&config.ProxyConfig{PathPrefix: "/", Hosts: []string{"*.local", "a.local"}},
+			&config.ProxyConfig{PathPrefix: "/api/v1", Hosts: []string{"*.local"}},
+		},
+		{
+			"more segments before longer prefix",
+			&config.ProxyConfig{PathPrefix: "/a/b"},
+			&config.ProxyConfig{PathPrefix: "/abcdefgh", Methods: []string{"GET"}},
+		},
+		{
+			"longer prefix before method restriction",
+			&config.ProxyConfig{PathPrefix: "/apis"},
+			&config.ProxyConfig{PathPrefix: "/api", Methods: []string{"GET"}},
+		},
+		{
+			"method restriction before name",
+			&config.ProxyConfig{PathPrefix: "/api", Methods: []string{"GET"}},
+			&config.ProxyConfig{PathPrefix: "/api"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 路由名称排序上 second 占优
+			table := NewRouteTable(map[string]*config.ProxyConfig{
+				"z": tt.first,
+				"a": tt.second,
+			})
+			routes := table.Routes()
+			if len(routes) != 2 || routes[0].Name != "z" {
+				t.Fatalf("order = %v, want z before a", routeNames(routes))
+			}
+		})
+	}
+
+	t.Run("name breaks ties", func(t *testing.T) {
+		table := NewRouteTable(map[string]*config.ProxyConfig{
+			"b": {PathPrefix: "/api"},
+			"a": {PathPrefix: "/api"},
+			"c": nil,
+		})
+		if got := routeNames(table.Routes()); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+			t.Fatalf("order = %v, want [a b]", got)
+		}
+	})
+}
+
+func TestRouteTableMatch(t *testing.T) {
+	table := NewRouteTable(map[string]*config.ProxyConfig{
+		"/":       {TargetDomain: "root.local"},
+		"api":     {TargetDomain: "api.local"},
+		"api/v2":  {TargetDomain: "v2.local"},
+		"api-get": {PathPrefix: "/api", Methods: []string{"GET"}, TargetDomain: "get.local"},
+		"exact":   {PathPrefix: "/api", Hosts: []string{"a.local:8080"}, TargetDomain: "exact.local"},
+		"name":    {PathPrefix: "/api", Hosts: []string{"a.local", "[::1]"}, TargetDomain: "name.local"},
+		"wild":    {PathPrefix: "/api", Hosts: []string{"*.b.local"}, TargetDomain: "wild.local"},
+		"high":    {PathPrefix: "/admin", Priority: 10, Methods: []string{"DELETE"}, TargetDomain: "high.local"},
+	})
+
+	tests := []struct {
+		method, host, path string
+		want               string
+	}{
+		// 路径按段边界匹配，多段前缀优先
+		{"POST", "x.local", "/api", "api"},
+		{"POST", "x.local", "/api/users", "api"},
+		{"POST", "x.local", "/apix", "/"},
+		{"POST", "x.local", "/api/v2/users", "api/v2"},
+		{"POST", "x.local", "/api/v20", "api"},
+		// 匹配前清理路径
+		{"POST", "x.local", "//api//v2/users", "api/v2"},
+		{"POST", "x.local", "/static/../api/v2", "api/v2"},
+		{"POST", "x.local", "/api/./v2/", "api/v2"},
+		{"POST", "x.local", "/api/v2/..", "api"},
+		{"POST", "x.local", "/api/../../etc", "/"},
+		{"POST", "x.local", "api/users", "api"},
+		// 主机匹配
+		{"POST", "a.local:8080", "/api/users", "exact"},
+		{"POST", "A.LOCAL:8080", "/api/users", "exact"},
+		{"POST", "a.local:9090", "/api/users", "name"},
+		{"POST", "a.local", "/api/users", "name"},
+		{"POST", "[::1]:8080", "/api/users", "name"},
+		{"POST", "x.b.local:8080", "/api/users", "wild"},
+		{"POST", "b.local", "/api/users", "api"},
+		// 方法匹配
+		{"GET", "x.local", "/api/users", "api-get"},
+		{"GET", "a.local:8080", "/api/users", "exact"},
+		{"DELETE", "x.local", "/admin/users", "high"},
+		{"GET", "x.local", "/admin/users", "/"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/", nil)
+		r.Host = tt.host
+		r.URL.Path = tt.path
+		route, ok := table.Match(r)
+		if !ok {
+			t.Errorf("%s %s%s: no route, want %s", tt.method, tt.host, tt.path, tt.want)
+			continue
+		}
+		if route.Name != tt.want {
+			t.Errorf("%s %s%s: matched %s, want %s", tt.method, tt.host, tt.path, route.Name, tt.want)
+		}
+	}
+
+	// 没有匹配所有路径的路由时，未匹配的请求不代理
+	table = NewRouteTable(map[string]*config.ProxyConfig{"api": {TargetDomain: "api.local"}})
+	if route, ok := table.Match(httptest.NewRequest("GET", "/apix/users", nil)); ok {
+		t.Errorf("/apix/users matched %s, want no route", route.Name)
+	}
+}
+
+// routeNames 获取路由名称列表
+func routeNames(routes []*Route) []string {
+	names := make([]string, len(routes))
+	for i, route := range routes {
+		names[i] = route.Name
+	}
+	return names
+}
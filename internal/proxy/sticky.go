@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// StickyCookie 记录最近一次代理的页面所属路由的 Cookie 名称
+const StickyCookie = "serve_origin"
+
+// HasStickyRoutes 判断是否有开启 sticky_origin 的路由
+func (h *Handler) HasStickyRoutes() bool {
+	for _, route := range h.routes.Load().Routes() {
+		if route.Config.StickyOrigin {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeSticky 处理未匹配任何路由的请求：按 Referer 或 Cookie 判断来源页面所属的路由，
+// 该路由开启了 sticky_origin 时以原路径转发并返回 true，否则不处理并返回 false
+// 页面脚本在运行时构造的以 / 开头的请求（如 fetch('/api/x')）缺少路径前缀，通过来源页面找回其上游
+func (h *Handler) ServeSticky(w http.ResponseWriter, r *http.Request) bool {
+	table := h.routes.Load()
+	route, reason := stickyRoute(table, r)
+	if route == nil {
+		h.logger.Debugf("Sticky origin: no origin route for %s %s%s (%s)", r.Method, r.Host, r.URL.Path, reason)
+		return false
+	}
+
+	h.logger.Debugf("Sticky origin: %s %s%s -> route %s (%s)", r.Method, r.Host, r.URL.Path, route.Name, reason)
+	targetPath := "/" + strings.TrimPrefix(r.URL.Path, "/")
+	h.forward(w, r, table, route, targetPath, true)
+	return true
+}
+
+// stickyRoute 查找请求来源页面所属的 sticky_origin 路由，返回路由和判断依据（用于日志）
+// 优先使用同源的 Referer 路径匹配路由，Referer 不属于任何路由时使用 Cookie 中记录的路由
+func stickyRoute(table *RouteTable, r *http.Request) (*Route, string) {
+	var reasons []string
+	if referer := r.Referer(); referer != "" {
+		u, err := url.Parse(referer)
+		switch {
+		case err != nil || u.Host == "":
+			reasons = append(reasons, "invalid Referer")
+		case !strings.EqualFold(u.Host, r.Host):
+			reasons = append(reasons, "cross-origin Referer "+u.Host)
+		default:
+			probe := &http.Request{Method: r.Method, Host: r.Host, URL: &url.URL{Path: u.Path}}
+			if route, ok := table.Match(probe); !ok {
+				reasons = append(reasons, "Referer "+u.Path+" matches no route")
+			} else if !route.Config.StickyOrigin {
+				return nil, "Referer " + u.Path + " belongs to route " + route.Name + " without sticky_origin"
+			} else {
+				return route, "via Referer " + u.Path
+			}
+		}
+	} else {
+		reasons = append(reasons, "no Referer")
+	}
+
+	cookie, err := r.Cookie(StickyCookie)
+	if err != nil {
+		return nil, strings.Join(append(reasons, "no "+StickyCookie+" cookie"), ", ")
+	}
+	name, err := url.QueryUnescape(cookie.Value)
+	if err != nil {
+		name = cookie.Value
+	}
+	for _, route := range table.Routes() {
+		if route.Name != name {
+			continue
+		}
+		if !route.Config.StickyOrigin || !route.matchHost(r.Host) || !route.matchMethod(r.Method) {
+			return nil, strings.Join(append(reasons, StickyCookie+" cookie route "+name+" is not sticky or does not match the request"), ", ")
+		}
+		return route, "via " + StickyCookie + " cookie"
+	}
+	return nil, strings.Join(append(reasons, StickyCookie+" cookie names unknown route "+name), ", ")
+}
+
+// setStickyCookie 代理 HTML 页面时设置 Cookie 记录页面所属的路由，
+// 页面中缺少 Referer 的请求（如 Referrer-Policy 为 no-referrer）仍然可以找回该路由
+func setStickyCookie(resp *http.Response, route *Route) {
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+		return
+	}
+	// 只在加载页面（包括 iframe）时记录，忽略 fetch 等请求返回的 HTML
+	if dest := resp.Request.Header.Get("Sec-Fetch-Dest"); dest != "" && dest != "document" && dest != "iframe" {
+		return
+	}
+	cookie := &http.Cookie{
+		Name:     StickyCookie,
+		Value:    url.QueryEscape(route.Name),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	resp.Header.Add("Set-Cookie", cookie.String())
+}
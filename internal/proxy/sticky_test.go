@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"serve/internal/config"
+)
+
+func TestStickyRoute(t *testing.T) {
+	table := NewRouteTable(map[string]*config.ProxyConfig{
+		"www":   {PathPrefix: "/www.example.com", StickyOrigin: true},
+		"app":   {PathPrefix: "/app", StickyOrigin: true},
+		"admin": {PathPrefix: "/admin", StickyOrigin: true, Hosts: []string{"admin.local"}},
+		"plain": {PathPrefix: "/plain"},
+	})
+
+	tests := []struct {
+		name    string
+		referer string
+		cookie  string
+		want    string // 期望的路由名称，为空表示不转发
+		reason  string
+	}{
+		{"Referer", "http://serve.local/www.example.com/index.html", "", "www", "via Referer /www.example.com/index.html"},
+		{"Referer before cookie", "http://serve.local/www.example.com/", "app", "www", "via Referer"},
+		{"Referer of non-sticky route wins over cookie", "http://serve.local/plain/page", "app", "", "belongs to route plain without sticky_origin"},
+		{"unmatched Referer falls back to cookie", "http://serve.local/index.html", "app", "app", "via serve_origin cookie"},
+		{"cross-origin Referer falls back to cookie", "http://other.local/www.example.com/", "app", "app", "via serve_origin cookie"},
+		{"cross-origin Referer without cookie", "http://other.local/www.example.com/", "", "", "cross-origin Referer other.local"},
+		{"invalid Referer", "::", "", "", "invalid Referer"},
+		{"cookie only", "", "www", "www", "via serve_origin cookie"},
+		{"cookie names non-sticky route", "", "plain", "", "is not sticky or does not match"},
+		{"cookie route host mismatch", "", "admin", "", "is not sticky or does not match"},
+		{"cookie names unknown route", "", "gone", "", "names unknown route gone"},
+		{"nothing", "", "", "", "no Referer, no serve_origin cookie"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/x", nil)
+			r.Host = "serve.local"
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: StickyCookie, Value: tt.cookie})
+			}
+
+			route, reason := stickyRoute(table, r)
+			got := ""
+			if route != nil {
+				got = route.Name
+			}
+			if got != tt.want {
+				t.Errorf("route = %q, want %q (reason %q)", got, tt.want, reason)
+			}
+			if !strings.Contains(reason, tt.reason) {
+				t.Errorf("reason = %q, want containing %q", reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestServeSticky(t *testing.T) {
+	var gotPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}))
+	defer upstream.Close()
+
+	strip := true
+	h := NewHandler(map[string]*config.ProxyConfig{
+		"app": {TargetDomain: upstream.Listener.Addr().String(), StripPrefix: &strip, StickyOrigin: true},
+	}, testLogger())
+	defer h.Close()
+	if !h.HasStickyRoutes() {
+		t.Fatal("HasStickyRoutes() = false, want true")
+	}
+
+	// 代理 HTML 页面时记录页面所属的路由
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/app/index.html", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != StickyCookie || cookies[0].Value != "app" || cookies[0].Path != "/" {
+		t.Fatalf("cookies = %v, want %s=app with Path=/", cookies, StickyCookie)
+	}
+
+	// 脚本发出的请求不覆盖 Cookie
+	req := httptest.NewRequest(http.MethodGet, "/app/fragment.html", nil)
+	req.Header.Set("Sec-Fetch-Dest", "empty")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("fetch response set cookies %v, want none", cookies)
+	}
+
+	// 未匹配路由的请求按 Cookie 以原路径转发
+	req = httptest.NewRequest(http.MethodGet, "/api/x", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	if !h.ServeSticky(rec, req) {
+		t.Fatal("ServeSticky() = false, want true")
+	}
+	if gotPath != "/api/x" {
+		t.Errorf("upstream path = %q, want %q", gotPath, "/api/x")
+	}
+
+	rec = httptest.NewRecorder()
+	if h.ServeSticky(rec, httptest.NewRequest(http.MethodGet, "/api/x", nil)) {
+		t.Error("ServeSticky() without Referer or cookie = true, want false")
+	}
+}
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"serve/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tlsKey 上游 TLS 设置的标识，设置变化或 CA、客户端证书文件更新后热加载会重建连接池
+// 只直接使用非敏感字段，PKCS#12 密码与 CA 文件内容、客户端证书文件的修改时间一起以摘要的形式参与比较
+func tlsKey(pc *config.ProxyConfig) string {
+	t := pc.TLS
+	if t == nil {
+		return ""
+	}
+
+	digest := sha256.New()
+	digest.Write([]byte(t.PKCS12Password))
+	digest.Write([]byte{0})
+	if t.CAFile != "" {
+		if data, err := os.ReadFile(t.CAFile); err == nil {
+			digest.Write(data)
+		}
+	}
+	digest.Write([]byte{0})
+	digest.Write([]byte(fileStamp(t.ClientCertFiles())))
+
+	return fmt.Sprintf("ca_file=%q server_name=%q pins=%q cert_file=%q key_file=%q pkcs12_file=%q profile=%+v digest=%x",
+		t.CAFile, t.ServerName, t.Pins, t.CertFile, t.KeyFile, t.PKCS12File, t.TLSProfileConfig, digest.Sum(nil))
+}
+
+// configureUpstreamTLS 按路由的 tls 配置设置私有 CA、SNI、证书固定和客户端证书
+// 配置无法加载时返回错误，此时连接全部验证失败，不会退化为不验证
+func configureUpstreamTLS(cfg *tls.Config, route string, settings *config.UpstreamTLSConfig, logger *logrus.Logger) error {
+	if settings == nil {
+		return nil
+	}
+	cfg.ServerName = settings.ServerName
+
+	// 客户端证书加载失败不影响其余设置，握手时会再次尝试加载
+	var certErr error
+	if settings.HasClientCert() {
+		cc := &clientCert{route: route, settings: settings, logger: logger}
+		certErr = cc.reload()
+		cfg.GetClientCertificate = cc.get
+	}
+
+	roots, err := settings.LoadCA()
+	if err != nil {
+		// 空的根证书池使证书验证失败，insecure 时跳过证书验证，需要在连接建立后同样拒绝
+		cfg.RootCAs = x509.NewCertPool()
+		cfg.VerifyConnection = func(tls.ConnectionState) error { return err }
+		return err
+	}
+	cfg.RootCAs = roots
+
+	pins, err := settings.PinHashes()
+	if err != nil {
+		cfg.VerifyConnection = func(tls.ConnectionState) error { return err }
+		return err
+	}
+	if len(pins) > 0 {
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(cs, pins)
+		}
+	}
+	return certErr
+}
+
+// clientCert 向上游出示的客户端证书（mTLS）
+// 每次 TLS 握手时检查证书文件的修改时间和大小，文件被替换（证书轮换）后自动重新加载，无需重启或热加载配置
+type clientCert struct {
+	route    string
+	settings *config.UpstreamTLSConfig
+	logger   *logrus.Logger
+
+	mu    sync.Mutex
+	cert  *tls.Certificate // 最近一次成功加载的证书
+	stamp string           // 最近一次加载时证书文件的修改时间和大小
+}
+
+// get 作为 tls.Config.GetClientCertificate 使用，返回当前的客户端证书
+// 重新加载失败时（如证书和私钥只替换了一个）继续使用之前的证书，文件再次变化时重试
+func (c *clientCert) get(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stamp := fileStamp(c.settings.ClientCertFiles()); stamp != c.stamp {
+		if err := c.load(stamp); err != nil && c.cert != nil {
+			c.logger.Errorf("Failed to reload upstream client certificate for proxy route %s, keeping the previous certificate: %v", c.route, err)
+		}
+	}
+	if c.cert == nil {
+		return nil, fmt.Errorf("no client certificate available for proxy route %s (%s)", c.route, c.settings.ClientCertPath())
+	}
+	return c.cert, nil
+}
+
+// reload 立即加载客户端证书
+func (c *clientCert) reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.load(fileStamp(c.settings.ClientCertFiles()))
+}
+
+// load 加载客户端证书，调用方需持有锁；失败时也记录 stamp，避免每次握手重复加载同一份有问题的文件
+func (c *clientCert) load(stamp string) error {
+	c.stamp = stamp
+	cert, err := c.settings.LoadClientCert()
+	if err != nil {
+		return err
+	}
+	reloaded := c.cert != nil
+	c.cert = cert
+
+	if cert.Leaf != nil {
+		if time.Now().After(cert.Leaf.NotAfter) {
+			c.logger.Warnf("Upstream client certificate for proxy route %s expired at %s: %s",
+				c.route, cert.Leaf.NotAfter.Format(time.RFC3339), c.settings.ClientCertPath())
+		}
+		if reloaded {
+			c.logger.Infof("Reloaded upstream client certificate for proxy route %s: %s (subject=%s, expires=%s)",
+				c.route, c.settings.ClientCertPath(), cert.Leaf.Subject, cert.Leaf.NotAfter.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// fileStamp 获取文件的修改时间和大小，文件不存在时记录错误信息
+func fileStamp(paths []string) string {
+	var parts []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			parts = append(parts, path+":"+err.Error())
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", path, info.ModTime().UnixNano(), info.Size()))
+	}
+	return strings.Join(parts, " ")
+}
+
+// verifyPins 检查证书链中是否有证书的公钥匹配固定值
+// 检查上游发送的证书和验证得到的证书链（包括上游未发送的根证书），insecure 时只有上游发送的证书
+func verifyPins(cs tls.ConnectionState, pins [][sha256.Size]byte) error {
+	certs := cs.PeerCertificates
+	for _, chain := range cs.VerifiedChains {
+		certs = append(certs[:len(certs):len(certs)], chain...)
+	}
+	for _, cert := range certs {
+		hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(hash[:], pin[:]) {
+				return nil
+			}
+		}
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("certificate pin mismatch for %s: no certificate presented", cs.ServerName)
+	}
+	return fmt.Errorf("certificate pin mismatch for %s: server certificate has %s", cs.ServerName, config.SPKIPin(cs.PeerCertificates[0]))
+}
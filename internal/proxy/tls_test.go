@@ -0,0 +1,290 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"serve/internal/config"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// writeCAFile 将 TLS 测试服务器的自签名证书写入 PEM 文件，作为私有 CA 使用
+func writeCAFile(t *testing.T, upstream *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUpstreamTLS(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	caFile := writeCAFile(t, upstream)
+	pin := config.SPKIPin(upstream.Certificate())
+	wrongPin := config.PinPrefix + "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+	tests := []struct {
+		name     string
+		insecure bool
+		tls      *config.UpstreamTLSConfig
+		want     int
+	}{
+		{name: "system roots reject private CA", want: http.StatusBadGateway},
+		{name: "custom CA", tls: &config.UpstreamTLSConfig{CAFile: caFile}, want: http.StatusOK},
+		{name: "custom CA with server name", tls: &config.UpstreamTLSConfig{CAFile: caFile, ServerName: "example.com"}, want: http.StatusOK},
+		{name: "server name not in certificate", tls: &config.UpstreamTLSConfig{CAFile: caFile, ServerName: "other.local"}, want: http.StatusBadGateway},
+		{name: "matching pin", tls: &config.UpstreamTLSConfig{CAFile: caFile, Pins: []string{wrongPin, pin}}, want: http.StatusOK},
+		{name: "mismatched pin", tls: &config.UpstreamTLSConfig{CAFile: caFile, Pins: []string{wrongPin}}, want: http.StatusBadGateway},
+		{name: "insecure with matching pin", insecure: true, tls: &config.UpstreamTLSConfig{Pins: []string{pin}}, want: http.StatusOK},
+		{name: "insecure with mismatched pin", insecure: true, tls: &config.UpstreamTLSConfig{Pins: []string{wrongPin}}, want: http.StatusBadGateway},
+		{name: "unreadable CA fails closed", insecure: true, tls: &config.UpstreamTLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}, want: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(map[string]*config.ProxyConfig{
+				"api": {TargetDomain: upstream.Listener.Addr().String(), UseHTTPS: true, Insecure: tt.insecure, TLS: tt.tls},
+			}, testLogger())
+			defer h.Close()
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLSKeyTracksCAFile(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	caFile := writeCAFile(t, upstream)
+
+	pc := &config.ProxyConfig{UseHTTPS: true, TLS: &config.UpstreamTLSConfig{CAFile: caFile}}
+	before := transportKey(pc)
+	if again := transportKey(pc); again != before {
+		t.Fatalf("transport key changed without a config change: %q -> %q", before, again)
+	}
+
+	// CA 文件内容更新后连接池需要重建
+	data, _ := os.ReadFile(caFile)
+	if err := os.WriteFile(caFile, append(data, data...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if after := transportKey(pc); after == before {
+		t.Error("transport key unchanged after the CA file changed")
+	}
+}
+
+// testCA 测试用的 CA，用于签发客户端证书
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCA 生成自签名的测试 CA
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test client CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// issue 签发客户端证书
+func (ca *testCA) issue(t *testing.T, commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// writePEMPair 将客户端证书和私钥写入 PEM 文件
+func writePEMPair(t *testing.T, certFile, keyFile string, cert *x509.Certificate, key *ecdsa.PrivateKey) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpstreamClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	// 上游要求客户端证书，响应中返回客户端证书的 CN
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	upstream.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: roots}
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	cert, key := ca.issue(t, "client-1")
+	writePEMPair(t, certFile, keyFile, cert, key)
+
+	p12File := filepath.Join(dir, "client.p12")
+	p12Cert, p12Key := ca.issue(t, "client-p12")
+	p12Data, err := pkcs12.Modern.Encode(p12Key, p12Cert, []*x509.Certificate{ca.cert}, "p12-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p12File, p12Data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := writeCAFile(t, upstream)
+	request := func(settings *config.UpstreamTLSConfig) (int, string) {
+		h := NewHandler(map[string]*config.ProxyConfig{
+			"api": {
+				TargetDomain: upstream.Listener.Addr().String(),
+				UseHTTPS:     true,
+				TLS:          settings,
+				// 每个请求新建连接，使证书轮换在下一次握手时生效
+				Transport: &config.TransportConfig{DisableKeepAlives: true},
+			},
+		}, testLogger())
+		defer h.Close()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, _ := request(&config.UpstreamTLSConfig{CAFile: caFile}); code != http.StatusBadGateway {
+		t.Errorf("without client certificate: status %d, want %d", code, http.StatusBadGateway)
+	}
+	if code, body := request(&config.UpstreamTLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}); code != http.StatusOK || body != "client-1" {
+		t.Errorf("PEM client certificate: got %d %q, want 200 client-1", code, body)
+	}
+	if code, body := request(&config.UpstreamTLSConfig{CAFile: caFile, PKCS12File: p12File, PKCS12Password: "p12-pass"}); code != http.StatusOK || body != "client-p12" {
+		t.Errorf("PKCS#12 client certificate: got %d %q, want 200 client-p12", code, body)
+	}
+	if code, _ := request(&config.UpstreamTLSConfig{CAFile: caFile, PKCS12File: p12File, PKCS12Password: "wrong"}); code != http.StatusBadGateway {
+		t.Errorf("PKCS#12 with wrong password: status %d, want %d", code, http.StatusBadGateway)
+	}
+}
+
+func TestClientCertRotation(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	cert, key := ca.issue(t, "client-1")
+	writePEMPair(t, certFile, keyFile, cert, key)
+
+	c := &clientCert{route: "api", settings: &config.UpstreamTLSConfig{CertFile: certFile, KeyFile: keyFile}, logger: testLogger()}
+	if err := c.reload(); err != nil {
+		t.Fatal(err)
+	}
+	commonName := func() string {
+		got, err := c.get(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.Leaf.Subject.CommonName
+	}
+	if got := commonName(); got != "client-1" {
+		t.Fatalf("client certificate %q, want client-1", got)
+	}
+
+	// 证书文件替换后下一次握手使用新证书
+	cert, key = ca.issue(t, "client-2")
+	writePEMPair(t, certFile, keyFile, cert, key)
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if got := commonName(); got != "client-2" {
+		t.Errorf("after rotation: client certificate %q, want client-2", got)
+	}
+
+	// 新文件无法加载时继续使用之前的证书
+	if err := os.WriteFile(keyFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := commonName(); got != "client-2" {
+		t.Errorf("after broken rotation: client certificate %q, want client-2", got)
+	}
+}
+
+func TestTLSKeyHidesPassword(t *testing.T) {
+	pc := &config.ProxyConfig{UseHTTPS: true, TLS: &config.UpstreamTLSConfig{PKCS12File: "client.p12", PKCS12Password: "s3cret-p12"}}
+	key := transportKey(pc)
+	if strings.Contains(key, "s3cret-p12") {
+		t.Errorf("transport key contains the PKCS#12 password: %s", key)
+	}
+
+	// 密码变化后连接池需要重建
+	pc.TLS.PKCS12Password = "other"
+	if transportKey(pc) == key {
+		t.Error("transport key unchanged after the PKCS#12 password changed")
+	}
+	key = transportKey(pc)
+	pc.TLS.Profile = config.TLSProfileModern
+	if transportKey(pc) == key {
+		t.Error("transport key unchanged after the TLS profile changed")
+	}
+}
@@ -0,0 +1,311 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"serve/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// upstream 代理路由的上游转发器
+// 在启动或热加载时按路由创建，所有请求共享同一个 ReverseProxy 和连接池，避免每个请求重新建立连接和 TLS 握手
+type upstream struct {
+	route     string                 // 路由名称
+	key       string                 // 连接设置的标识，相同时热加载复用连接池
+	transport *http.Transport        // 长期复用的连接池，所有上游目标共享
+	proxy     *httputil.ReverseProxy // 共享的反向代理，转发目标从请求上下文中读取
+	backends  []*backend             // 上游目标
+	balancer  balancer               // 负载均衡器
+	policy    string                 // 负载均衡策略名称，只有一个目标时为 single
+	checker   *healthChecker         // 主动健康检查，未启用时为 nil
+	retry     *config.RetryConfig    // 填充默认值后的重试配置，未启用重试时为 nil
+	config    *config.ProxyConfig    // 路由配置，RoundTrip 按其设置转发头、Host 头和请求头
+	logger    *logrus.Logger
+}
+
+// UpstreamStats 上游目标的统计数据
+type UpstreamStats struct {
+	Address  string `json:"address"`
+	Weight   int    `json:"weight"`
+	Healthy  bool   `json:"healthy"`
+	Active   int64  `json:"active"`            // 进行中的请求数
+	Requests uint64 `json:"requests"`          // 已转发的请求总数
+	Failures uint64 `json:"failures"`          // 转发失败的请求数
+	Circuit  string `json:"circuit,omitempty"` // 熔断器状态：closed、open、half_open，未启用时为空
+
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"` // 熔断器统计的连续失败次数
+	LastCheck           *time.Time `json:"last_check,omitempty"`           // 最近一次健康检查的时间
+	LastError           string     `json:"last_error,omitempty"`           // 最近一次健康检查的错误
+}
+
+// RouteStats 代理路由的负载均衡和健康检查状态
+type RouteStats struct {
+	Route       string          `json:"route"`
+	Policy      string          `json:"policy"`
+	HealthCheck bool            `json:"health_check"` // 是否启用了主动健康检查
+	Upstreams   []UpstreamStats `json:"upstreams"`
+}
+
+// target 单个请求的转发信息，由 ServeHTTP 计算后通过请求上下文传给 Director 和 RoundTrip
+type target struct {
+	scheme     string       // 目标协议
+	host       string       // 转发给上游的 Host 头（preserve_host 或 request_headers 中设置），为空时使用选中的上游目标地址
+	path       string       // 转发路径
+	pathPrefix string       // 匹配的路径前缀，仅用于日志
+	original   string       // 原始请求 URI，仅用于日志
+	body       []byte       // 为重试缓存的请求体，没有请求体或未缓存时为 nil
+	retryable  bool         // 是否允许重试：路由启用了重试、请求方法允许且请求体已完整缓存
+	attempts   []attempt    // 每次转发尝试的结果，由 RoundTrip 记录
+	vars       *requestVars // 头修改规则的模板变量
+	links      *linkIndex   // 请求所用路由表的链接索引，用于响应体链接改写
+	sticky     bool         // 请求是否未匹配路由、按来源页面（sticky origin）转发
+}
+
+// targetKey 请求上下文中转发目标的 key
+type targetKey struct{}
+
+// withTarget 将转发目标写入请求上下文
+func withTarget(r *http.Request, t *target) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), targetKey{}, t))
+}
+
+// transportKey 生成连接设置的标识，协议、证书验证和连接设置都相同时可以复用连接池
+func transportKey(pc *config.ProxyConfig) string {
+	return fmt.Sprintf("https=%t insecure=%t tls={%s} %+v", pc.UseHTTPS, pc.Insecure, tlsKey(pc), pc.Transport.WithDefaults())
+}
+
+// newUpstream 根据代理配置创建上游转发器
+// previous 为热加载前同名路由的转发器，连接设置未变化时复用其连接池，为 nil 时新建
+func newUpstream(route *Route, previous *upstream, logger *logrus.Logger) *upstream {
+	u := &upstream{route: route.Name, key: transportKey(route.Config), config: route.Config, logger: logger}
+	if route.Config.Retry != nil {
+		settings := route.Config.Retry.WithDefaults()
+		u.retry = &settings
+	}
+	if previous != nil && previous.key == u.key {
+		u.transport = previous.transport
+	} else {
+		var err error
+		u.transport, err = newTransport(route, logger)
+		if err != nil {
+			logger.Errorf("Invalid TLS settings for proxy route %s, upstream connections will fail: %v", route.Name, err)
+		}
+		if route.Config.UseHTTPS && route.Config.Insecure {
+			logger.Debugf("SSL certificate verification disabled for proxy route: %s", route.Name)
+		}
+		if route.Config.UseHTTPS {
+			if weak := route.Config.TLS.GetTLSProfile().WeakSettings(); len(weak) > 0 {
+				logger.Warnf("Weak TLS settings for proxy route %s: %s", route.Name, strings.Join(weak, ", "))
+			}
+		}
+	}
+
+	// 上游目标：同一路由下地址和权重都未变化的目标沿用原有状态
+	reusable := make(map[string]*backend)
+	if previous != nil {
+		for _, b := range previous.backends {
+			reusable[b.addr] = b
+		}
+	}
+	for _, target := range route.Config.UpstreamTargets(route.PathPrefix) {
+		b, ok := reusable[target.Address]
+		if !ok || b.weight != target.Weight {
+			b = newBackend(target.Address, target.Weight)
+		}
+		u.backends = append(u.backends, b)
+	}
+	for _, b := range u.backends {
+		if route.Config.CircuitBreaker == nil {
+			b.breaker.Store(nil)
+			continue
+		}
+		// 熔断器配置未变化时保留熔断状态
+		settings := route.Config.CircuitBreaker.WithDefaults()
+		if cb := b.breaker.Load(); cb == nil || cb.route != route.Name || !reflect.DeepEqual(cb.settings, settings) {
+			b.breaker.Store(newCircuitBreaker(route.Name, b.addr, settings, logger))
+		}
+	}
+	if route.Config.HealthCheck != nil {
+		u.checker = newHealthChecker(route, u.transport, u.backends, logger)
+	} else {
+		// 关闭健康检查后所有目标恢复参与负载均衡
+		for _, b := range u.backends {
+			b.healthy.Store(true)
+		}
+	}
+	u.balancer = newBalancer(route.Config.LoadBalancer, u.backends)
+	u.policy = route.Config.LoadBalancer.GetPolicy()
+	if len(u.backends) == 1 {
+		u.policy = "single"
+	}
+
+	// 目标地址（URL 和 Host 头）由 RoundTrip 在每次尝试时根据选中的上游目标设置，响应头按路由的头修改规则处理
+	u.proxy = &httputil.ReverseProxy{
+		Transport: u,
+		Director: func(req *http.Request) {
+			t := req.Context().Value(targetKey{}).(*target)
+			req.URL.Scheme = t.scheme
+			req.URL.Path = t.path
+			req.URL.RawPath = ""
+			if _, ok := req.Header["User-Agent"]; !ok {
+				// 与 httputil.NewSingleHostReverseProxy 一致，不使用默认的 User-Agent
+				req.Header.Set("User-Agent", "")
+			}
+			if route.Config.RewriteBody != nil {
+				// 改写响应体时只接受可以解压的 gzip 编码，客户端不接受 gzip 时由 Transport 请求 gzip 并自动解压
+				if strings.Contains(strings.ToLower(req.Header.Get("Accept-Encoding")), "gzip") {
+					req.Header.Set("Accept-Encoding", "gzip")
+				} else {
+					req.Header.Del("Accept-Encoding")
+				}
+			}
+
+			logger.Debugf("Proxy request details: Method=%s, Path=%s, PathPrefix=%s, Retryable=%t",
+				req.Method, req.URL.Path, t.pathPrefix, t.retryable)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			t := resp.Request.Context().Value(targetKey{}).(*target)
+			if route.Config.ReverseRewrite != nil {
+				rewriteResponseHeaders(resp, route, t, logger)
+			}
+			if route.Config.StickyOrigin && !t.sticky {
+				setStickyCookie(resp, route)
+			}
+			applyHeaderRules(resp.Header, route.Config.ResponseHeaders, t.vars)
+			if route.Config.RewriteBody != nil {
+				return rewriteResponseBody(resp, route, t.links, logger)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			var unavailable *unavailableError
+			switch {
+			case errors.As(err, &unavailable):
+				// 没有可用目标时快速失败，因熔断而不可用时通过 Retry-After 告知冷却期的剩余时间
+				if unavailable.retryAfter > 0 {
+					seconds := int(math.Ceil(unavailable.retryAfter.Seconds()))
+					logger.Warnf("Circuit open for proxy route %s, failing fast: %s %s%s (retry after %ds)", route.Name, req.Method, req.Host, req.URL.Path, seconds)
+					w.Header().Set("Retry-After", strconv.Itoa(seconds))
+					http.Error(w, fmt.Sprintf("Upstream circuit open, retry after %d seconds", seconds), http.StatusServiceUnavailable)
+					return
+				}
+				logger.Warnf("No healthy upstream for proxy route %s: %s %s%s", route.Name, req.Method, req.Host, req.URL.Path)
+				http.Error(w, "No healthy upstream available", http.StatusServiceUnavailable)
+			case errors.Is(err, context.Canceled):
+				// 客户端取消请求不视为上游失败
+				logger.Debugf("Proxy request canceled by client for route %s: %s %s", route.Name, req.Method, req.URL.String())
+				w.WriteHeader(http.StatusBadGateway)
+			default:
+				logger.Errorf("Proxy error for route %s: %s %s: %v", route.Name, req.Method, req.URL.String(), err)
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		},
+	}
+	return u
+}
+
+// pick 为请求选择上游目标
+func (u *upstream) pick(r *http.Request) *backend {
+	return u.balancer.pick(r)
+}
+
+// retryAfter 获取最早结束的熔断冷却期剩余时间，没有健康且熔断中的目标时返回 0
+func (u *upstream) retryAfter() time.Duration {
+	var wait time.Duration
+	for _, b := range u.backends {
+		cb := b.breaker.Load()
+		if cb == nil || !b.healthy.Load() {
+			continue
+		}
+		if d := cb.retryAfter(); d > 0 && (wait == 0 || d < wait) {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// close 停止健康检查
+func (u *upstream) close() {
+	if u.checker != nil {
+		u.checker.close()
+	}
+}
+
+// stats 获取负载均衡和健康检查状态
+func (u *upstream) stats(name string) RouteStats {
+	stats := RouteStats{Route: name, Policy: u.policy, HealthCheck: u.checker != nil}
+	for _, b := range u.backends {
+		upstreamStats := UpstreamStats{
+			Address:  b.addr,
+			Weight:   b.weight,
+			Healthy:  b.healthy.Load(),
+			Active:   b.active.Load(),
+			Requests: b.requests.Load(),
+			Failures: b.failures.Load(),
+		}
+		if cb := b.breaker.Load(); cb != nil {
+			state, failures := cb.snapshot()
+			upstreamStats.Circuit = state.String()
+			upstreamStats.ConsecutiveFailures = failures
+		}
+		if lastCheck, lastError := b.lastCheckResult(); !lastCheck.IsZero() {
+			upstreamStats.LastCheck = &lastCheck
+			upstreamStats.LastError = lastError
+		}
+		stats.Upstreams = append(stats.Upstreams, upstreamStats)
+	}
+	return stats
+}
+
+// newTransport 根据代理配置创建连接池，上游 TLS 配置无法加载时同时返回连接池（连接全部验证失败）和错误
+func newTransport(route *Route, logger *logrus.Logger) (*http.Transport, error) {
+	pc := route.Config
+	settings := pc.Transport.WithDefaults()
+	dialer := &net.Dialer{
+		Timeout:   settings.DialTimeout.Duration(),
+		KeepAlive: settings.KeepAlive.Duration(),
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		DisableKeepAlives:     settings.DisableKeepAlives,
+		MaxIdleConns:          settings.MaxIdleConns,
+		MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       settings.MaxConnsPerHost,
+		IdleConnTimeout:       settings.IdleConnTimeout.Duration(),
+		TLSHandshakeTimeout:   settings.TLSHandshakeTimeout.Duration(),
+		ResponseHeaderTimeout: settings.ResponseHeaderTimeout.Duration(),
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	// 配置传输层，按路由的 TLS 档位设置协议版本和加密套件
+	if pc.UseHTTPS {
+		transport.TLSClientConfig = &tls.Config{
+			// 跳过 SSL 证书验证
+			InsecureSkipVerify: pc.Insecure,
+		}
+		if err := pc.TLS.GetTLSProfile().Apply(transport.TLSClientConfig); err != nil {
+			transport.TLSClientConfig.VerifyConnection = func(tls.ConnectionState) error { return err }
+			return transport, err
+		}
+		if err := configureUpstreamTLS(transport.TLSClientConfig, route.Name, pc.TLS, logger); err != nil {
+			return transport, err
+		}
+	}
+
+	return transport, nil
+}
@@ -0,0 +1,288 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"html"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"serve/internal/autotls"
+	"serve/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CAPagePath 本地 CA 安装页面的路径，只在 HTTP 监听器上提供（设备信任 CA 之前无法正常访问 HTTPS）
+const CAPagePath = "/.well-known/serve-ca"
+
+// 安装页面提供的 CA 证书文件
+const (
+	caPEMPath          = CAPagePath + "/ca.pem"
+	caDERPath          = CAPagePath + "/ca.crt"
+	caMobileConfigPath = CAPagePath + "/ca.mobileconfig"
+)
+
+// caPage 本地 CA 安装页面，提供 PEM、DER 和 iOS 描述文件格式的 CA 证书以及指纹和安装说明
+type caPage struct {
+	cert       *x509.Certificate
+	httpsPorts []string // HTTPS 监听器的端口，用于在页面中给出安装后访问的地址
+}
+
+// newCAPage 服务器证书由 serve 管理的本地 CA（auto_tls_dir 中的 rootCA.pem）签发时返回安装页面，否则返回 nil
+// 检查顶层、虚拟主机和监听器的证书，任意一张证书由本地 CA 签发即启用
+func newCAPage(cfg *config.Config, certificates []*tls.Certificate, logger *logrus.Logger) *caPage {
+	store := &autotls.Store{Dir: cfg.GetAutoTLSDir()}
+	ca, err := autotls.LoadCertificate(store.CAFile())
+	if err != nil {
+		return nil
+	}
+
+	var leaves []*x509.Certificate
+	for _, cert := range certificates {
+		if cert != nil && cert.Leaf != nil {
+			leaves = append(leaves, cert.Leaf)
+		}
+	}
+	for _, listener := range cfg.Listeners {
+		if listener != nil && listener.CertFile != "" {
+			if leaf, err := autotls.LoadCertificate(listener.CertFile); err == nil {
+				leaves = append(leaves, leaf)
+			}
+		}
+	}
+
+	for _, leaf := range leaves {
+		if !autotls.IssuedBy(leaf, ca) {
+			continue
+		}
+		page := &caPage{cert: ca}
+		for _, listener := range cfg.EffectiveListeners() {
+			if !listener.IsHTTPS() {
+				continue
+			}
+			if _, port, err := net.SplitHostPort(listener.Addr); err == nil {
+				page.httpsPorts = append(page.httpsPorts, port)
+			}
+		}
+		logger.Debugf("Server certificate %s is issued by local CA %s, CA install page enabled", leaf.Subject, store.CAFile())
+		return page
+	}
+	return nil
+}
+
+// caPageURL 获取监听地址上安装页面的 URL，用于日志
+// 监听所有地址时使用本机的第一个非回环 IPv4 地址，便于在手机上直接输入
+func caPageURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr + CAPagePath
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+		for _, ip := range autotls.LocalIPs() {
+			if ip.To4() != nil && !ip.IsLoopback() {
+				host = ip.String()
+				break
+			}
+		}
+	}
+	return "http://" + net.JoinHostPort(host, port) + CAPagePath
+}
+
+// matches 判断请求路径是否属于安装页面
+func (p *caPage) matches(path string) bool {
+	return path == CAPagePath || strings.HasPrefix(path, CAPagePath+"/")
+}
+
+// ServeHTTP 输出安装页面或 CA 证书文件
+func (p *caPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch r.URL.Path {
+	case CAPagePath, CAPagePath + "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		caPageTemplate.Execute(w, p.pageData(r))
+	case caPEMPath:
+		p.download(w, "application/x-pem-file", "serve-rootCA.pem",
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.cert.Raw}))
+	case caDERPath:
+		// Android 按此类型识别为 CA 证书并进入安装流程
+		p.download(w, "application/x-x509-ca-cert", "serve-rootCA.crt", p.cert.Raw)
+	case caMobileConfigPath:
+		p.download(w, "application/x-apple-aspen-config", "serve-rootCA.mobileconfig", p.mobileConfig())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// download 以附件形式输出文件
+func (p *caPage) download(w http.ResponseWriter, contentType, filename string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(data)
+}
+
+// caPageData 安装页面的模板数据
+type caPageData struct {
+	Name         string
+	NotBefore    string
+	NotAfter     string
+	SHA256       string
+	SHA1         string
+	PEMPath      string
+	DERPath      string
+	MobilePath   string
+	HTTPSAddress []string
+}
+
+// pageData 生成安装页面的模板数据，安装后访问的地址使用请求的主机名和 HTTPS 监听器的端口
+func (p *caPage) pageData(r *http.Request) caPageData {
+	data := caPageData{
+		Name:       p.cert.Subject.CommonName,
+		NotBefore:  p.cert.NotBefore.Format(time.DateOnly),
+		NotAfter:   p.cert.NotAfter.Format(time.DateOnly),
+		SHA256:     autotls.Fingerprint(p.cert),
+		SHA1:       autotls.FingerprintSHA1(p.cert),
+		PEMPath:    caPEMPath,
+		DERPath:    caDERPath,
+		MobilePath: caMobileConfigPath,
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, port := range p.httpsPorts {
+		data.HTTPSAddress = append(data.HTTPSAddress, "https://"+net.JoinHostPort(host, port)+"/")
+	}
+	return data
+}
+
+// mobileConfig 生成 iOS/macOS 描述文件，包含一个根证书载荷
+// 描述文件未签名，安装时会显示“未验证”；UUID 由证书内容生成，重复下载安装时替换同一个描述文件
+func (p *caPage) mobileConfig() []byte {
+	name := html.EscapeString(p.cert.Subject.CommonName)
+	profileUUID := certUUID(p.cert, "profile")
+	payloadUUID := certUUID(p.cert, "payload")
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadCertificateFileName</key>
+			<string>serve-rootCA.crt</string>
+			<key>PayloadContent</key>
+			<data>%s</data>
+			<key>PayloadDescription</key>
+			<string>Adds the serve local root CA</string>
+			<key>PayloadDisplayName</key>
+			<string>%s</string>
+			<key>PayloadIdentifier</key>
+			<string>com.apple.security.root.%s</string>
+			<key>PayloadType</key>
+			<string>com.apple.security.root</string>
+			<key>PayloadUUID</key>
+			<string>%s</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+		</dict>
+	</array>
+	<key>PayloadDescription</key>
+	<string>Trust HTTPS certificates issued by serve on this computer</string>
+	<key>PayloadDisplayName</key>
+	<string>%s</string>
+	<key>PayloadIdentifier</key>
+	<string>serve.local-ca.%s</string>
+	<key>PayloadRemovalDisallowed</key>
+	<false/>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>%s</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+</dict>
+</plist>
+`, base64.StdEncoding.EncodeToString(p.cert.Raw), name, payloadUUID, payloadUUID, name, profileUUID, profileUUID))
+}
+
+// certUUID 根据证书内容和用途生成固定的 UUID（版本 4 格式）
+func certUUID(cert *x509.Certificate, purpose string) string {
+	sum := sha256.Sum256(append([]byte(purpose+":"), cert.Raw...))
+	b := sum[:16]
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]))
+}
+
+// caPageTemplate 安装页面模板
+var caPageTemplate = template.Must(template.New("ca").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>安装 serve 本地 CA 证书</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 40rem; margin: 0 auto; padding: 1rem; line-height: 1.6; color: #222; }
+.buttons a { display: block; margin: .5rem 0; padding: .8rem; border-radius: .5rem; background: #0a66c2; color: #fff; text-align: center; text-decoration: none; }
+code { font-size: .85rem; word-break: break-all; }
+h2 { font-size: 1.1rem; margin-top: 1.5rem; }
+</style>
+</head>
+<body>
+<h1>安装 serve 本地 CA 证书</h1>
+<p>这台电脑上的 serve 使用本地 CA 签发 HTTPS 证书。在设备上安装并信任该 CA 后，即可通过 HTTPS 正常访问，不再出现证书警告。</p>
+<p>CA 名称：{{.Name}}<br>有效期：{{.NotBefore}} 至 {{.NotAfter}}</p>
+<p>请先核对指纹与电脑上 <code>serve cert</code> 输出的指纹一致：<br>
+SHA-256：<code>{{.SHA256}}</code><br>
+SHA-1：<code>{{.SHA1}}</code></p>
+
+<div class="buttons">
+<a href="{{.MobilePath}}">iPhone / iPad / Mac：下载描述文件（.mobileconfig）</a>
+<a href="{{.DERPath}}">Android / Windows：下载证书（.crt）</a>
+<a href="{{.PEMPath}}">其他系统：下载 PEM 证书（.pem）</a>
+</div>
+
+<h2>iPhone / iPad</h2>
+<ol>
+<li>使用 Safari 打开本页，点击下载描述文件并选择“允许”</li>
+<li>打开“设置 → 通用 → VPN 与设备管理”（或设置首页的“已下载描述文件”），安装该描述文件</li>
+<li>打开“设置 → 通用 → 关于本机 → 证书信任设置”，为该 CA 开启“完全信任”</li>
+</ol>
+
+<h2>Android</h2>
+<ol>
+<li>下载 .crt 证书</li>
+<li>打开“设置 → 安全 → 加密与凭据 → 安装证书 → CA 证书”（不同厂商的菜单名称可能不同，可以在设置中搜索“CA 证书”），选择下载的文件</li>
+<li>Android 7 及以上版本中，用户安装的 CA 默认只被浏览器等信任用户证书的应用使用</li>
+</ol>
+
+<h2>Mac / Windows / Linux</h2>
+<ul>
+<li>Mac：打开描述文件后在“系统设置 → 通用 → 设备管理”中安装，或将 .pem 导入“钥匙串访问”的“系统”钥匙串并设为“始终信任”</li>
+<li>Windows：打开 .crt，选择“安装证书”，存储位置选择“受信任的根证书颁发机构”</li>
+<li>Linux：将 .pem 复制到 <code>/usr/local/share/ca-certificates/serve-rootCA.crt</code> 后执行 <code>sudo update-ca-certificates</code>；Firefox 需要在浏览器设置中单独导入</li>
+</ul>
+{{if .HTTPSAddress}}
+<h2>安装完成后访问</h2>
+<ul>
+{{range .HTTPSAddress}}<li><a href="{{.}}">{{.}}</a></li>
+{{end}}</ul>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"serve/internal/autotls"
+	"serve/internal/config"
+)
+
+// newLocalCA 在临时目录中创建本地 CA 并签发服务器证书，返回存储目录和 CA
+func newLocalCA(t *testing.T) (*autotls.Store, *autotls.CA) {
+	t.Helper()
+	store := &autotls.Store{Dir: t.TempDir()}
+	ca, _, err := store.LoadOrCreateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.EnsureLeaf(ca, autotls.CollectNames(nil), false); err != nil {
+		t.Fatal(err)
+	}
+	return store, ca
+}
+
+func TestNewCAPage(t *testing.T) {
+	store, _ := newLocalCA(t)
+	other, _ := newLocalCA(t)
+	selfCert, selfKey := newCertPair(t, "localhost")
+
+	tests := []struct {
+		name   string
+		setup  func(cfg *config.Config)
+		wantOn bool
+	}{
+		{"top-level certificate issued by the local CA", func(cfg *config.Config) {
+			cfg.CertFile, cfg.KeyFile = store.CertFile(), store.KeyFile()
+		}, true},
+		{"listener certificate issued by the local CA", func(cfg *config.Config) {
+			cfg.Listeners = []*config.Listener{
+				{Addr: ":8080", Protocol: config.ProtocolHTTP},
+				{Addr: ":8443", Protocol: config.ProtocolHTTPS, CertFile: store.CertFile(), KeyFile: store.KeyFile()},
+			}
+		}, true},
+		{"self-signed certificate", func(cfg *config.Config) {
+			cfg.CertFile, cfg.KeyFile = selfCert, selfKey
+		}, false},
+		{"certificate issued by another CA", func(cfg *config.Config) {
+			cfg.CertFile, cfg.KeyFile = other.CertFile(), other.KeyFile()
+		}, false},
+		{"no certificate", func(cfg *config.Config) {}, false},
+	}
+	for _, tt := range tests {
+		cfg := config.LoadConfig()
+		cfg.StaticDir = t.TempDir()
+		cfg.AutoTLSDir = store.Dir
+		tt.setup(cfg)
+		rt, err := newRouter(cfg, nil, testLogger())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if on := rt.caPage != nil; on != tt.wantOn {
+			t.Errorf("%s: CA page enabled = %t, want %t", tt.name, on, tt.wantOn)
+		}
+	}
+
+	// CA 目录中没有 CA 时不启用
+	cfg := config.LoadConfig()
+	cfg.StaticDir = t.TempDir()
+	cfg.AutoTLSDir = t.TempDir()
+	cfg.CertFile, cfg.KeyFile = store.CertFile(), store.KeyFile()
+	if rt, err := newRouter(cfg, nil, testLogger()); err != nil || rt.caPage != nil {
+		t.Errorf("without rootCA.pem: CA page enabled = %t (err %v), want disabled", rt != nil && rt.caPage != nil, err)
+	}
+}
+
+func TestCAPageResponses(t *testing.T) {
+	store, ca := newLocalCA(t)
+	cfg := config.LoadConfig()
+	cfg.StaticDir = t.TempDir()
+	cfg.AutoTLSDir = store.Dir
+	cfg.CertFile, cfg.KeyFile = store.CertFile(), store.KeyFile()
+	cfg.Listeners = []*config.Listener{
+		{Addr: ":8080", Protocol: config.ProtocolHTTP},
+		{Addr: ":8443", Protocol: config.ProtocolHTTPS},
+	}
+	s := newTestServer(t, cfg)
+
+	get := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Host = "192.168.1.20:8080"
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(http.MethodGet, CAPagePath)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("page: status %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	page := rec.Body.String()
+	for _, want := range []string{autotls.Fingerprint(ca.Cert), caPEMPath, caDERPath, caMobileConfigPath, "https://192.168.1.20:8443/"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+
+	rec = get(http.MethodGet, caPEMPath)
+	block, _ := pem.Decode(rec.Body.Bytes())
+	if block == nil || block.Type != "CERTIFICATE" || !bytes.Equal(block.Bytes, ca.Cert.Raw) {
+		t.Errorf("ca.pem does not contain the CA certificate: %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="serve-rootCA.pem"` {
+		t.Errorf("ca.pem Content-Disposition = %q", got)
+	}
+
+	rec = get(http.MethodGet, caDERPath)
+	if rec.Header().Get("Content-Type") != "application/x-x509-ca-cert" || !bytes.Equal(rec.Body.Bytes(), ca.Cert.Raw) {
+		t.Errorf("ca.crt: Content-Type %q, body is not the DER CA certificate", rec.Header().Get("Content-Type"))
+	}
+
+	rec = get(http.MethodGet, caMobileConfigPath)
+	profile := rec.Body.String()
+	if rec.Header().Get("Content-Type") != "application/x-apple-aspen-config" {
+		t.Errorf("mobileconfig Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		"<data>" + base64.StdEncoding.EncodeToString(ca.Cert.Raw) + "</data>",
+		"<string>com.apple.security.root</string>",
+		"<string>" + certUUID(ca.Cert, "profile") + "</string>",
+	} {
+		if !strings.Contains(profile, want) {
+			t.Errorf("mobileconfig does not contain %q", want)
+		}
+	}
+	// 重复下载的描述文件相同，安装时替换而不是新增
+	if again := get(http.MethodGet, caMobileConfigPath).Body.String(); again != profile {
+		t.Error("mobileconfig differs between downloads")
+	}
+
+	if rec := get(http.MethodGet, CAPagePath+"/other"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown file: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := get(http.MethodPost, caPEMPath); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestCAPageHTTPOnly(t *testing.T) {
+	store, ca := newLocalCA(t)
+	httpAddr, httpsAddr := freeAddr(t), freeAddr(t)
+
+	cfg := config.LoadConfig()
+	cfg.StaticDir = newStaticDir(t, "site.txt", "site")
+	cfg.AutoTLSDir = store.Dir
+	cfg.CertFile, cfg.KeyFile = store.CertFile(), store.KeyFile()
+	cfg.Listeners = []*config.Listener{
+		{Addr: httpAddr, Protocol: config.ProtocolHTTP},
+		{Addr: httpsAddr, Protocol: config.ProtocolHTTPS},
+	}
+	s := newTestServer(t, cfg)
+	startServer(t, s)
+
+	if got := fetch(t, http.DefaultClient, "http://"+httpAddr+caDERPath); got != string(ca.Cert.Raw) {
+		t.Error("HTTP listener did not serve the CA certificate")
+	}
+
+	// HTTPS 监听器上的请求交给站点处理
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	fetch(t, client, "https://"+httpsAddr+"/site.txt")
+	resp, err := client.Get("https://" + httpsAddr + caDERPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || bytes.Equal(body, ca.Cert.Raw) {
+		t.Errorf("HTTPS listener: status %d, want %d from the static site", resp.StatusCode, http.StatusNotFound)
+	}
+}
@@ -3,106 +3,259 @@ package server
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"serve/internal/config"
-	"serve/internal/proxy"
-	"serve/internal/static"
 
 	"github.com/sirupsen/logrus"
 )
 
 // Server HTTP/HTTPS 服务器
 type Server struct {
-	config     *config.Config
-	httpServer *http.Server
-	logger     *logrus.Logger
+	config      *config.Config         // 启动时的配置，监听器以此为准
+	httpServers []*http.Server         // 每个监听器对应一个 http.Server
+	mu          sync.Mutex             // 保护 httpServers
+	router      atomic.Pointer[router] // 当前生效的站点路由快照，热加载时整体替换
+	logger      *logrus.Logger
 }
 
 // NewServer 创建新的服务器实例
-func NewServer(cfg *config.Config, logger *logrus.Logger) *Server {
-	return &Server{
+// cfg 需要已经通过 Validate 校验
+func NewServer(cfg *config.Config, logger *logrus.Logger) (*Server, error) {
+	s := &Server{
 		config: cfg,
 		logger: logger,
 	}
+
+	rt, err := newRouter(cfg, nil, logger)
+	if err != nil {
+		return nil, err
+	}
+	s.router.Store(rt)
+
+	return s, nil
 }
 
 // Start 启动服务器
+// 先绑定所有监听地址（任意一个失败时关闭已绑定的地址并返回错误），再在各自的 goroutine 中提供服务
+// 阻塞直到所有监听器停止，返回第一个非 http.ErrServerClosed 的错误
 func (s *Server) Start() error {
 	// 创建路由处理器
 	mux := http.NewServeMux()
 
-	// 创建静态文件处理器
-	staticHandler := static.NewHandler(s.config.StaticDir, s.logger)
-
-	// 创建代理处理器
-	proxyHandler := proxy.NewHandler(s.config, s.logger)
-
 	// 注册路由处理函数
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// 首先检查是否为代理路径
-		if proxyHandler.IsProxyPath(r.URL.Path) {
-			proxyHandler.ServeHTTP(w, r)
-			return
+	mux.HandleFunc("/", s.ServeHTTP)
+
+	// 绑定所有监听地址
+	listeners := s.config.EffectiveListeners()
+	netListeners := make([]net.Listener, 0, len(listeners))
+	servers := make([]*http.Server, 0, len(listeners))
+	for _, listener := range listeners {
+		server, ln, err := s.listen(listener, mux)
+		if err != nil {
+			for _, opened := range netListeners {
+				opened.Close()
+			}
+			return err
 		}
+		netListeners = append(netListeners, ln)
+		servers = append(servers, server)
+	}
+	s.mu.Lock()
+	s.httpServers = servers
+	s.mu.Unlock()
 
-		// 否则使用静态文件服务
-		staticHandler.ServeHTTP(w, r)
-	})
+	if s.router.Load().caPage != nil && !hasHTTPListener(listeners) {
+		s.logger.Warnf("Server certificate is issued by the local CA, but the CA install page (%s) is only served on HTTP listeners; add one (e.g. --listen http://:8080) so devices can download the CA", CAPagePath)
+	}
+
+	// 所有监听器共享同一套路由，分别在独立的 goroutine 中提供服务
+	errCh := make(chan error, len(servers))
+	for i, server := range servers {
+		go func(server *http.Server, ln net.Listener) {
+			// HTTPS 使用 ServeTLS，保留标准库对 HTTP/2 的自动支持
+			if server.TLSConfig != nil {
+				errCh <- server.ServeTLS(ln, "", "")
+				return
+			}
+			errCh <- server.Serve(ln)
+		}(server, netListeners[i])
+	}
 
+	var firstErr error
+	for range servers {
+		if err := <-errCh; err != nil && err != http.ErrServerClosed && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return http.ErrServerClosed
+}
+
+// listen 为监听器创建 http.Server 并绑定监听地址
+func (s *Server) listen(listener *config.Listener, handler http.Handler) (*http.Server, net.Listener, error) {
 	// 创建 HTTP 服务器
-	s.httpServer = &http.Server{
-		Addr:         s.config.Host,
-		Handler:      mux,
+	server := &http.Server{
+		Addr:         listener.Addr,
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
-	// 根据配置启动 HTTP 或 HTTPS 服务
-	if s.config.IsHTTPS() {
-		// 配置 TLS 以支持 Android 4 等旧版本浏览器
-		tlsConfig := &tls.Config{
-			MinVersion: tls.VersionTLS10, // 支持 TLS 1.0（Android 4 支持的最低版本）
-			MaxVersion: tls.VersionTLS13, // 支持到 TLS 1.3
-			// 使用兼容 Android 4 的加密套件
-			CipherSuites: []uint16{
-				tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-				tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
-				// 现代加密套件（优先）
-				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			},
-			PreferServerCipherSuites: true,
+	ln, err := net.Listen("tcp", listener.Addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen on %s: %v", listener, err)
+	}
+
+	if !listener.IsHTTPS() {
+		s.logger.Infof("Starting HTTP server on %s", listener.Addr)
+		if s.router.Load().caPage != nil {
+			s.logger.Infof("Local CA install page: %s", caPageURL(listener.Addr))
+		}
+		return server, ln, nil
+	}
+
+	// 按监听器或顶层的 TLS 档位设置协议版本和加密套件
+	profile := s.config.ListenerTLS(listener)
+	tlsConfig := &tls.Config{}
+	if err := profile.Apply(tlsConfig); err != nil {
+		ln.Close()
+		return nil, nil, fmt.Errorf("%s: invalid tls settings: %v", listener, err)
+	}
+
+	if listener.CertFile != "" {
+		// 监听器指定了证书时固定使用该证书
+		cert, err := tls.LoadX509KeyPair(listener.CertFile, listener.KeyFile)
+		if err != nil {
+			ln.Close()
+			return nil, nil, fmt.Errorf("%s: failed to load certificate pair: %v", listener, err)
 		}
-		s.httpServer.TLSConfig = tlsConfig
-		
-		s.logger.Infof("Starting HTTPS server on %s", s.config.Host)
-		s.logger.Infof("Certificate: %s, Key: %s", s.config.CertFile, s.config.KeyFile)
-		s.logger.Infof("TLS configuration: MinVersion=TLS1.0, MaxVersion=TLS1.3 (Android 4 compatible)")
-		return s.httpServer.ListenAndServeTLS(s.config.CertFile, s.config.KeyFile)
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		s.logger.Infof("Certificate for %s: %s, Key: %s", listener, listener.CertFile, listener.KeyFile)
+	} else {
+		// 按 SNI 选择虚拟主机证书，证书随配置热加载
+		tlsConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.router.Load().certificate(hello.ServerName)
+		}
+		if s.config.CertFile != "" {
+			s.logger.Infof("Certificate: %s, Key: %s", s.config.CertFile, s.config.KeyFile)
+		}
+	}
+	server.TLSConfig = tlsConfig
+
+	s.logger.Infof("Starting HTTPS server on %s", listener.Addr)
+	s.logger.Infof("TLS configuration for %s: %s", listener, profile.Describe())
+	if weak := profile.WeakSettings(); len(weak) > 0 {
+		s.logger.Warnf("Weak TLS settings on %s: %s", listener, strings.Join(weak, ", "))
 	}
+	return server, ln, nil
+}
 
-	s.logger.Infof("Starting HTTP server on %s", s.config.Host)
-	return s.httpServer.ListenAndServe()
+// ServeHTTP 分发请求：按 Host 头选择站点（虚拟主机），未匹配时使用默认站点
+// 状态接口优先于所有站点
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt := s.router.Load()
+	if rt.statusPath != "" && r.URL.Path == rt.statusPath {
+		rt.serveStatus(w, r)
+		return
+	}
+	// 本地 CA 安装页面只在 HTTP 监听器上提供
+	if rt.caPage != nil && r.TLS == nil && rt.caPage.matches(r.URL.Path) {
+		rt.caPage.ServeHTTP(w, r)
+		return
+	}
+
+	site := rt.match(r.Host)
+	s.logger.Debugf("Request %s %s%s dispatched to site %s", r.Method, r.Host, r.URL.Path, site.name)
+	site.ServeHTTP(w, r)
 }
 
-// Stop 停止服务器
+// Reload 热加载配置
+// 代理路由、静态文件目录、虚拟主机和证书原子替换，进行中的请求不受影响
+// 监听器（地址、协议、监听器证书和 TLS 档位）需要重启才能生效，发生变化时仅输出警告
+// 调用方需要保证 cfg 已经通过 Validate 校验
+func (s *Server) Reload(cfg *config.Config) error {
+	if !sameListeners(cfg, s.config) {
+		s.logger.Warn("Listener settings (host, listeners, HTTP/HTTPS mode, TLS profile) changed, restart required to take effect")
+	}
+
+	rt, err := newRouter(cfg, s.router.Load(), s.logger)
+	if err != nil {
+		return err
+	}
+	s.router.Store(rt)
+
+	s.logger.Infof("Configuration reloaded: static directory %s, %d proxy configurations, %d virtual hosts",
+		cfg.StaticDir, len(cfg.ProxyConfigs), len(cfg.VirtualHosts))
+	return nil
+}
+
+// Stop 停止服务器，所有监听器一起优雅关闭
 func (s *Server) Stop(ctx context.Context) error {
 	s.logger.Info("Shutting down server...")
-	return s.httpServer.Shutdown(ctx)
+
+	s.mu.Lock()
+	servers := s.httpServers
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(servers))
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server *http.Server) {
+			defer wg.Done()
+			errs[i] = server.Shutdown(ctx)
+		}(i, server)
+	}
+	wg.Wait()
+
+	// 停止代理上游的健康检查
+	s.router.Load().close()
+
+	return errors.Join(errs...)
 }
 
-// GetAddr 获取服务器监听地址
+// GetAddr 获取服务器监听地址，多个监听器时以逗号分隔
 func (s *Server) GetAddr() string {
-	return s.config.Host
+	listeners := s.config.EffectiveListeners()
+	addrs := make([]string, 0, len(listeners))
+	for _, listener := range listeners {
+		addrs = append(addrs, listener.String())
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// hasHTTPListener 判断是否有 HTTP 监听器
+func hasHTTPListener(listeners []*config.Listener) bool {
+	for _, listener := range listeners {
+		if !listener.IsHTTPS() {
+			return true
+		}
+	}
+	return false
 }
 
+// sameListeners 判断两份配置的监听器设置（包括生效的 TLS 档位）是否相同
+func sameListeners(a, b *config.Config) bool {
+	la, lb := a.EffectiveListeners(), b.EffectiveListeners()
+	if len(la) != len(lb) {
+		return false
+	}
+	for i := range la {
+		if !reflect.DeepEqual(la[i], lb[i]) || !reflect.DeepEqual(a.ListenerTLS(la[i]), b.ListenerTLS(lb[i])) {
+			return false
+		}
+	}
+	return true
+}
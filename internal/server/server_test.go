@@ -0,0 +1,351 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"serve/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testLogger 返回丢弃输出的日志记录器
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// newUpstream 启动一个在响应中回显请求路径的上游服务
+func newUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "upstream "+r.URL.Path)
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+// newStaticDir 创建包含单个文件的静态文件目录
+func newStaticDir(t *testing.T, name, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// newCertPair 生成自签名证书，写入临时目录并返回证书和私钥文件路径
+func newCertPair(t *testing.T, hosts ...string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// freeAddr 获取一个当前空闲的本地监听地址
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// startServer 在后台启动服务器，测试结束时停止并确认 Start 正常返回
+func startServer(t *testing.T, s *Server) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- s.Start() }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Stop(ctx); err != nil {
+			t.Errorf("Stop: %v", err)
+		}
+		if err := <-done; err != http.ErrServerClosed {
+			t.Errorf("Start returned %v, want %v", err, http.ErrServerClosed)
+		}
+	})
+}
+
+// fetch 请求 URL 直到监听器就绪，返回响应内容
+func fetch(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := client.Get(url)
+		if err == nil {
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(body)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newTestServer 创建服务器，创建失败时测试失败
+func newTestServer(t *testing.T, cfg *config.Config) *Server {
+	t.Helper()
+	s, err := NewServer(cfg, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// reload 热加载配置，加载失败时测试失败
+func reload(t *testing.T, s *Server, cfg *config.Config) {
+	t.Helper()
+	if err := s.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// get 通过服务器的路由处理请求，返回状态码和响应内容
+func get(s *Server, path string) (int, string) {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestReloadAddsAndRemovesRoutes(t *testing.T) {
+	upstream := newUpstream(t)
+	cfg := config.LoadConfig()
+	cfg.StaticDir = newStaticDir(t, "home.txt", "home")
+	s := newTestServer(t, cfg)
+
+	if code, _ := get(s, "/api/users"); code != http.StatusNotFound {
+		t.Fatalf("before reload: status %d, want %d", code, http.StatusNotFound)
+	}
+
+	added := config.LoadConfig()
+	added.StaticDir = cfg.StaticDir
+	added.ProxyConfigs["api"] = &config.ProxyConfig{TargetDomain: upstream.Listener.Addr().String()}
+	reload(t, s, added)
+
+	code, body := get(s, "/api/users")
+	if code != http.StatusOK || body != "upstream /api/users" {
+		t.Fatalf("after adding route: got %d %q, want 200 %q", code, body, "upstream /api/users")
+	}
+
+	removed := config.LoadConfig()
+	removed.StaticDir = cfg.StaticDir
+	reload(t, s, removed)
+
+	if code, _ := get(s, "/api/users"); code != http.StatusNotFound {
+		t.Fatalf("after removing route: status %d, want %d", code, http.StatusNotFound)
+	}
+	if code, body := get(s, "/home.txt"); code != http.StatusOK || body != "home" {
+		t.Fatalf("static file after reload: got %d %q, want 200 %q", code, body, "home")
+	}
+}
+
+func TestReloadSwapsStaticDir(t *testing.T) {
+	cfg := config.LoadConfig()
+	cfg.StaticDir = newStaticDir(t, "app.js", "old")
+	s := newTestServer(t, cfg)
+
+	if code, body := get(s, "/app.js"); code != http.StatusOK || body != "old" {
+		t.Fatalf("before reload: got %d %q, want 200 %q", code, body, "old")
+	}
+
+	next := config.LoadConfig()
+	next.StaticDir = newStaticDir(t, "app.js", "new")
+	reload(t, s, next)
+
+	if code, body := get(s, "/app.js"); code != http.StatusOK || body != "new" {
+		t.Fatalf("after reload: got %d %q, want 200 %q", code, body, "new")
+	}
+}
+
+func TestStartMultipleListeners(t *testing.T) {
+	certFile, keyFile := newCertPair(t, "localhost")
+	httpAddr, httpsAddr, sniAddr := freeAddr(t), freeAddr(t), freeAddr(t)
+
+	cfg := config.LoadConfig()
+	cfg.StaticDir = newStaticDir(t, "site.txt", "shared")
+	cfg.CertFile, cfg.KeyFile = certFile, keyFile
+	cfg.Listeners = []*config.Listener{
+		{Addr: httpAddr, Protocol: config.ProtocolHTTP},
+		{Addr: httpsAddr, Protocol: config.ProtocolHTTPS, CertFile: certFile, KeyFile: keyFile},
+		// 未指定证书的 HTTPS 监听器按 SNI 使用顶层证书
+		{Addr: sniAddr, Protocol: config.ProtocolHTTPS},
+	}
+	s := newTestServer(t, cfg)
+	startServer(t, s)
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	for _, url := range []string{
+		"http://" + httpAddr + "/site.txt",
+		"https://" + httpsAddr + "/site.txt",
+		"https://" + sniAddr + "/site.txt",
+	} {
+		if body := fetch(t, client, url); body != "shared" {
+			t.Errorf("GET %s = %q, want %q", url, body, "shared")
+		}
+	}
+
+	// HTTPS 监听器不接受明文 HTTP 请求
+	resp, err := client.Get("http://" + httpsAddr + "/site.txt")
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Errorf("plain HTTP request to HTTPS listener succeeded")
+		}
+	}
+
+	want := "http://" + httpAddr + ", https://" + httpsAddr + ", https://" + sniAddr
+	if got := s.GetAddr(); got != want {
+		t.Errorf("GetAddr() = %q, want %q", got, want)
+	}
+}
+
+func TestListenerTLSProfile(t *testing.T) {
+	certFile, keyFile := newCertPair(t, "localhost")
+	inheritedAddr, modernAddr := freeAddr(t), freeAddr(t)
+
+	cfg := config.LoadConfig()
+	cfg.StaticDir = newStaticDir(t, "site.txt", "tls")
+	cfg.CertFile, cfg.KeyFile = certFile, keyFile
+	cfg.TLS = &config.TLSProfileConfig{Profile: config.TLSProfileIntermediate}
+	cfg.Listeners = []*config.Listener{
+		// 未设置 tls 的监听器使用顶层档位
+		{Addr: inheritedAddr, Protocol: config.ProtocolHTTPS},
+		{Addr: modernAddr, Protocol: config.ProtocolHTTPS, TLS: &config.TLSProfileConfig{Profile: config.TLSProfileModern}},
+	}
+	s := newTestServer(t, cfg)
+	startServer(t, s)
+
+	clientFor := func(maxVersion uint16) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true, MaxVersion: maxVersion},
+		}}
+	}
+	fetch(t, clientFor(tls.VersionTLS13), "https://"+inheritedAddr+"/site.txt")
+	fetch(t, clientFor(tls.VersionTLS13), "https://"+modernAddr+"/site.txt")
+
+	tests := []struct {
+		addr       string
+		maxVersion uint16
+		wantOK     bool
+	}{
+		{inheritedAddr, tls.VersionTLS12, true},
+		{inheritedAddr, tls.VersionTLS11, false},
+		{modernAddr, tls.VersionTLS13, true},
+		{modernAddr, tls.VersionTLS12, false},
+	}
+	for _, tt := range tests {
+		resp, err := clientFor(tt.maxVersion).Get("https://" + tt.addr + "/site.txt")
+		if err == nil {
+			resp.Body.Close()
+		}
+		if ok := err == nil; ok != tt.wantOK {
+			t.Errorf("%s with client max %s: err = %v, want success=%t", tt.addr, tls.VersionName(tt.maxVersion), err, tt.wantOK)
+		}
+	}
+
+	// TLS 档位变化需要重启才能生效
+	next := *cfg
+	next.TLS = &config.TLSProfileConfig{Profile: config.TLSProfileModern}
+	if sameListeners(&next, cfg) {
+		t.Error("sameListeners() = true after the top-level TLS profile changed")
+	}
+}
+
+func TestStartDerivedListener(t *testing.T) {
+	addr := freeAddr(t)
+	cfg := config.LoadConfig()
+	cfg.Host = addr
+	cfg.StaticDir = newStaticDir(t, "site.txt", "derived")
+	s := newTestServer(t, cfg)
+	startServer(t, s)
+
+	if body := fetch(t, http.DefaultClient, "http://"+addr+"/site.txt"); body != "derived" {
+		t.Errorf("GET = %q, want %q", body, "derived")
+	}
+	if got, want := s.GetAddr(), "http://"+addr; got != want {
+		t.Errorf("GetAddr() = %q, want %q", got, want)
+	}
+}
+
+func TestStartReleasesListenersOnBindFailure(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	first := freeAddr(t)
+
+	cfg := config.LoadConfig()
+	cfg.StaticDir = t.TempDir()
+	cfg.Listeners = []*config.Listener{
+		{Addr: first, Protocol: config.ProtocolHTTP},
+		{Addr: busy.Addr().String(), Protocol: config.ProtocolHTTP},
+	}
+	err = newTestServer(t, cfg).Start()
+	if err == nil || !strings.Contains(err.Error(), "failed to listen on http://"+busy.Addr().String()) {
+		t.Fatalf("Start() error = %v, want bind failure", err)
+	}
+
+	// 已绑定的第一个地址被释放
+	ln, err := net.Listen("tcp", first)
+	if err != nil {
+		t.Fatalf("first listener not released: %v", err)
+	}
+	ln.Close()
+}
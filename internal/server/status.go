@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+
+	"serve/internal/proxy"
+)
+
+// siteStatus 站点的代理上游状态
+type siteStatus struct {
+	Site   string             `json:"site"`
+	Hosts  []string           `json:"hosts,omitempty"`
+	Routes []proxy.RouteStats `json:"routes"`
+}
+
+// statusResponse 状态接口的响应
+type statusResponse struct {
+	Sites []siteStatus `json:"sites"`
+}
+
+// serveStatus 以 JSON 输出所有站点代理路由的负载均衡和健康检查状态
+// 状态中包含上游地址，只允许本机回环地址和 status_allow 中的客户端访问
+func (rt *router) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if !rt.statusAllowed(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := statusResponse{Sites: make([]siteStatus, 0, len(rt.all))}
+	for _, s := range rt.all {
+		status.Sites = append(status.Sites, siteStatus{
+			Site:   s.name,
+			Hosts:  s.hosts,
+			Routes: s.proxyHandler.Stats(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(status)
+}
+
+// statusAllowed 判断直连的客户端是否可以访问状态接口，不参考 X-Forwarded-For 等请求头
+func (rt *router) statusAllowed(r *http.Request) bool {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() {
+		return true
+	}
+	for _, prefix := range rt.statusAllow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"serve/internal/config"
+)
+
+// getStatus 以指定的客户端地址请求状态接口
+func getStatus(s *Server, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/_serve/status", nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStatusOutput(t *testing.T) {
+	cfg := config.LoadConfig()
+	cfg.StaticDir = t.TempDir()
+	cfg.StatusPath = "/_serve/status"
+	cfg.ProxyConfigs["api"] = &config.ProxyConfig{
+		Targets:      []*config.UpstreamTarget{{Address: "10.0.0.1:8080", Weight: 1}, {Address: "10.0.0.2:8080", Weight: 3}},
+		LoadBalancer: &config.LoadBalancerConfig{Policy: config.PolicyLeastConn},
+	}
+	cfg.VirtualHosts = []*config.VirtualHost{{Name: "www", Hosts: []string{"www.local"}}}
+	s := newTestServer(t, cfg)
+
+	rec := getStatus(s, "127.0.0.1:40000")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+
+	var got statusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	if len(got.Sites) != 2 || got.Sites[0].Site != defaultSiteName || got.Sites[1].Site != "vhost:www" {
+		t.Fatalf("sites = %+v, want default and vhost:www", got.Sites)
+	}
+	if hosts := got.Sites[1].Hosts; len(hosts) != 1 || hosts[0] != "www.local" {
+		t.Errorf("vhost hosts = %v, want [www.local]", hosts)
+	}
+	routes := got.Sites[0].Routes
+	if len(routes) != 1 || routes[0].Route != "api" || routes[0].Policy != config.PolicyLeastConn || routes[0].HealthCheck {
+		t.Fatalf("routes = %+v, want api with least_conn and no health check", routes)
+	}
+	upstreams := routes[0].Upstreams
+	if len(upstreams) != 2 || upstreams[1].Address != "10.0.0.2:8080" || upstreams[1].Weight != 3 || !upstreams[1].Healthy {
+		t.Errorf("upstreams = %+v", upstreams)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/_serve/status", nil)
+	req.RemoteAddr = "127.0.0.1:40000"
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: status %d, Allow %q, want 405 with Allow: GET, HEAD", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+func TestStatusAccess(t *testing.T) {
+	cfg := config.LoadConfig()
+	cfg.StaticDir = t.TempDir()
+	cfg.StatusPath = "/_serve/status"
+	s := newTestServer(t, cfg)
+
+	// 默认只允许本机回环地址
+	tests := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"127.0.0.1:40000", http.StatusOK},
+		{"127.0.0.2:40000", http.StatusOK},
+		{"[::1]:40000", http.StatusOK},
+		{"[::ffff:127.0.0.1]:40000", http.StatusOK},
+		{"10.1.2.3:40000", http.StatusForbidden},
+		{"192.168.1.20:40000", http.StatusForbidden},
+		{"[fd00::1]:40000", http.StatusForbidden},
+		{"not-an-address", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := getStatus(s, tt.remoteAddr).Code; got != tt.want {
+			t.Errorf("default: %s got %d, want %d", tt.remoteAddr, got, tt.want)
+		}
+	}
+
+	// 转发头不能绕过访问控制
+	req := httptest.NewRequest(http.MethodGet, "/_serve/status", nil)
+	req.RemoteAddr = "10.1.2.3:40000"
+	req.Header.Set("X-Forwarded-For", "127.0.0.1")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("X-Forwarded-For: 127.0.0.1 from 10.1.2.3 got %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	next := config.LoadConfig()
+	next.StaticDir = cfg.StaticDir
+	next.StatusPath = cfg.StatusPath
+	next.StatusAllow = []string{"10.0.0.0/8", "192.168.1.20", "fd00::/8"}
+	reload(t, s, next)
+
+	tests = []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"127.0.0.1:40000", http.StatusOK},
+		{"10.1.2.3:40000", http.StatusOK},
+		{"192.168.1.20:40000", http.StatusOK},
+		{"192.168.1.21:40000", http.StatusForbidden},
+		{"[fd00::1]:40000", http.StatusOK},
+		{"[::ffff:10.1.2.3]:40000", http.StatusOK},
+		{"172.16.0.1:40000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := getStatus(s, tt.remoteAddr).Code; got != tt.want {
+			t.Errorf("status_allow: %s got %d, want %d", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
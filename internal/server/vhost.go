@@ -0,0 +1,225 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/netip"
+
+	"serve/internal/config"
+	"serve/internal/hostmatch"
+	"serve/internal/proxy"
+	"serve/internal/static"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultSiteName 由顶层配置构成的默认站点名称
+const defaultSiteName = "default"
+
+// site 站点，对应一个虚拟主机或顶层配置
+type site struct {
+	name          string
+	hosts         []string
+	headers       map[string]string
+	staticHandler *static.Handler // 未配置静态文件目录时为 nil
+	proxyHandler  *proxy.Handler
+	certificate   *tls.Certificate // 未配置证书时为 nil
+}
+
+// router 站点路由快照，热加载时整体替换
+type router struct {
+	all         []*site        // 所有站点，包括由顶层配置构成的站点
+	sites       []*site        // 按配置顺序排列的虚拟主机站点
+	defaultSite *site          // 没有虚拟主机匹配时使用的站点
+	statusPath  string         // 状态接口路径，为空时不启用
+	statusAllow []netip.Prefix // 除本机回环地址外允许访问状态接口的网段
+	caPage      *caPage        // 本地 CA 安装页面，服务器证书不是由 serve 管理的本地 CA 签发时为 nil
+}
+
+// siteConfig 构建站点所需的配置
+type siteConfig struct {
+	name         string
+	hosts        []string
+	staticDir    string
+	proxyConfigs map[string]*config.ProxyConfig
+	certFile     string
+	keyFile      string
+	headers      map[string]string
+	isDefault    bool
+}
+
+// newRouter 根据配置构建站点路由
+// previous 为上一次的路由快照，同名站点复用已有的处理器，仅原子替换其配置
+// 证书全部加载成功后才会更新处理器，加载失败时旧的路由快照保持不变
+// 不再存在的站点会关闭其代理处理器（停止健康检查）
+func newRouter(cfg *config.Config, previous *router, logger *logrus.Logger) (*router, error) {
+	// 顶层配置构成默认站点，可以被标记为 default 的虚拟主机替代
+	siteConfigs := []siteConfig{{
+		name:         defaultSiteName,
+		staticDir:    cfg.StaticDir,
+		proxyConfigs: cfg.ProxyConfigs,
+		certFile:     cfg.CertFile,
+		keyFile:      cfg.KeyFile,
+	}}
+	for _, vhost := range cfg.VirtualHosts {
+		siteConfigs = append(siteConfigs, siteConfig{
+			name:         "vhost:" + vhost.Name,
+			hosts:        vhost.Hosts,
+			staticDir:    vhost.StaticDir,
+			proxyConfigs: vhost.ProxyConfigs,
+			certFile:     vhost.CertFile,
+			keyFile:      vhost.KeyFile,
+			headers:      vhost.Headers,
+			isDefault:    vhost.Default,
+		})
+	}
+
+	// 先加载所有证书
+	certificates := make([]*tls.Certificate, len(siteConfigs))
+	for i, sc := range siteConfigs {
+		if sc.certFile == "" || sc.keyFile == "" {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(sc.certFile, sc.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to load certificate pair: %v", sc.name, err)
+		}
+		certificates[i] = &cert
+	}
+
+	existing := make(map[string]*site)
+	if previous != nil {
+		for _, s := range previous.all {
+			existing[s.name] = s
+		}
+	}
+
+	rt := &router{statusPath: cfg.StatusPath, caPage: newCAPage(cfg, certificates, logger)}
+	for _, allow := range cfg.StatusAllow {
+		if prefix, err := config.ParseIPPrefix(allow); err == nil {
+			rt.statusAllow = append(rt.statusAllow, prefix)
+		}
+	}
+	for i, sc := range siteConfigs {
+		s := buildSite(sc, existing[sc.name], logger)
+		s.certificate = certificates[i]
+		rt.all = append(rt.all, s)
+		// 复用的站点不能在下面被关闭，顶层站点同样如此
+		delete(existing, sc.name)
+
+		if i == 0 {
+			rt.defaultSite = s
+			continue
+		}
+		rt.sites = append(rt.sites, s)
+		if sc.isDefault {
+			rt.defaultSite = s
+		}
+	}
+
+	for _, removed := range existing {
+		removed.proxyHandler.Close()
+	}
+
+	return rt, nil
+}
+
+// close 关闭所有站点的代理处理器
+func (rt *router) close() {
+	for _, s := range rt.all {
+		s.proxyHandler.Close()
+	}
+}
+
+// buildSite 构建站点，存在同名的旧站点时复用其处理器
+func buildSite(sc siteConfig, previous *site, logger *logrus.Logger) *site {
+	s := &site{
+		name:    sc.name,
+		hosts:   sc.hosts,
+		headers: sc.headers,
+	}
+
+	if previous != nil {
+		s.proxyHandler = previous.proxyHandler
+		s.proxyHandler.Update(sc.proxyConfigs)
+	} else {
+		s.proxyHandler = proxy.NewHandler(sc.proxyConfigs, logger)
+	}
+
+	if sc.staticDir != "" {
+		if previous != nil && previous.staticHandler != nil {
+			s.staticHandler = previous.staticHandler
+			s.staticHandler.Update(sc.staticDir)
+		} else {
+			s.staticHandler = static.NewHandler(sc.staticDir, logger)
+		}
+	}
+
+	return s
+}
+
+// match 根据 Host 头查找站点，没有匹配时返回默认站点
+// 虚拟主机按配置顺序匹配，同一虚拟主机内任意一条主机规则匹配即可
+func (rt *router) match(requestHost string) *site {
+	for _, s := range rt.sites {
+		for _, host := range s.hosts {
+			if hostmatch.Match(host, requestHost) {
+				return s
+			}
+		}
+	}
+	return rt.defaultSite
+}
+
+// certificate 根据 TLS SNI 选择证书
+// 优先使用匹配的虚拟主机证书，其次使用默认站点证书，最后使用任意已配置的证书
+func (rt *router) certificate(serverName string) (*tls.Certificate, error) {
+	if serverName != "" {
+		for _, s := range rt.sites {
+			if s.certificate == nil {
+				continue
+			}
+			for _, host := range s.hosts {
+				if hostmatch.MatchName(host, serverName) {
+					return s.certificate, nil
+				}
+			}
+		}
+	}
+
+	if rt.defaultSite.certificate != nil {
+		return rt.defaultSite.certificate, nil
+	}
+	for _, s := range rt.all {
+		if s.certificate != nil {
+			return s.certificate, nil
+		}
+	}
+	return nil, fmt.Errorf("no certificate configured for server name %q", serverName)
+}
+
+// ServeHTTP 处理站点请求：先设置站点响应头，再检查代理路由和来源页面所属的代理路由，最后使用静态文件服务
+func (s *site) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for name, value := range s.headers {
+		w.Header().Set(name, value)
+	}
+
+	// 首先检查是否匹配代理路由
+	if s.proxyHandler.IsProxyRequest(r) {
+		s.proxyHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// 没有对应的静态文件时，按来源页面转发到其所属的代理路由（sticky origin）
+	if s.proxyHandler.HasStickyRoutes() && (s.staticHandler == nil || !s.staticHandler.Exists(r)) && s.proxyHandler.ServeSticky(w, r) {
+		return
+	}
+
+	// 否则使用静态文件服务
+	if s.staticHandler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.staticHandler.ServeHTTP(w, r)
+}
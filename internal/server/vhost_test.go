@@ -0,0 +1,192 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"serve/internal/config"
+)
+
+// getHost 以指定的 Host 头通过服务器的路由处理请求
+func getHost(s *Server, host, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Host = host
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestVirtualHostDispatch(t *testing.T) {
+	upstream := newUpstream(t)
+	cfg := config.LoadConfig()
+	cfg.StaticDir = newStaticDir(t, "site.txt", "default")
+	cfg.VirtualHosts = []*config.VirtualHost{
+		{
+			Name:      "exact",
+			Hosts:     []string{"a.local:8080"},
+			StaticDir: newStaticDir(t, "site.txt", "exact"),
+			Headers:   map[string]string{"X-Site": "exact"},
+		},
+		{
+			Name:      "name",
+			Hosts:     []string{"a.local", "b.local"},
+			StaticDir: newStaticDir(t, "site.txt", "name"),
+		},
+		{
+			Name:         "wildcard",
+			Hosts:        []string{"*.example.com"},
+			ProxyConfigs: map[string]*config.ProxyConfig{"api": {TargetDomain: upstream.Listener.Addr().String()}},
+		},
+	}
+	s := newTestServer(t, cfg)
+
+	tests := []struct {
+		host, path string
+		wantCode   int
+		wantBody   string
+	}{
+		{"a.local:8080", "/site.txt", http.StatusOK, "exact"},
+		{"A.LOCAL:8080", "/site.txt", http.StatusOK, "exact"},
+		{"a.local:9090", "/site.txt", http.StatusOK, "name"},
+		{"b.local", "/site.txt", http.StatusOK, "name"},
+		{"www.example.com", "/api/users", http.StatusOK, "upstream /api/users"},
+		// 没有静态文件目录的虚拟主机不回退到默认站点
+		{"www.example.com", "/site.txt", http.StatusNotFound, ""},
+		{"example.com", "/site.txt", http.StatusOK, "default"},
+		{"other.local", "/site.txt", http.StatusOK, "default"},
+	}
+	for _, tt := range tests {
+		rec := getHost(s, tt.host, tt.path)
+		if rec.Code != tt.wantCode || (tt.wantBody != "" && rec.Body.String() != tt.wantBody) {
+			t.Errorf("%s%s: got %d %q, want %d %q", tt.host, tt.path, rec.Code, rec.Body.String(), tt.wantCode, tt.wantBody)
+		}
+	}
+
+	if got := getHost(s, "a.local:8080", "/site.txt").Header().Get("X-Site"); got != "exact" {
+		t.Errorf("X-Site = %q, want %q", got, "exact")
+	}
+	if got := getHost(s, "b.local", "/site.txt").Header().Get("X-Site"); got != "" {
+		t.Errorf("X-Site on other site = %q, want empty", got)
+	}
+}
+
+func TestVirtualHostDefault(t *testing.T) {
+	cfg := config.LoadConfig()
+	cfg.StaticDir = newStaticDir(t, "site.txt", "top-level")
+	cfg.VirtualHosts = []*config.VirtualHost{
+		{Name: "fallback", Default: true, StaticDir: newStaticDir(t, "site.txt", "fallback")},
+	}
+	s := newTestServer(t, cfg)
+
+	if rec := getHost(s, "unknown.local", "/site.txt"); rec.Body.String() != "fallback" {
+		t.Errorf("unmatched host served %q, want %q", rec.Body.String(), "fallback")
+	}
+}
+
+func TestStickyOriginPrefersStaticFiles(t *testing.T) {
+	upstream := newUpstream(t)
+	strip := true
+	cfg := config.LoadConfig()
+	cfg.StaticDir = newStaticDir(t, "app.js", "static")
+	cfg.ProxyConfigs["app"] = &config.ProxyConfig{TargetDomain: upstream.Listener.Addr().String(), StripPrefix: &strip, StickyOrigin: true}
+	s := newTestServer(t, cfg)
+
+	getFrom := func(path, referer string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = "serve.local"
+		if referer != "" {
+			req.Header.Set("Referer", referer)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	tests := []struct {
+		path, referer, want string
+	}{
+		// 静态文件目录中存在的文件优先
+		{"/app.js", "http://serve.local/app/index.html", "static"},
+		{"/api/x", "http://serve.local/app/index.html", "upstream /api/x"},
+		{"/app/api/x", "", "upstream /api/x"},
+		{"/api/x", "", "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		if got := getFrom(tt.path, tt.referer); got != tt.want {
+			t.Errorf("%s (Referer %q): got %q, want %q", tt.path, tt.referer, got, tt.want)
+		}
+	}
+}
+
+// waitFor 等待条件成立，超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestReloadKeepsDefaultSiteHealthChecks 热加载后顶层站点的健康检查继续运行，恢复的上游重新参与负载均衡
+func TestReloadKeepsDefaultSiteHealthChecks(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		ProxyConfigs: map[string]*config.ProxyConfig{
+			"api": {
+				Targets: []*config.UpstreamTarget{{Address: strings.TrimPrefix(backend.URL, "http://"), Weight: 1}},
+				HealthCheck: &config.HealthCheckConfig{
+					Interval:           config.Duration(20 * time.Millisecond),
+					Timeout:            config.Duration(10 * time.Millisecond),
+					HealthyThreshold:   1,
+					UnhealthyThreshold: 1,
+				},
+			},
+		},
+	}
+
+	logger := testLogger()
+	rt, err := newRouter(cfg, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if rt, err = newRouter(cfg, rt, logger); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer rt.close()
+
+	upstreamHealthy := func() bool {
+		return rt.defaultSite.proxyHandler.Stats()[0].Upstreams[0].Healthy
+	}
+	lastCheck := func() time.Time {
+		if last := rt.defaultSite.proxyHandler.Stats()[0].Upstreams[0].LastCheck; last != nil {
+			return *last
+		}
+		return time.Time{}
+	}
+
+	start := time.Now()
+	waitFor(t, "health check after reload", func() bool { return lastCheck().After(start) })
+
+	healthy.Store(false)
+	waitFor(t, "upstream marked unhealthy", func() bool { return !upstreamHealthy() })
+
+	healthy.Store(true)
+	waitFor(t, "upstream healthy again", upstreamHealthy)
+}
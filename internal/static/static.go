@@ -2,26 +2,51 @@ package static
 
 import (
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 )
 
 // Handler 静态文件服务处理器
 type Handler struct {
+	state  atomic.Pointer[state] // 当前生效的目录状态，热加载时整体替换
+	logger *logrus.Logger
+}
+
+// state 静态文件服务状态快照
+type state struct {
 	dir        string // 静态文件目录
 	fileServer http.Handler
-	logger     *logrus.Logger
 }
 
 // NewHandler 创建静态文件服务处理器
 func NewHandler(staticDir string, logger *logrus.Logger) *Handler {
-	return &Handler{
+	h := &Handler{
+		logger: logger,
+	}
+	h.Update(staticDir)
+	return h
+}
+
+// Update 原子替换静态文件目录，进行中的请求继续使用旧目录
+func (h *Handler) Update(staticDir string) {
+	h.state.Store(&state{
 		dir:        staticDir,
 		fileServer: http.FileServer(http.Dir(staticDir)),
-		logger:     logger,
+	})
+}
+
+// Exists 判断请求路径对应的文件或目录是否存在
+func (h *Handler) Exists(r *http.Request) bool {
+	path := filepath.Clean("/" + strings.TrimPrefix(r.URL.Path, "/"))
+	if strings.Contains(path, "..") {
+		return false
 	}
+	_, err := os.Stat(filepath.Join(h.state.Load().dir, filepath.FromSlash(path)))
+	return err == nil
 }
 
 // ServeHTTP 处理静态文件请求
@@ -44,5 +69,5 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debugf("Serving static file: %s", path)
 
 	// 使用标准文件服务器处理请求
-	h.fileServer.ServeHTTP(w, r)
+	h.state.Load().fileServer.ServeHTTP(w, r)
 }